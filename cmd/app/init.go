@@ -2,19 +2,68 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
 	"ws/dtn-satellite-sdn/sdn"
+	"ws/dtn-satellite-sdn/sdn/clientset"
+	"ws/dtn-satellite-sdn/sdn/filter"
+	"ws/dtn-satellite-sdn/sdn/ipam"
+	"ws/dtn-satellite-sdn/sdn/link"
+	"ws/dtn-satellite-sdn/sdn/route"
+	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
+	"ws/dtn-satellite-sdn/sdn/util"
 )
 
 var (
-	url      string
-	node     int
-	interval int
-	is_test  bool
-	is_debug bool
+	url                 string
+	node                int
+	interval            int
+	is_test             bool
+	is_debug            bool
+	grazing_altitude    float64
+	min_elevation       float64
+	access_num          int
+	topology            string
+	nearest_k           int
+	max_range           float64
+	motif               string
+	hysteresis          bool
+	release_range       float64
+	release_angle       float64
+	hysteresis_margin   float64
+	polar_latitude      float64
+	suppress_seam       bool
+	seam_angle          float64
+	high_orbit_altitude float64
+	relay_num           int
+	filter_path         string
+	ipam_configmap      string
+	ipam_grace_period   int
+	reattach            bool
+	ip_family           string
+	global_prefix       string
+	link_prefix         string
+	route_tolerance     float64
+	router              string
+	congestion_factor   float64
+	load_smoothing      float64
+	load_expiry         int
+	link_capacity       float64
+	load_url            string
+	processing_delay    float64
+	link_bandwidth      string
+	link_loss           float64
+	latency_step        float64
+	link_budget         string
+	packet_size         float64
+	look_ahead          bool
+	horizon             int
+	horizon_step        int
+	multipath           int
+	disjoint            string
 
 	initCmd = &cobra.Command{
 		Use:   "init",
@@ -24,12 +73,121 @@ var (
 			if is_debug {
 				logrus.SetLevel(logrus.DebugLevel)
 			}
+			if access_num < 1 {
+				return fmt.Errorf("access num should be at least 1, got %v", access_num)
+			}
+			if min_elevation < -90 || min_elevation > 90 {
+				return fmt.Errorf("min elevation should be in [-90, 90], got %v", min_elevation)
+			}
+			if relay_num < 1 {
+				return fmt.Errorf("relay num should be at least 1, got %v", relay_num)
+			}
+			if nearest_k < 1 {
+				return fmt.Errorf("nearest k should be at least 1, got %v", nearest_k)
+			}
+			satv2.GrazingAltitude = grazing_altitude
+			satv2.DefaultMinElevation = min_elevation
+			satv2.DefaultMaxAccessNum = access_num
+			satv2.HighOrbitAltitude = high_orbit_altitude
+			if err := util.SetIPFamily(ip_family, global_prefix, link_prefix); err != nil {
+				return err
+			}
+			if util.IPFamily == util.IPv6 {
+				logrus.Warnf("ipv6 mode needs a podserver image accepting the address family argument of start.sh, which %s is not verified to", util.ImageName)
+			}
+			config := clientset.NewDefaultSDNConfig()
+			config.RelayNum = relay_num
+			if r, err := route.NewRouter(router, route_tolerance, util.ThreadNums); err != nil {
+				return fmt.Errorf("invalid router: %v", err)
+			} else {
+				config.Router = r
+			}
+			if loadAware, ok := config.Router.(*route.LoadAwareRouter); ok {
+				loadAware.CongestionFactor = congestion_factor
+				config.Load = &clientset.LoadConfig{
+					Smoothing: load_smoothing,
+					Expiry:    time.Duration(load_expiry) * time.Second,
+					Capacity:  link_capacity,
+					ScrapeURL: load_url,
+				}
+				if err := config.Load.Validate(); err != nil {
+					return fmt.Errorf("invalid load-aware routing: %v", err)
+				}
+			}
+			config.LinkModel = &link.LinkModel{
+				ProcessingDelay: processing_delay,
+				Bandwidth:       link_bandwidth,
+				Loss:            link_loss,
+				LatencyStep:     latency_step,
+				PacketSize:      packet_size,
+			}
+			if link_budget != "" {
+				budget, err := link.LoadLinkBudget(link_budget)
+				if err != nil {
+					return err
+				}
+				config.LinkModel.Budget = budget
+			}
+			if err := config.LinkModel.Validate(); err != nil {
+				return fmt.Errorf("invalid link model: %v", err)
+			}
+			if filter_path != "" {
+				satelliteFilter, err := filter.LoadSatelliteFilter(filter_path)
+				if err != nil {
+					return err
+				}
+				config.Filter = satelliteFilter
+			}
+			if strategy, err := clientset.NewTopologyStrategy(topology, nearest_k, max_range, motif); err != nil {
+				return fmt.Errorf("invalid topology: %v", err)
+			} else {
+				config.Topology = strategy
+			}
+			if hysteresis {
+				config.Hysteresis = &clientset.HysteresisConfig{
+					ReleaseRange: release_range,
+					ReleaseAngle: release_angle,
+					Margin:       hysteresis_margin,
+				}
+				if err := config.Hysteresis.Validate(); err != nil {
+					return fmt.Errorf("invalid hysteresis: %v", err)
+				}
+			}
+			if polar_latitude > 0 || suppress_seam {
+				config.CrossPlane = &clientset.CrossPlaneConfig{
+					PolarLatitude: polar_latitude,
+					SuppressSeam:  suppress_seam,
+					SeamAngle:     seam_angle,
+				}
+			}
+			if !is_test && ipam_configmap != "" {
+				config.IPAMStore = &ipam.ConfigMapStore{Name: ipam_configmap}
+				config.IPAMGracePeriod = time.Duration(ipam_grace_period) * time.Second
+			}
+			if look_ahead {
+				// Routes remain valid until the next update by default, whose horizon is derived from timestamps
+				if horizon < 0 || horizon_step <= 0 {
+					return fmt.Errorf("horizon should be non-negative and horizon step should be positive for look-ahead routing")
+				}
+				config.LookAhead = &clientset.LookAheadConfig{
+					Horizon:   time.Duration(horizon) * time.Second,
+					Step:      time.Duration(horizon_step) * time.Second,
+					Predictor: &clientset.RemotePredictor{URL: url},
+				}
+			}
+			if multipath > 1 {
+				config.Multipath = &clientset.MultipathConfig{K: multipath, Disjoint: disjoint}
+				if err := config.Multipath.Validate(); err != nil {
+					return fmt.Errorf("invalid multipath: %v", err)
+				}
+			}
+			config.Reattach = reattach
 			if is_test {
-				if err := sdn.RunSDNServerTest(url, node, interval); err != nil {
+				if err := sdn.RunSDNServerTest(url, node, interval, config); err != nil {
 					return fmt.Errorf("init test emulation environment failed: %v", err)
 				}
 			} else {
-				if err := sdn.RunSDNServer(url, node, interval); err != nil {
+				if err := sdn.RunSDNServer(url, node, interval, config); err != nil {
 					return fmt.Errorf("init emulation environment failed: %v", err)
 				}
 			}
@@ -44,6 +202,48 @@ func init() {
 	initCmd.Flags().IntVarP(&interval, "interval", "i", -1, "Assign update interval for Satellite SDN Controller (-1 means 'no update')")
 	initCmd.Flags().BoolVar(&is_test, "test", false, "Open the test mode")
 	initCmd.Flags().BoolVar(&is_debug, "debug", false, "Open the debug mode")
+	initCmd.Flags().Float64Var(&min_elevation, "min-elevation", satv2.DefaultMinElevation, "The default elevation mask(degree) for terminals to access satellites")
+	initCmd.Flags().IntVar(&access_num, "access-num", satv2.DefaultMaxAccessNum, "The default max number of satellites a terminal accesses simultaneously")
+	initCmd.Flags().StringVar(&ipam_configmap, "ipam-configmap", "", "The ConfigMap which persists node addresses across restarts, whose addresses are reset unless --reattach (empty means no persistence)")
+	initCmd.Flags().IntVar(&ipam_grace_period, "ipam-grace-period", int(ipam.DefaultGracePeriod/time.Second), "The time(s) that addresses of removed nodes are kept for them before reused by new nodes")
+	initCmd.Flags().BoolVar(&reattach, "reattach", false, "Reattach to an existing emulation instead of creating topologies and routes")
+	initCmd.Flags().StringVar(&router, "router", route.ShortestRouterName, "The routing algorithm (shortest/min-hop/load-aware/greedy)")
+	initCmd.Flags().Float64Var(&congestion_factor, "congestion-factor", route.DefaultCongestionFactor, "The factor of queueing delay added to link latency by load-aware routing")
+	initCmd.Flags().Float64Var(&load_smoothing, "load-smoothing", clientset.DefaultLoadSmoothing, "The weight(0~1] of the latest link utilization in its moving average, smaller means stronger damping")
+	initCmd.Flags().IntVar(&load_expiry, "load-expiry", int(clientset.DefaultLoadExpiry/time.Second), "The age(s) after which link throughput reported is regarded as stale")
+	initCmd.Flags().Float64Var(&link_capacity, "link-capacity", clientset.DefaultLinkCapacity, "The capacity(Mbps) of links without bandwidth limit or link budget for load-aware routing")
+	initCmd.Flags().StringVar(&load_url, "load-url", "", "The address scraped for link throughput at each update (empty means throughput is only reported to /reportLinkLoad)")
+	initCmd.Flags().Float64Var(&route_tolerance, "route-tolerance", route.DefaultWeightTolerance, "The relative change of a link's latency below which routes over it are not recomputed (0 means exact routes)")
+	initCmd.Flags().Float64Var(&processing_delay, "processing-delay", 0, "The processing delay(ms) added by each hop to link latency")
+	initCmd.Flags().StringVar(&link_bandwidth, "link-bandwidth", "", "The bandwidth limit of each link in tc format, e.g. 100Mbps (empty means no limit)")
+	initCmd.Flags().Float64Var(&link_loss, "link-loss", 0, "The loss rate(percentage) of each link")
+	initCmd.Flags().Float64Var(&latency_step, "latency-step", link.DefaultLatencyStep, "The granularity(ms) of latency applied to links, topologies are updated only when it changes")
+	initCmd.Flags().StringVar(&link_budget, "link-budget", "", "The link budget file's path, which declares radios to compute SNR and rate of links")
+	initCmd.Flags().Float64Var(&packet_size, "packet-size", link.DefaultPacketSize, "The packet size(byte) whose transmission delay is added to link latency with link budget")
+	initCmd.Flags().BoolVar(&look_ahead, "look-ahead", false, "Compute routes only over links lasting the look-ahead horizon, destinations without such a path are unreachable")
+	initCmd.Flags().IntVar(&horizon, "horizon", 0, "The look-ahead horizon(s) in simulated time (0 means the gap between timestamps of successive updates)")
+	initCmd.Flags().IntVar(&horizon_step, "horizon-step", int(clientset.DefaultHorizonStep/time.Second), "The interval(s) between snapshots within the look-ahead horizon in simulated time")
+	initCmd.Flags().IntVar(&multipath, "multipath", 1, "The max number of alternative routes returned by /getRoute, installed routes are single path (1 means no alternative)")
+	initCmd.Flags().StringVar(&disjoint, "disjoint", "", "The disjointness(link/node) of alternative routes (empty means k-shortest routes)")
+	initCmd.Flags().StringVar(&ip_family, "ip-family", util.IPv4, "The address family of global and link IPs (ipv4/ipv6)")
+	initCmd.Flags().StringVar(&global_prefix, "global-prefix", util.DefaultGlobalPrefixV6, "The ULA prefix of global IPs in ipv6 mode")
+	initCmd.Flags().StringVar(&link_prefix, "link-prefix", util.DefaultLinkPrefixV6, "The ULA prefix of /127 link IPs in ipv6 mode")
+	initCmd.Flags().StringVar(&filter_path, "filter", "", "The satellite filter file's path, which declares include/exclude rules (default excludes satellites 53302 and 53347)")
+	initCmd.Flags().Float64Var(&high_orbit_altitude, "high-orbit-altitude", satv2.HighOrbitAltitude, "The min altitude(km) of satellites in the high-orbit(MEO/GEO/IGSO) layer")
+	initCmd.Flags().IntVar(&relay_num, "relay-num", clientset.DefaultRelayNum, "The number of low-orbit satellites connected by each high-orbit satellite")
+	initCmd.Flags().Float64Var(&grazing_altitude, "grazing-altitude", satv2.GrazingAltitude, "The min altitude(km) that an inter-satellite link can pass by")
+
+	initCmd.Flags().StringVar(&topology, "topology", clientset.DefaultTopologyName, "The strategy to compute inter-satellite links (default/grid/nearest-k/max-range/motif)")
+	initCmd.Flags().IntVar(&nearest_k, "nearest-k", 4, "The number of neighbours for nearest-k topology")
+	initCmd.Flags().Float64Var(&max_range, "max-range", 5000, "The max length(km) of inter-satellite links for max-range topology")
+	initCmd.Flags().StringVar(&motif, "motif", "0:1,1:0", "The motif(planeOffset:indexOffset,...) for motif topology")
+	initCmd.Flags().BoolVar(&hysteresis, "hysteresis", false, "Keep existing cross-plane links until they exceed release thresholds")
+	initCmd.Flags().Float64Var(&release_range, "release-range", 0, "The max length(km) of a kept cross-plane link (0 means no limit)")
+	initCmd.Flags().Float64Var(&release_angle, "release-angle", 0, "The max central angle(degree) of a kept cross-plane link (0 means no limit)")
+	initCmd.Flags().Float64Var(&polar_latitude, "polar-latitude", 0, "The latitude(degree) above which cross-plane links are disabled (0 means no cutoff)")
+	initCmd.Flags().BoolVar(&suppress_seam, "suppress-seam", false, "Disable cross-plane links across the seam between the first and the last counter-rotating planes")
+	initCmd.Flags().Float64Var(&seam_angle, "seam-angle", 90, "The min angle(degree) between normals of the planes beside the seam to regard them as counter-rotating")
+	initCmd.Flags().Float64Var(&hysteresis_margin, "hysteresis-margin", 0.2, "The ratio by which a new cross-plane link must be shorter to replace a kept one")
 
 	initCmd.MarkFlagRequired("url")
 	initCmd.MarkFlagRequired("node")
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"fmt"
 	"math"
+	"time"
 	"ws/dtn-satellite-sdn/position"
+	"ws/dtn-satellite-sdn/sdn/filter"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -10,9 +13,13 @@ import (
 
 var (
 	tle      string
+	scenario string
 	fixedNum int
 	maxNum 	 int
 	isDebugMode bool
+	startTime string
+	speed     float64
+	posFilter string
 
 	posCmd = &cobra.Command{
 		Use:   "pos",
@@ -22,7 +29,26 @@ var (
 			if isDebugMode {
 				logrus.SetLevel(logrus.DebugLevel)
 			}
-			position.RunPositionModule(tle, fixedNum, maxNum)
+			start := time.Time{}
+			if startTime != "" {
+				var err error
+				if start, err = time.Parse(time.RFC3339, startTime); err != nil {
+					return fmt.Errorf("parse start time failed: %v", err)
+				}
+				// Offsets like +08:00 are allowed, but SGP4 takes calendar fields in UTC
+				start = start.UTC()
+			}
+			if speed <= 0 {
+				return fmt.Errorf("speed should be positive, got %v", speed)
+			}
+			var satelliteFilter *filter.SatelliteFilter
+			if posFilter != "" {
+				var err error
+				if satelliteFilter, err = filter.LoadSatelliteFilter(posFilter); err != nil {
+					return err
+				}
+			}
+			position.RunPositionModule(tle, scenario, fixedNum, maxNum, position.NewSimClock(start, speed), satelliteFilter)
 			return nil
 		},
 	}
@@ -30,12 +56,15 @@ var (
 
 func init() {
 	posCmd.Flags().StringVarP(&tle, "tle", "t", "", "The TLE file's path")
-	posCmd.Flags().IntVarP(&fixedNum, "num", "n", 10, "The number of fixed network node.")
+	posCmd.Flags().StringVar(&scenario, "scenario", "", "The scenario file's path, which declares ground stations, users and missiles (default is no station or missile)")
+	posCmd.Flags().IntVarP(&fixedNum, "num", "n", 10, "The number of fixed network node without scenario (ignored if scenario is specified).")
 	posCmd.Flags().IntVar(&maxNum, "max", math.MaxInt32, "The max number of satellites")
 	posCmd.Flags().BoolVar(&isDebugMode, "debug", false, "set log level to debug")
+	posCmd.Flags().StringVar(&startTime, "start", "", "The start epoch of simulation clock in RFC3339 format (default is now)")
+	posCmd.Flags().StringVar(&posFilter, "filter", "", "The satellite filter file's path, which declares include/exclude rules")
+	posCmd.Flags().Float64Var(&speed, "speed", 1.0, "The ratio of simulated time to real time")
 
 	posCmd.MarkFlagRequired("tle")
-	posCmd.MarkFlagRequired("num")
 
 	rootCmd.AddCommand(posCmd)
 }
@@ -42,7 +42,12 @@ func (client *RedisClient) MultiGet(keys []string) ([]string, error) {
 	} else {
 		var result []string
 		for _, a := range output {
-			result = append(result, a.(string))
+			// Missing keys(e.g. objects not reconciled yet) are returned as nil
+			if val, ok := a.(string); ok {
+				result = append(result, val)
+			} else {
+				result = append(result, "")
+			}
 		}
 		return result, nil
 	}
@@ -3,6 +3,7 @@ package flow
 import (
 	"context"
 	"fmt"
+	"net"
 	"os/exec"
 	"strings"
 	"ws/dtn-satellite-sdn/sdn/util"
@@ -11,7 +12,6 @@ import (
 )
 
 const (
-	ipSymbol    = "10.233"
 	ClientLabel = "type=client"
 	ServerLabel = "type=server"
 )
@@ -45,15 +45,17 @@ func GetIPByIfconfig(namespace string, podname string) string {
 	cmd := exec.Command("bash", "-c", fmt.Sprintf("kubectl exec -it -n %s %s -- ifconfig", namespace, podname))
 	stdout, _ := cmd.CombinedOutput()
 	ifconfig := string(stdout)
-	words := strings.Split(ifconfig, " ")
-	var IP string
-	for _, word := range words {
-		if strings.Contains(word, ipSymbol) {
-			IP = word[5:]
-			break
+	for _, word := range strings.Fields(ifconfig) {
+		// Addresses are printed like "addr:10.233.0.1", "fd00:233::1/64" or "fd00:233::1" by versions of ifconfig
+		candidate := strings.TrimPrefix(word, "addr:")
+		if idx := strings.Index(candidate, "/"); idx >= 0 {
+			candidate = candidate[:idx]
+		}
+		if ip := net.ParseIP(candidate); ip != nil && util.IsGlobalIP(ip) {
+			return candidate
 		}
 	}
-	return IP
+	return ""
 }
 
 func StartClient(bandwidth string) error {
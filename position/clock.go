@@ -0,0 +1,140 @@
+package position
+
+import (
+	"sync"
+	"time"
+)
+
+// SimClock is the simulation clock of position module.
+// Simulated time starts at a fixed epoch and advances `speed` times faster than wall-clock time.
+type SimClock struct {
+	mu sync.Mutex
+
+	// start is the simulated time when the clock was created
+	start time.Time
+
+	// epoch is the simulated time at anchor
+	epoch time.Time
+
+	// anchor is the wall-clock time at which epoch was taken
+	anchor time.Time
+
+	// speed is the ratio of simulated time to wall-clock time
+	speed float64
+
+	// paused means that simulated time is frozen at epoch
+	paused bool
+
+	// wallNow returns current wall-clock time(replaceable for test)
+	wallNow func() time.Time
+}
+
+type ClockStatus struct {
+	TimeStamp int64   `json:"unixTimeStamp"`
+	Speed     float64 `json:"speed"`
+	Paused    bool    `json:"paused"`
+}
+
+// Function: NewSimClock
+// Description: Create a simulation clock starting at start and running speed times faster than real time.
+// 1. start: The simulated time when the clock is created, zero value means time.Now().
+// 2. speed: Time-scaling factor, which should be positive.
+func NewSimClock(start time.Time, speed float64) *SimClock {
+	if speed <= 0 {
+		speed = 1.0
+	}
+	now := time.Now()
+	if start.IsZero() {
+		start = now
+	}
+	// Calendar fields of simulated time are passed to SGP4, which expects UTC
+	start = start.UTC()
+	return &SimClock{
+		start:   start,
+		epoch:   start,
+		anchor:  now,
+		speed:   speed,
+		wallNow: time.Now,
+	}
+}
+
+// Function: Now
+// Description: Return current simulated time.
+func (c *SimClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now()
+}
+
+func (c *SimClock) now() time.Time {
+	if c.paused {
+		return c.epoch
+	}
+	elapsed := float64(c.wallNow().Sub(c.anchor)) * c.speed
+	return c.epoch.Add(time.Duration(elapsed))
+}
+
+// Function: Start
+// Description: Return the start epoch of simulation clock.
+func (c *SimClock) Start() time.Time {
+	return c.start
+}
+
+// rebase moves anchor to current wall-clock time so that speed/epoch can be changed safely.
+func (c *SimClock) rebase() {
+	c.epoch = c.now()
+	c.anchor = c.wallNow()
+}
+
+// Function: Pause
+// Description: Freeze simulated time until Resume is called.
+func (c *SimClock) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rebase()
+	c.paused = true
+}
+
+// Function: Resume
+// Description: Continue advancing simulated time from where it was paused.
+func (c *SimClock) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.anchor = c.wallNow()
+	c.paused = false
+}
+
+// Function: Step
+// Description: Advance simulated time by d, whether the clock is paused or not.
+// 1. d: Simulated duration to advance.
+func (c *SimClock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rebase()
+	c.epoch = c.epoch.Add(d)
+}
+
+// Function: SetSpeed
+// Description: Change time-scaling factor without making simulated time jump.
+// 1. speed: New time-scaling factor, non-positive value is ignored.
+func (c *SimClock) SetSpeed(speed float64) {
+	if speed <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rebase()
+	c.speed = speed
+}
+
+// Function: Status
+// Description: Return a snapshot of the clock for http response.
+func (c *SimClock) Status() ClockStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ClockStatus{
+		TimeStamp: c.now().UnixMilli(),
+		Speed:     c.speed,
+		Paused:    c.paused,
+	}
+}
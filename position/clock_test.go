@@ -0,0 +1,65 @@
+package position
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSimClock(t *testing.T) {
+	wall := time.Date(2023, 9, 18, 4, 0, 0, 0, time.UTC)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewSimClock(start, 10)
+	clock.anchor = wall
+	clock.wallNow = func() time.Time { return wall }
+
+	wall = wall.Add(time.Second)
+	if got := clock.Now(); !got.Equal(start.Add(10 * time.Second)) {
+		t.Errorf("speed: expected %v, got %v", start.Add(10*time.Second), got)
+	}
+
+	clock.Pause()
+	wall = wall.Add(time.Minute)
+	if got := clock.Now(); !got.Equal(start.Add(10 * time.Second)) {
+		t.Errorf("pause: expected %v, got %v", start.Add(10*time.Second), got)
+	}
+
+	clock.Step(5 * time.Second)
+	if got := clock.Now(); !got.Equal(start.Add(15 * time.Second)) {
+		t.Errorf("step: expected %v, got %v", start.Add(15*time.Second), got)
+	}
+
+	clock.Resume()
+	clock.SetSpeed(2)
+	wall = wall.Add(time.Second)
+	if got := clock.Now(); !got.Equal(start.Add(17 * time.Second)) {
+		t.Errorf("resume: expected %v, got %v", start.Add(17*time.Second), got)
+	}
+}
+
+func TestClockHandlerMethod(t *testing.T) {
+	ps := &PositionServer{clock: NewSimClock(time.Time{}, 1)}
+	handlers := map[string]http.HandlerFunc{
+		"/clock/pause":  ps.PauseClockHandler,
+		"/clock/resume": ps.ResumeClockHandler,
+		"/clock/step":   ps.StepClockHandler,
+		"/clock/speed":  ps.SetClockSpeedHandler,
+	}
+	for path, handler := range handlers {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("GET %s: expected %d, got %d", path, http.StatusMethodNotAllowed, rec.Code)
+		}
+	}
+	if ps.clock.Status().Paused {
+		t.Errorf("GET should not pause the clock")
+	}
+
+	rec := httptest.NewRecorder()
+	ps.PauseClockHandler(rec, httptest.NewRequest(http.MethodPost, "/clock/pause", nil))
+	if rec.Code != http.StatusOK || !ps.clock.Status().Paused {
+		t.Errorf("POST /clock/pause: expected paused clock, got code %d", rec.Code)
+	}
+}
@@ -2,29 +2,6 @@ package position
 
 import "fmt"
 
-func GetGroundStation() []GSParams {
-	return []GSParams{
-		// Shanghai Sheshan Station.
-		{
-			UUID:      "station",
-			Longitude: 121.4476,
-			Latitude:  31.1618,
-			Altitude:  0.02,
-		},
-	}
-}
-
-func GetMissiles() []MSParams {
-	return []MSParams{
-		{
-			UUID:      "missile",
-			Longitude: 122.4476,
-			Latitude:  32.1618,
-			Altitude:  10.002,
-		},
-	}
-}
-
 func GetFixedNodes(num int) []FixedParams {
 	result := make([]FixedParams, 0, num)
 	for idx := 0; idx < num; idx++ {
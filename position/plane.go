@@ -0,0 +1,118 @@
+package position
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	sdnv1 "ws/dtn-satellite-sdn/sdn/type/v1"
+)
+
+const (
+	// PlaneInclinationTolerance is the max inclination gap(degree) inside an orbital plane
+	PlaneInclinationTolerance float64 = 0.5
+
+	// PlaneSMATolerance is the max semi-major axis gap(km) inside an orbital plane
+	PlaneSMATolerance float64 = 20.0
+
+	// PlaneRAANTolerance is the max RAAN gap(degree) inside an orbital plane
+	PlaneRAANTolerance float64 = 1.0
+)
+
+type satElements struct {
+	name   string
+	incl   float64
+	raan   float64
+	argLat float64
+	sma    float64
+}
+
+// Function: ClassifyOrbitalPlanes
+// Description: Group satellites into orbital planes by clustering inclination, semi-major axis and RAAN,
+// then sort satellites in each plane by argument of latitude.
+// Return type: trackID -> []satellite's name(indexed by inTrackID)
+// 1. sats: Satellites to classify.
+// 2. t: Standard time to compute orbital elements.
+func ClassifyOrbitalPlanes(sats []sdnv1.Satellite, t time.Time) [][]string {
+	// SGP4 takes calendar fields in UTC
+	t = t.UTC()
+	year, month, day, hour, minute, second :=
+		t.Year(), int(t.Month()),
+		t.Day(), t.Hour(),
+		t.Minute(), t.Second()
+	elements := make([]satElements, 0, len(sats))
+	for _, sat := range sats {
+		incl, raan, argLat, sma := sat.OrbitalElementsAtTime(
+			year, month, day,
+			hour, minute, second,
+		)
+		elements = append(elements, satElements{
+			name:   sat.Name,
+			incl:   incl,
+			raan:   raan,
+			argLat: argLat,
+			sma:    sma,
+		})
+	}
+
+	result := [][]string{}
+	for _, inclGroup := range splitByGap(elements, func(e satElements) float64 { return e.incl }, PlaneInclinationTolerance, false) {
+		for _, shell := range splitByGap(inclGroup, func(e satElements) float64 { return e.sma }, PlaneSMATolerance, false) {
+			for _, plane := range splitByGap(shell, func(e satElements) float64 { return e.raan }, PlaneRAANTolerance, true) {
+				sort.SliceStable(plane, func(i, j int) bool {
+					return plane[i].argLat < plane[j].argLat
+				})
+				names := make([]string, 0, len(plane))
+				for _, e := range plane {
+					names = append(names, e.name)
+				}
+				result = append(result, names)
+			}
+		}
+	}
+	return result
+}
+
+// splitByGap sorts elements by key and splits them where the gap between neighbours exceeds tolerance.
+// If circular is true, key is regarded as an angle in degree and clusters may wrap around 360.
+func splitByGap(elements []satElements, key func(satElements) float64, tolerance float64, circular bool) [][]satElements {
+	if len(elements) == 0 {
+		return nil
+	}
+	sorted := make([]satElements, len(elements))
+	copy(sorted, elements)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if key(sorted[i]) == key(sorted[j]) {
+			return sorted[i].name < sorted[j].name
+		}
+		return key(sorted[i]) < key(sorted[j])
+	})
+
+	// For circular keys, start from the element after the first gap so that no cluster is cut by 0/360.
+	start := 0
+	if circular {
+		wrapGap := key(sorted[0]) + 360.0 - key(sorted[len(sorted)-1])
+		if wrapGap <= tolerance {
+			for idx := 1; idx < len(sorted); idx++ {
+				if key(sorted[idx])-key(sorted[idx-1]) > tolerance {
+					start = idx
+					break
+				}
+			}
+		}
+	}
+
+	result := [][]satElements{{sorted[start]}}
+	for cnt := 1; cnt < len(sorted); cnt++ {
+		prev, cur := sorted[(start+cnt-1)%len(sorted)], sorted[(start+cnt)%len(sorted)]
+		gap := key(cur) - key(prev)
+		if circular {
+			gap = math.Mod(gap+360.0, 360.0)
+		}
+		if gap > tolerance {
+			result = append(result, []satElements{})
+		}
+		result[len(result)-1] = append(result[len(result)-1], cur)
+	}
+	return result
+}
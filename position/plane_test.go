@@ -0,0 +1,131 @@
+package position
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sdnv1 "ws/dtn-satellite-sdn/sdn/type/v1"
+)
+
+// tleEpoch is the epoch of TLEs made by makeTLE, 2024-01-01 00:00:00 UTC
+var tleEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// tleChecksum returns the modulo-10 checksum of a TLE line.
+func tleChecksum(line string) int {
+	sum := 0
+	for _, c := range line {
+		if c >= '0' && c <= '9' {
+			sum += int(c - '0')
+		} else if c == '-' {
+			sum++
+		}
+	}
+	return sum % 10
+}
+
+// makeTLE makes a near-circular satellite at tleEpoch with argument of perigee 0,
+// so that its argument of latitude is about meanAnomaly.
+func makeTLE(t *testing.T, name string, catalog int, incl, raan, meanAnomaly float64) sdnv1.Satellite {
+	t.Helper()
+	line1 := fmt.Sprintf("1 %05dU 24001A   24001.00000000  .00000000  00000-0  00000-0 0  999", catalog)
+	line2 := fmt.Sprintf("2 %05d %8.4f %8.4f 0001000   0.0000 %8.4f 15.06000000    1", catalog, incl, raan, meanAnomaly)
+	line1 += fmt.Sprint(tleChecksum(line1))
+	line2 += fmt.Sprint(tleChecksum(line2))
+	sat, err := sdnv1.NewStatellite(name, line1, line2)
+	if err != nil {
+		t.Fatalf("make TLE of %s failed: %v", name, err)
+	}
+	return *sat
+}
+
+func TestOrbitalElementsAtTime(t *testing.T) {
+	// ISS at its TLE epoch 2008-09-20 12:25:40 UTC, whose mean elements are
+	// inclination 51.6416, RAAN 247.4627, argument of perigee 130.5360 and mean anomaly 325.0288
+	sat, err := sdnv1.NewStatellite("ISS",
+		"1 25544U 98067A   08264.51782528 -.00002182  00000-0 -11606-4 0  2927",
+		"2 25544  51.6416 247.4627 0006703 130.5360 325.0288 15.72125391563537")
+	if err != nil {
+		t.Fatalf("create satellite failed: %v", err)
+	}
+	incl, raan, argLat, sma := sat.OrbitalElementsAtTime(2008, 9, 20, 12, 25, 40)
+	// Propagated elements are osculating, so they differ slightly from the mean elements in TLE
+	tests := []struct {
+		name      string
+		got, want float64
+		tolerance float64
+	}{
+		{"inclination", incl, 51.6416, 0.1},
+		{"RAAN", raan, 247.4627, 0.1},
+		{"argument of latitude", argLat, math.Mod(130.5360+325.0288, 360), 0.5},
+		{"semi-major axis", sma, 6730, 20},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > tt.tolerance {
+			t.Errorf("%s: expected %v±%v, got %v", tt.name, tt.want, tt.tolerance, tt.got)
+		}
+	}
+}
+
+func TestClassifyOrbitalPlanes(t *testing.T) {
+	sats := []sdnv1.Satellite{
+		// Two planes in the same shell, whose satellites are listed out of order
+		makeTLE(t, "a3", 1, 53, 10, 200),
+		makeTLE(t, "b1", 2, 53, 40, 30),
+		makeTLE(t, "a1", 3, 53, 10, 20),
+		makeTLE(t, "b2", 4, 53, 40, 150),
+		makeTLE(t, "a2", 5, 53, 10, 100),
+		// A plane across RAAN 0/360
+		makeTLE(t, "c2", 6, 53, 359.8, 50),
+		makeTLE(t, "c1", 7, 53, 0.3, 10),
+		// Another shell with the same RAAN
+		makeTLE(t, "d1", 8, 97.6, 10, 0),
+	}
+	planes := ClassifyOrbitalPlanes(sats, tleEpoch)
+
+	want := map[string][]string{
+		"a": {"a1", "a2", "a3"},
+		"b": {"b1", "b2"},
+		"c": {"c1", "c2"},
+		"d": {"d1"},
+	}
+	if len(planes) != len(want) {
+		t.Fatalf("expected %d planes, got %d: %v", len(want), len(planes), planes)
+	}
+	for _, plane := range planes {
+		prefix := plane[0][:1]
+		for _, name := range plane {
+			if !strings.HasPrefix(name, prefix) {
+				t.Errorf("satellites of different planes are grouped: %v", plane)
+				break
+			}
+		}
+		if !reflect.DeepEqual(plane, want[prefix]) {
+			t.Errorf("plane %s: expected %v ordered by argument of latitude, got %v", prefix, want[prefix], plane)
+		}
+	}
+}
+
+func TestSplitByGapCircular(t *testing.T) {
+	elements := []satElements{
+		{name: "a", raan: 359.6},
+		{name: "b", raan: 0.2},
+		{name: "c", raan: 120.1},
+		{name: "d", raan: 119.8},
+		{name: "e", raan: 240.0},
+	}
+	planes := splitByGap(elements, func(e satElements) float64 { return e.raan }, PlaneRAANTolerance, true)
+	if len(planes) != 3 {
+		t.Fatalf("expected 3 planes, got %d: %v", len(planes), planes)
+	}
+	for _, plane := range planes {
+		if plane[0].name == "a" || plane[0].name == "b" {
+			if len(plane) != 2 {
+				t.Errorf("plane across 0/360 is split: %v", plane)
+			}
+		}
+	}
+}
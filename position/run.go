@@ -2,11 +2,13 @@ package position
 
 import (
 	"encoding/json"
-	"math"
+	"fmt"
 	"net/http"
-	"time"
+	"strconv"
 	"sync"
+	"time"
 
+	"ws/dtn-satellite-sdn/sdn/filter"
 	sdnv1 "ws/dtn-satellite-sdn/sdn/type/v1"
 
 	"github.com/sirupsen/logrus"
@@ -14,6 +16,11 @@ import (
 
 type PositionServerInterface interface {
 	GetLocationHanlder(http.ResponseWriter, *http.Request)
+	GetClockHandler(http.ResponseWriter, *http.Request)
+	PauseClockHandler(http.ResponseWriter, *http.Request)
+	ResumeClockHandler(http.ResponseWriter, *http.Request)
+	StepClockHandler(http.ResponseWriter, *http.Request)
+	SetClockSpeedHandler(http.ResponseWriter, *http.Request)
 	Init()
 	Update() error
 }
@@ -23,6 +30,16 @@ type PositionServer struct {
 	cache     *PositionCache
 	fixedNum  int
 	timeStamp time.Time
+	clock     *SimClock
+
+	// lock guards cache and timeStamp, which are written by Update while predictions read the cache
+	lock sync.RWMutex
+
+	// trajectories stores node's uuid -> trajectory of mobile nodes
+	trajectories map[string]*Trajectory
+
+	// filter decides which satellites are kept in constellation, nil means keeping all satellites
+	filter *filter.SatelliteFilter
 }
 
 type PositionCache struct {
@@ -32,15 +49,14 @@ type PositionCache struct {
 	fixedCache []FixedParams
 }
 
-const (
-	MaxOrbitSize int = 10
-)
-
-func NewPositionServer(inputPath string, num int, maxNum int) *PositionServer {
+func NewPositionServer(inputPath string, scenarioPath string, num int, maxNum int, clock *SimClock, filter *filter.SatelliteFilter) *PositionServer {
 	logger := logrus.WithFields(logrus.Fields{
 		"input-path": 		  inputPath,
+		"scenario-path":      scenarioPath,
 		"fixed-num":  		  num,
 		"max-satellites-num": maxNum,
+		"start-time":         clock.Now(),
+		"speed":              clock.Status().Speed,
 	})
 	logger.Info("initializing position server...")
 	if constellation, err := sdnv1.NewConstellation(inputPath); err != nil {
@@ -51,16 +67,31 @@ func NewPositionServer(inputPath string, num int, maxNum int) *PositionServer {
 		if satelliteNum > maxNum {
 			constellation.Satellites = constellation.Satellites[:maxNum]
 		}
+		// Without scenario file, there are only num generated fixed nodes besides satellites
+		scenario, fixedNodes := DefaultScenario(), GetFixedNodes(num)
+		if scenarioPath != "" {
+			var err error
+			if scenario, err = LoadScenario(scenarioPath); err != nil {
+				logrus.WithError(err).Panic("load scenario failed")
+				return nil
+			}
+			fixedNodes = scenario.GetFixedNodes()
+		}
+		cache := &PositionCache{
+			satCache:   make(map[string]*SatParams),
+			msCache:    scenario.GetMissiles(),
+			gsCache:    scenario.GetGroundStation(),
+			fixedCache: fixedNodes,
+		}
+		trajectories := scenario.GetTrajectories()
 		ps := PositionServer{
 			c:        constellation,
-			cache:    &PositionCache{
-				satCache:   make(map[string]*SatParams),
-				msCache:    GetMissiles(),
-				gsCache:    GetGroundStation(),
-				fixedCache: GetFixedNodes(num),
-			},
+			cache:    cache,
+			trajectories: trajectories,
+			filter:   filter,
 			fixedNum: num,
-			timeStamp: time.Now(),
+			timeStamp: clock.Now(),
+			clock:    clock,
 		}
 		ps.Init()
 		logger.Info("position server has been initailized")
@@ -70,40 +101,195 @@ func NewPositionServer(inputPath string, num int, maxNum int) *PositionServer {
 
 // Function: GetLocationHanlder
 // Description: A http hanlder for getting location of all types of node.
+// Locations at a given time(unix milliseconds) are predicted if `time` is specified, which are not cached.
 func (ps *PositionServer) GetLocationHandler(w http.ResponseWriter, req *http.Request) {
-	ps.timeStamp = time.Now()
-	if err := ps.Update(); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(err.Error()))
-		logrus.WithError(err).Error("update cache failed.")
+	if timeParam := req.URL.Query().Get("time"); timeParam != "" {
+		unixMilli, err := strconv.ParseInt(timeParam, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("invalid time: %v", err)))
+			return
+		}
+		retParams := ps.PredictLocation(time.UnixMilli(unixMilli))
+		content, _ := json.Marshal(&retParams)
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+		return
 	}
+	ps.lock.Lock()
+	ps.timeStamp = ps.clock.Now()
+	err := ps.Update()
+	// Locations are copied, so that they are marshalled after the lock is released
 	retParams := RetParams{
 		TimeStamp:  ps.timeStamp.UnixMilli(),
 		Satellites: []SatParams{},
-		// Missiles:   ps.cache.msCache,
-		// Stations:   ps.cache.gsCache,
-		FixedNodes: ps.cache.fixedCache,
+		Missiles:   append([]MSParams{}, ps.cache.msCache...),
+		Stations:   append([]GSParams{}, ps.cache.gsCache...),
+		FixedNodes: append([]FixedParams{}, ps.cache.fixedCache...),
 	}
 	for _, sat := range ps.cache.satCache {
 		retParams.Satellites = append(retParams.Satellites, *sat)
 	}
+	ps.lock.Unlock()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		logrus.WithError(err).Error("update cache failed.")
+	}
 	logrus.Debugf("return value is %v", retParams)
 	content, _ := json.Marshal(&retParams)
 	w.WriteHeader(http.StatusOK)
 	w.Write(content)
 }
 
+// Function: PredictLocation
+// Description: Return locations of all types of node at given time without updating cache.
+// Satellites are propagated from TLE and mobile nodes move along their trajectories.
+// 1. t: The time to predict locations at.
+func (ps *PositionServer) PredictLocation(t time.Time) RetParams {
+	// SGP4 takes calendar fields in UTC
+	utc := t.UTC()
+	year, month, day, hour, minute, second :=
+		utc.Year(), int(utc.Month()),
+		utc.Day(), utc.Hour(),
+		utc.Minute(), utc.Second()
+	// Cached params are copied under the lock, and locations are predicted on the copies
+	ps.lock.RLock()
+	retParams := RetParams{
+		TimeStamp:  t.UnixMilli(),
+		Satellites: make([]SatParams, 0, len(ps.c.Satellites)),
+		Missiles:   append([]MSParams{}, ps.cache.msCache...),
+		Stations:   append([]GSParams{}, ps.cache.gsCache...),
+		FixedNodes: append([]FixedParams{}, ps.cache.fixedCache...),
+	}
+	cachedSats := make(map[string]SatParams, len(ps.cache.satCache))
+	for name, cached := range ps.cache.satCache {
+		cachedSats[name] = *cached
+	}
+	ps.lock.RUnlock()
+	for _, sat := range ps.c.Satellites {
+		params, ok := cachedSats[sat.Name]
+		if !ok {
+			continue
+		}
+		params.Longitude, params.Latitude, params.Altitude = sat.LocationAtTime(
+			year, month, day,
+			hour, minute, second,
+		)
+		retParams.Satellites = append(retParams.Satellites, params)
+	}
+	elapsed := t.Sub(ps.clock.Start()).Seconds()
+	for idx, node := range retParams.Stations {
+		if trajectory, ok := ps.trajectories[node.UUID]; ok {
+			retParams.Stations[idx].Longitude, retParams.Stations[idx].Latitude, retParams.Stations[idx].Altitude =
+				trajectory.LocationAt(elapsed)
+		}
+	}
+	for idx, node := range retParams.Missiles {
+		if trajectory, ok := ps.trajectories[node.UUID]; ok {
+			retParams.Missiles[idx].Longitude, retParams.Missiles[idx].Latitude, retParams.Missiles[idx].Altitude =
+				trajectory.LocationAt(elapsed)
+		}
+	}
+	for idx, node := range retParams.FixedNodes {
+		if trajectory, ok := ps.trajectories[node.UUID]; ok {
+			retParams.FixedNodes[idx].Longitude, retParams.FixedNodes[idx].Latitude, retParams.FixedNodes[idx].Altitude =
+				trajectory.LocationAt(elapsed)
+		}
+	}
+	return retParams
+}
+
+// Function: GetClockHandler
+// Description: A http handler for getting the status of simulation clock.
+func (ps *PositionServer) GetClockHandler(w http.ResponseWriter, req *http.Request) {
+	writeClockStatus(w, ps.clock.Status())
+}
+
+// Function: PauseClockHandler
+// Description: A http handler for pausing simulation clock, which accepts POST only.
+func (ps *PositionServer) PauseClockHandler(w http.ResponseWriter, req *http.Request) {
+	if !allowPost(w, req) {
+		return
+	}
+	ps.clock.Pause()
+	logrus.WithField("time", ps.clock.Now()).Info("simulation clock paused")
+	writeClockStatus(w, ps.clock.Status())
+}
+
+// Function: ResumeClockHandler
+// Description: A http handler for resuming simulation clock, which accepts POST only.
+func (ps *PositionServer) ResumeClockHandler(w http.ResponseWriter, req *http.Request) {
+	if !allowPost(w, req) {
+		return
+	}
+	ps.clock.Resume()
+	logrus.WithField("time", ps.clock.Now()).Info("simulation clock resumed")
+	writeClockStatus(w, ps.clock.Status())
+}
+
+// Function: StepClockHandler
+// Description: A http handler for advancing simulation clock by `seconds`, which accepts POST only.
+func (ps *PositionServer) StepClockHandler(w http.ResponseWriter, req *http.Request) {
+	if !allowPost(w, req) {
+		return
+	}
+	seconds, err := strconv.ParseFloat(req.URL.Query().Get("seconds"), 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("invalid seconds: %v", err)))
+		return
+	}
+	ps.clock.Step(time.Duration(seconds * float64(time.Second)))
+	logrus.WithField("time", ps.clock.Now()).Info("simulation clock stepped")
+	writeClockStatus(w, ps.clock.Status())
+}
+
+// Function: SetClockSpeedHandler
+// Description: A http handler for changing time-scaling factor of simulation clock, which accepts POST only.
+func (ps *PositionServer) SetClockSpeedHandler(w http.ResponseWriter, req *http.Request) {
+	if !allowPost(w, req) {
+		return
+	}
+	speed, err := strconv.ParseFloat(req.URL.Query().Get("speed"), 64)
+	if err != nil || speed <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("invalid speed: %s", req.URL.Query().Get("speed"))))
+		return
+	}
+	ps.clock.SetSpeed(speed)
+	logrus.WithField("speed", speed).Info("simulation clock speed changed")
+	writeClockStatus(w, ps.clock.Status())
+}
+
+// allowPost returns whether req is POST, otherwise responds 405 since the request would change state.
+func allowPost(w http.ResponseWriter, req *http.Request) bool {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+func writeClockStatus(w http.ResponseWriter, status ClockStatus) {
+	content, _ := json.Marshal(&status)
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
+
 // Function: Update
-// Description: Update cache in ps.cache for future use.
+// Description: Update cache in ps.cache for future use. The caller should hold ps.lock.
 func (ps *PositionServer) Update() error {
 	// Update longitude, latitude, altitude in Satellites
+	// SGP4 takes calendar fields in UTC
+	utc := ps.timeStamp.UTC()
 	year, month, day, hour, minute, second :=
-		ps.timeStamp.Year(),
-		int(ps.timeStamp.Month()),
-		ps.timeStamp.Day(),
-		ps.timeStamp.Hour(),
-		ps.timeStamp.Minute(),
-		ps.timeStamp.Second()
+		utc.Year(),
+		int(utc.Month()),
+		utc.Day(),
+		utc.Hour(),
+		utc.Minute(),
+		utc.Second()
 	logrus.Info("update longitude, latitude, altitude in cache")
 	
 	var wg sync.WaitGroup
@@ -121,20 +307,52 @@ func (ps *PositionServer) Update() error {
 		}(sat)
 	}
 	wg.Wait()
+
+	// Update longitude, latitude, altitude of mobile nodes
+	ps.UpdateMobileNodes()
 	return nil
 }
 
+// Function: UpdateMobileNodes
+// Description: Move nodes with trajectory to where they should be at ps.timeStamp. The caller should hold ps.lock.
+func (ps *PositionServer) UpdateMobileNodes() {
+	if len(ps.trajectories) == 0 {
+		return
+	}
+	elapsed := ps.timeStamp.Sub(ps.clock.Start()).Seconds()
+	for idx, node := range ps.cache.gsCache {
+		if trajectory, ok := ps.trajectories[node.UUID]; ok {
+			ps.cache.gsCache[idx].Longitude, ps.cache.gsCache[idx].Latitude, ps.cache.gsCache[idx].Altitude =
+				trajectory.LocationAt(elapsed)
+		}
+	}
+	for idx, node := range ps.cache.msCache {
+		if trajectory, ok := ps.trajectories[node.UUID]; ok {
+			ps.cache.msCache[idx].Longitude, ps.cache.msCache[idx].Latitude, ps.cache.msCache[idx].Altitude =
+				trajectory.LocationAt(elapsed)
+		}
+	}
+	for idx, node := range ps.cache.fixedCache {
+		if trajectory, ok := ps.trajectories[node.UUID]; ok {
+			ps.cache.fixedCache[idx].Longitude, ps.cache.fixedCache[idx].Latitude, ps.cache.fixedCache[idx].Altitude =
+				trajectory.LocationAt(elapsed)
+		}
+	}
+}
+
 // Function: Init
 // Description: Compute all of sats' information when cache is recently created.
 func (ps *PositionServer) Init() {
 	// Initialze satCache
+	// SGP4 takes calendar fields in UTC
+	utc := ps.timeStamp.UTC()
 	year, month, day, hour, minute, second :=
-		ps.timeStamp.Year(),
-		int(ps.timeStamp.Month()),
-		ps.timeStamp.Day(),
-		ps.timeStamp.Hour(),
-		ps.timeStamp.Minute(),
-		ps.timeStamp.Second()
+		utc.Year(),
+		int(utc.Month()),
+		utc.Day(),
+		utc.Hour(),
+		utc.Minute(),
+		utc.Second()
 	for _, sat := range ps.c.Satellites {
 		long, lat, alt := sat.LocationAtTime(
 			year, month, day,
@@ -148,74 +366,77 @@ func (ps *PositionServer) Init() {
 		}
 		ps.cache.satCache[sat.Name] = &s
 	}
-	// Classify Satellites
-	curTrackID, remainNode := 0, len(ps.cache.satCache)
-	classifySatsUUIDList := [][]string{}
-	visited := make(map[string]bool, remainNode)
-	for k := range ps.cache.satCache {
-		visited[k] = false
-	}
-	for remainNode > 0 {
-		var standardHeight float64
-		// Find standard height for this track
-		for key, sat := range ps.cache.satCache {
-			if !visited[key] {
-				standardHeight = sat.Altitude
-				break
-			}
+	ps.UpdateMobileNodes()
+	// Classify Satellites into orbital planes, in which satellites are sorted by argument of latitude
+	classifySatsUUIDList := ClassifyOrbitalPlanes(ps.c.Satellites, ps.timeStamp)
+	inclinations := map[string]float64{}
+	if ps.filter != nil {
+		for _, sat := range ps.c.Satellites {
+			inclinations[sat.Name], _, _, _ = sat.OrbitalElementsAtTime(year, month, day, hour, minute, second)
 		}
-		// Iterate to find sats in the same track(|height - standardHeight| < 500)
-		curOrbitSize := 0
-		for key, sat := range ps.cache.satCache {
-			if !visited[key] && math.Abs(sat.Altitude-standardHeight) < 500 {
-				if len(classifySatsUUIDList) <= curTrackID {
-					classifySatsUUIDList = append(classifySatsUUIDList, []string{})
-				}
-				classifySatsUUIDList[curTrackID] = append(classifySatsUUIDList[curTrackID], key) // Update result
-				visited[key] = true                                                              // Mark as visited
-				remainNode--
-				curOrbitSize++
-				if curOrbitSize >= MaxOrbitSize {
-					curTrackID++
-					curOrbitSize = 0
-				}
+	}
+	// Satellites rejected by filter are removed from cache, and the tracks rule of filter matches plane's order
+	removed, planes := []string{}, [][]string{}
+	for planeID, keyGroup := range classifySatsUUIDList {
+		plane := []string{}
+		for _, key := range keyGroup {
+			if !ps.filter.Match(filter.FilterTarget{
+				UUID:        key,
+				Altitude:    ps.cache.satCache[key].Altitude,
+				Inclination: inclinations[key],
+				TrackID:     planeID,
+			}) {
+				delete(ps.cache.satCache, key)
+				removed = append(removed, key)
+				continue
 			}
+			plane = append(plane, key)
 		}
-		curTrackID++
-	}
-	// Bubble sort satellites according to Angle to get inTrackID
-	for trackID, keyGroup := range classifySatsUUIDList {
-		for idx1 := 0; idx1 < len(keyGroup)-1; idx1++ {
-			for idx2 := 0; idx2 < len(keyGroup)-1-idx1; idx2++ {
-				sat1, _ := ps.c.FindSatelliteByName(keyGroup[idx2])
-				sat2, _ := ps.c.FindSatelliteByName(keyGroup[idx2+1])
-				if sat1.AngleDeltaAtTime(sat2, year, month, day, hour, minute, second) > 0 {
-					keyGroup[idx2], keyGroup[idx2+1] = keyGroup[idx2+1], keyGroup[idx2]
-				}
-			}
+		// Planes emptied by filter are dropped, so that there is no track without satellites
+		if len(plane) > 0 {
+			planes = append(planes, plane)
 		}
-		// Assign TrackID and InTrackID
-
-		for inTrackID, key := range keyGroup {
+	}
+	// Assign TrackID and InTrackID
+	for trackID, plane := range planes {
+		for inTrackID, key := range plane {
 			ps.cache.satCache[key].TrackID = trackID
 			ps.cache.satCache[key].InTrackID = inTrackID
 		}
 	}
-	
-	logrus.WithField("group-num", len(classifySatsUUIDList)).Debug(classifySatsUUIDList)
+	if len(removed) > 0 {
+		satellites := make([]sdnv1.Satellite, 0, len(ps.cache.satCache))
+		for _, sat := range ps.c.Satellites {
+			if _, ok := ps.cache.satCache[sat.Name]; ok {
+				satellites = append(satellites, sat)
+			}
+		}
+		ps.c.Satellites = satellites
+		logrus.WithField("satellites", removed).Info("satellites are filtered out")
+	}
+
+	logrus.WithField("group-num", len(planes)).Debug(planes)
 }
 
 // Function: RunPositionModule
 // Description: Start Position Computing Module.
 // 1. inputPath: TLE file's path.
-// 2. fixedNum: The number of fixed network pod expected to generate.
-// 3. maxNum: The max number of satellites.
-func RunPositionModule(inputPath string, fixedNum int, maxNum int) {
+// 2. scenarioPath: Scenario file's path, which declares stations, users and missiles(empty means no scenario).
+// 3. fixedNum: The number of fixed network pod expected to generate(ignored if scenario is specified).
+// 4. maxNum: The max number of satellites.
+// 5. clock: The simulation clock which all of timestamps are drawn from.
+// 6. filter: The satellite filter, nil means keeping all satellites.
+func RunPositionModule(inputPath string, scenarioPath string, fixedNum int, maxNum int, clock *SimClock, filter *filter.SatelliteFilter) {
 	// Construct Constellation from file
-	ps := NewPositionServer(inputPath, fixedNum, maxNum)
+	ps := NewPositionServer(inputPath, scenarioPath, fixedNum, maxNum, clock, filter)
 
 	// Bind handler and start server
 	http.HandleFunc("/location", ps.GetLocationHandler)
+	http.HandleFunc("/clock", ps.GetClockHandler)
+	http.HandleFunc("/clock/pause", ps.PauseClockHandler)
+	http.HandleFunc("/clock/resume", ps.ResumeClockHandler)
+	http.HandleFunc("/clock/step", ps.StepClockHandler)
+	http.HandleFunc("/clock/speed", ps.SetClockSpeedHandler)
 	http.ListenAndServe(":30100", nil)
 
 }
@@ -0,0 +1,40 @@
+package position
+
+import (
+	"testing"
+
+	"ws/dtn-satellite-sdn/sdn/filter"
+	sdnv1 "ws/dtn-satellite-sdn/sdn/type/v1"
+)
+
+func TestInitDropsFilteredPlanes(t *testing.T) {
+	// The middle plane is removed by filter, and the last plane takes its trackID
+	satFilter := &filter.SatelliteFilter{Exclude: []filter.FilterRule{{UUIDs: []string{"b1", "b2"}}}}
+	if err := satFilter.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	ps := PositionServer{
+		c: &sdnv1.Constellation{Satellites: []sdnv1.Satellite{
+			makeTLE(t, "a1", 1, 53, 10, 20),
+			makeTLE(t, "b1", 2, 53, 40, 20),
+			makeTLE(t, "b2", 3, 53, 40, 60),
+			makeTLE(t, "c1", 4, 53, 70, 20),
+			makeTLE(t, "c2", 5, 53, 70, 60),
+		}},
+		cache:     &PositionCache{satCache: make(map[string]*SatParams)},
+		timeStamp: tleEpoch,
+		filter:    satFilter,
+	}
+	ps.Init()
+
+	if len(ps.c.Satellites) != 3 || len(ps.cache.satCache) != 3 {
+		t.Fatalf("b1 and b2 should be removed, got %v", ps.cache.satCache)
+	}
+	expected := map[string][2]int{"a1": {0, 0}, "c1": {1, 0}, "c2": {1, 1}}
+	for name, ids := range expected {
+		sat := ps.cache.satCache[name]
+		if sat == nil || sat.TrackID != ids[0] || sat.InTrackID != ids[1] {
+			t.Errorf("%s: expected trackID %d and inTrackID %d, got %+v", name, ids[0], ids[1], sat)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package position
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Scenario describes non-satellite nodes of an experiment.
+// It can be written in YAML or JSON, e.g.
+//
+//	stations:
+//	  - name: sheshan
+//	    lon: 121.4476
+//	    lat: 31.1618
+//	    height: 0.02
+//	    minElevation: 25
+//	    maxAccess: 2
+//	users:
+//	  - name: user0
+//	    lon: 121.5
+//	    lat: 31.2
+//	    height: 0
+//	missiles:
+//	  - name: missile
+//	    lon: 122.4476
+//	    lat: 32.1618
+//	    height: 10.002
+//	    trajectory:
+//	      route:
+//	        speed: 900
+//	        points:
+//	          - {lon: 122.4476, lat: 32.1618, height: 10.002}
+//	          - {lon: 139.6917, lat: 35.6895, height: 10.002}
+type Scenario struct {
+	Stations []ScenarioNode `json:"stations"`
+	Users    []ScenarioNode `json:"users"`
+	Missiles []ScenarioNode `json:"missiles"`
+}
+
+type ScenarioNode struct {
+	Name      string  `json:"name"`
+	Longitude float64 `json:"lon"`
+	Latitude  float64 `json:"lat"`
+	Altitude  float64 `json:"height"`
+
+	// MinElevation is the elevation mask(degree), nil means using SDN server's default
+	MinElevation *float64 `json:"minElevation,omitempty"`
+
+	// MaxAccess is the max number of satellites accessed simultaneously, 0 means using SDN server's default
+	MaxAccess int `json:"maxAccess,omitempty"`
+
+	// Trajectory makes the node mobile, nil means the node is static
+	Trajectory *Trajectory `json:"trajectory,omitempty"`
+}
+
+// defaultScenario is used when no scenario file is specified, which declares no node
+//
+//go:embed default_scenario.yaml
+var defaultScenario []byte
+
+// Function: LoadScenario
+// Description: Load scenario from a YAML/JSON file.
+// 1. filePath: The scenario file's path.
+func LoadScenario(filePath string) (*Scenario, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario file %s failed: %v", filePath, err)
+	}
+	return parseScenario(content, filePath)
+}
+
+// Function: DefaultScenario
+// Description: Return the default scenario, which declares no station, missile or user.
+func DefaultScenario() *Scenario {
+	scenario, err := parseScenario(defaultScenario, "default_scenario.yaml")
+	if err != nil {
+		panic(err)
+	}
+	return scenario
+}
+
+func parseScenario(content []byte, name string) (*Scenario, error) {
+	scenario := Scenario{}
+	if err := yaml.UnmarshalStrict(content, &scenario); err != nil {
+		return nil, fmt.Errorf("parse scenario file %s failed: %v", name, err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario file %s: %v", name, err)
+	}
+	return &scenario, nil
+}
+
+// Function: Validate
+// Description: Check that every node has a unique name, valid coordinates and valid trajectory.
+func (s *Scenario) Validate() error {
+	names := map[string]bool{}
+	for _, nodes := range [][]ScenarioNode{s.Stations, s.Users, s.Missiles} {
+		for _, node := range nodes {
+			if node.Name == "" {
+				return fmt.Errorf("node name is empty")
+			}
+			if names[node.Name] {
+				return fmt.Errorf("duplicated node name %s", node.Name)
+			}
+			names[node.Name] = true
+			if node.Latitude < -90 || node.Latitude > 90 {
+				return fmt.Errorf("latitude of %s is out of range: %v", node.Name, node.Latitude)
+			}
+			if node.Longitude < -180 || node.Longitude > 180 {
+				return fmt.Errorf("longitude of %s is out of range: %v", node.Name, node.Longitude)
+			}
+			if node.MinElevation != nil && (*node.MinElevation < -90 || *node.MinElevation > 90) {
+				return fmt.Errorf("min elevation of %s is out of range: %v", node.Name, *node.MinElevation)
+			}
+			if node.MaxAccess < 0 {
+				return fmt.Errorf("max access of %s should not be negative: %v", node.Name, node.MaxAccess)
+			}
+			if node.Trajectory != nil {
+				if err := node.Trajectory.Compile(); err != nil {
+					return fmt.Errorf("invalid trajectory of %s: %v", node.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Function: GetTrajectories
+// Description: Return node's name -> trajectory map of mobile nodes declared in scenario.
+func (s *Scenario) GetTrajectories() map[string]*Trajectory {
+	result := map[string]*Trajectory{}
+	for _, nodes := range [][]ScenarioNode{s.Stations, s.Users, s.Missiles} {
+		for _, node := range nodes {
+			if node.Trajectory != nil {
+				result[node.Name] = node.Trajectory
+			}
+		}
+	}
+	return result
+}
+
+// Function: GetGroundStation
+// Description: Return ground stations declared in scenario.
+func (s *Scenario) GetGroundStation() []GSParams {
+	result := make([]GSParams, 0, len(s.Stations))
+	for _, node := range s.Stations {
+		result = append(result, GSParams{
+			UUID:         node.Name,
+			Longitude:    node.Longitude,
+			Latitude:     node.Latitude,
+			Altitude:     node.Altitude,
+			MinElevation: node.MinElevation,
+			MaxAccess:    node.MaxAccess,
+		})
+	}
+	return result
+}
+
+// Function: GetMissiles
+// Description: Return missiles declared in scenario.
+func (s *Scenario) GetMissiles() []MSParams {
+	result := make([]MSParams, 0, len(s.Missiles))
+	for _, node := range s.Missiles {
+		result = append(result, MSParams{
+			UUID:         node.Name,
+			Longitude:    node.Longitude,
+			Latitude:     node.Latitude,
+			Altitude:     node.Altitude,
+			MinElevation: node.MinElevation,
+			MaxAccess:    node.MaxAccess,
+		})
+	}
+	return result
+}
+
+// Function: GetFixedNodes
+// Description: Return user terminals declared in scenario.
+func (s *Scenario) GetFixedNodes() []FixedParams {
+	result := make([]FixedParams, 0, len(s.Users))
+	for _, node := range s.Users {
+		result = append(result, FixedParams{
+			UUID:         node.Name,
+			Longitude:    node.Longitude,
+			Latitude:     node.Latitude,
+			Altitude:     node.Altitude,
+			MinElevation: node.MinElevation,
+			MaxAccess:    node.MaxAccess,
+		})
+	}
+	return result
+}
@@ -0,0 +1,25 @@
+package position
+
+import (
+	"testing"
+)
+
+func TestLoadScenario(t *testing.T) {
+	scenario, err := LoadScenario("../sdn/data/scenario.yaml")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(scenario.GetGroundStation()) != 1 || len(scenario.GetMissiles()) != 1 || len(scenario.GetFixedNodes()) != 1 {
+		t.Errorf("unexpected scenario: %v", scenario)
+	}
+	if station := scenario.GetGroundStation()[0]; station.UUID != "station" || station.Latitude != 31.1618 {
+		t.Errorf("unexpected station: %v", station)
+	}
+}
+
+func TestDefaultScenario(t *testing.T) {
+	scenario := DefaultScenario()
+	if len(scenario.GetGroundStation()) != 0 || len(scenario.GetMissiles()) != 0 || len(scenario.GetFixedNodes()) != 0 || len(scenario.GetTrajectories()) != 0 {
+		t.Errorf("Expect no node in default scenario, got %v", scenario)
+	}
+}
@@ -0,0 +1,145 @@
+package position
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// EarthRadius is the mean radius of the Earth(km) used for great-circle distance
+	EarthRadius float64 = 6371.0
+)
+
+// Trajectory describes how a mobile node moves with simulated time.
+// Either Waypoints or Route should be specified.
+type Trajectory struct {
+	// Waypoints are timed positions, between which the node moves along great circle
+	Waypoints []Waypoint `json:"waypoints,omitempty"`
+
+	// Route is a great-circle route travelled at constant speed
+	Route *Route `json:"route,omitempty"`
+
+	// Loop means that the node restarts the trajectory after reaching the last waypoint
+	Loop bool `json:"loop,omitempty"`
+}
+
+type Waypoint struct {
+	// Offset is the number of seconds since the start epoch of simulation clock
+	Offset    float64 `json:"offset"`
+	Longitude float64 `json:"lon"`
+	Latitude  float64 `json:"lat"`
+	Altitude  float64 `json:"height"`
+}
+
+type Route struct {
+	// Start is the number of seconds since the start epoch of simulation clock when the node departs
+	Start float64 `json:"start"`
+
+	// Speed is the ground speed in km/h
+	Speed float64 `json:"speed"`
+
+	// Points are the positions the node passes by in order
+	Points []RoutePoint `json:"points"`
+}
+
+type RoutePoint struct {
+	Longitude float64 `json:"lon"`
+	Latitude  float64 `json:"lat"`
+	Altitude  float64 `json:"height"`
+}
+
+// Function: Compile
+// Description: Validate trajectory and convert Route into timed waypoints.
+func (t *Trajectory) Compile() error {
+	if t.Route != nil {
+		if len(t.Waypoints) > 0 {
+			return fmt.Errorf("waypoints and route should not be both specified")
+		}
+		if t.Route.Speed <= 0 {
+			return fmt.Errorf("route speed should be positive, got %v", t.Route.Speed)
+		}
+		if len(t.Route.Points) < 2 {
+			return fmt.Errorf("route should have at least 2 points")
+		}
+		offset := t.Route.Start
+		for idx, point := range t.Route.Points {
+			if idx > 0 {
+				prev := t.Route.Points[idx-1]
+				distance := EarthRadius * centralAngle(prev.Longitude, prev.Latitude, point.Longitude, point.Latitude)
+				offset += distance / t.Route.Speed * 3600.0
+			}
+			t.Waypoints = append(t.Waypoints, Waypoint{
+				Offset:    offset,
+				Longitude: point.Longitude,
+				Latitude:  point.Latitude,
+				Altitude:  point.Altitude,
+			})
+		}
+		t.Route = nil
+	}
+	if len(t.Waypoints) == 0 {
+		return fmt.Errorf("trajectory has no waypoint")
+	}
+	for idx := 1; idx < len(t.Waypoints); idx++ {
+		if t.Waypoints[idx].Offset <= t.Waypoints[idx-1].Offset {
+			return fmt.Errorf("offsets of waypoints should be strictly increasing")
+		}
+	}
+	return nil
+}
+
+// Function: LocationAt
+// Description: Return the location of node expressed by longitude/latitude/altitude at given time.
+// 1. elapsed: The number of seconds since the start epoch of simulation clock.
+func (t *Trajectory) LocationAt(elapsed float64) (long, lat, alt float64) {
+	first, last := t.Waypoints[0], t.Waypoints[len(t.Waypoints)-1]
+	if t.Loop && last.Offset > first.Offset && elapsed > last.Offset {
+		period := last.Offset - first.Offset
+		elapsed = first.Offset + math.Mod(elapsed-first.Offset, period)
+	}
+	if elapsed <= first.Offset {
+		return first.Longitude, first.Latitude, first.Altitude
+	}
+	if elapsed >= last.Offset {
+		return last.Longitude, last.Latitude, last.Altitude
+	}
+	// Find the segment and interpolate along great circle
+	idx := 1
+	for t.Waypoints[idx].Offset < elapsed {
+		idx++
+	}
+	from, to := t.Waypoints[idx-1], t.Waypoints[idx]
+	ratio := (elapsed - from.Offset) / (to.Offset - from.Offset)
+	long, lat = interpolateGreatCircle(from.Longitude, from.Latitude, to.Longitude, to.Latitude, ratio)
+	alt = from.Altitude + (to.Altitude-from.Altitude)*ratio
+	return
+}
+
+// centralAngle returns the angle(rad) between two points on the sphere.
+func centralAngle(long1, lat1, long2, lat2 float64) float64 {
+	x1, y1, z1 := unitVector(long1, lat1)
+	x2, y2, z2 := unitVector(long2, lat2)
+	dot := math.Max(-1, math.Min(1, x1*x2+y1*y2+z1*z2))
+	return math.Acos(dot)
+}
+
+// interpolateGreatCircle returns the point at ratio of the great-circle arc between two points.
+func interpolateGreatCircle(long1, lat1, long2, lat2, ratio float64) (long, lat float64) {
+	angle := centralAngle(long1, lat1, long2, lat2)
+	if angle < 1e-12 {
+		return long1, lat1
+	}
+	x1, y1, z1 := unitVector(long1, lat1)
+	x2, y2, z2 := unitVector(long2, lat2)
+	a := math.Sin((1-ratio)*angle) / math.Sin(angle)
+	b := math.Sin(ratio*angle) / math.Sin(angle)
+	x, y, z := a*x1+b*x2, a*y1+b*y2, a*z1+b*z2
+	lat = math.Atan2(z, math.Hypot(x, y)) * 180.0 / math.Pi
+	long = math.Atan2(y, x) * 180.0 / math.Pi
+	return
+}
+
+func unitVector(long, lat float64) (x, y, z float64) {
+	longRad, latRad := long*math.Pi/180.0, lat*math.Pi/180.0
+	return math.Cos(latRad) * math.Cos(longRad), math.Cos(latRad) * math.Sin(longRad), math.Sin(latRad)
+}
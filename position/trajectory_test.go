@@ -0,0 +1,33 @@
+package position
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTrajectoryLocationAt(t *testing.T) {
+	trajectory := Trajectory{
+		Route: &Route{
+			Speed: EarthRadius * math.Pi / 2,
+			Points: []RoutePoint{
+				{Longitude: 0, Latitude: 0, Altitude: 0},
+				{Longitude: 90, Latitude: 0, Altitude: 10},
+			},
+		},
+	}
+	if err := trajectory.Compile(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	// A quarter of great circle takes one hour
+	if last := trajectory.Waypoints[1].Offset; math.Abs(last-3600) > 1e-6 {
+		t.Errorf("expected arrival at 3600s, got %v", last)
+	}
+	long, lat, alt := trajectory.LocationAt(1800)
+	if math.Abs(long-45) > 1e-6 || math.Abs(lat) > 1e-6 || math.Abs(alt-5) > 1e-6 {
+		t.Errorf("expected (45, 0, 5), got (%v, %v, %v)", long, lat, alt)
+	}
+	long, _, _ = trajectory.LocationAt(7200)
+	if math.Abs(long-90) > 1e-6 {
+		t.Errorf("expected to stay at the last waypoint, got %v", long)
+	}
+}
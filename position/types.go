@@ -19,22 +19,28 @@ type SatParams struct {
 }
 
 type GSParams struct {
-	UUID      string  `json:"uuid"`
-	Longitude float64 `json:"lon"`
-	Latitude  float64 `json:"lat"`
-	Altitude  float64 `json:"height"`
+	UUID         string   `json:"uuid"`
+	Longitude    float64  `json:"lon"`
+	Latitude     float64  `json:"lat"`
+	Altitude     float64  `json:"height"`
+	MinElevation *float64 `json:"minElevation,omitempty"`
+	MaxAccess    int      `json:"maxAccess,omitempty"`
 }
 
 type FixedParams struct {
-	UUID      string  `json:"uuid"`
-	Longitude float64 `json:"lon"`
-	Latitude  float64 `json:"lat"`
-	Altitude  float64 `json:"height"`
+	UUID         string   `json:"uuid"`
+	Longitude    float64  `json:"lon"`
+	Latitude     float64  `json:"lat"`
+	Altitude     float64  `json:"height"`
+	MinElevation *float64 `json:"minElevation,omitempty"`
+	MaxAccess    int      `json:"maxAccess,omitempty"`
 }
 
 type MSParams struct {
-	UUID      string  `json:"uuid"`
-	Longitude float64 `json:"lon"`
-	Latitude  float64 `json:"lat"`
-	Altitude  float64 `json:"height"`
+	UUID         string   `json:"uuid"`
+	Longitude    float64  `json:"lon"`
+	Latitude     float64  `json:"lat"`
+	Altitude     float64  `json:"height"`
+	MinElevation *float64 `json:"minElevation,omitempty"`
+	MaxAccess    int      `json:"maxAccess,omitempty"`
 }
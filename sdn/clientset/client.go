@@ -5,16 +5,20 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"ws/dtn-satellite-sdn/sdn/ipam"
 	"ws/dtn-satellite-sdn/sdn/link"
 	"ws/dtn-satellite-sdn/sdn/metrics"
 	"ws/dtn-satellite-sdn/sdn/pod"
 	"ws/dtn-satellite-sdn/sdn/route"
+	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
 	"ws/dtn-satellite-sdn/sdn/util"
 
 	"github.com/sirupsen/logrus"
+	topov1 "github.com/y-young/kube-dtn/api/v1"
 )
 
 type ClientInterface interface {
@@ -24,15 +28,25 @@ type ClientInterface interface {
 	GetRouteFromAndTo(uuid1, uuid2 string) ([]string, error)
 	GetRouteHops(uuid, uuidList string) (string, error)
 	GetDistance(uuid1, uuid2 string) (float64, error)
+	GetLatency(uuid1, uuid2 string) (float64, error)
+	GetLinkMetrics(uuid1, uuid2 string) (link.LinkMetrics, error)
+	GetUtilization(uuid1, uuid2 string) (float64, error)
+	ReportLinkLoad(loads []LinkLoad) error
 	GetSpreadArray(uuid string)([][]string, error)
+	GetAccess(uuid string) ([]string, error)
 	CheckConnectionHandler(w http.ResponseWriter, r *http.Request)
 	GetTopoInAscArrayHandler(w http.ResponseWriter, r *http.Request)
 	GetRouteFromAndToHandler(w http.ResponseWriter, r *http.Request)
 	GetRouteHopsHandler(w http.ResponseWriter, r *http.Request)
 	GetDistanceHanlder(w http.ResponseWriter, r *http.Request)
+	GetLatencyHandler(w http.ResponseWriter, r *http.Request)
+	GetLinkMetricsHandler(w http.ResponseWriter, r *http.Request)
+	ReportLinkLoadHandler(w http.ResponseWriter, r *http.Request)
 	GetSpreadArrayHanlder(w http.ResponseWriter, r *http.Request)
+	GetAccessHandler(w http.ResponseWriter, r *http.Request)
 	GetFakeMetricsHandler(w http.ResponseWriter, r *http.Request)
 	ApplyPod(nodeNum int) error
+	SyncNodes() error
 	ApplyTopo() error
 	ApplyRoute() error
 	UpdateTopo() error
@@ -51,34 +65,95 @@ type SDNClient struct {
 
 	// RWLock is RWMutex for synchronizing writing threads and reading threads
 	RWLock *sync.RWMutex
+
+	// addedUUIDs/removedUUIDs store nodes added/removed since last SyncNodes
+	addedUUIDs   map[string]bool
+	removedUUIDs map[string]bool
+
+	// uuidAllocNodeMap stores satellite's uuid -> physical node it is deployed to
+	uuidAllocNodeMap map[string]string
+
+	// userPairing stores iperf roles of users, which are kept as users join or leave
+	userPairing *pod.UserPairing
+
+	// appliedIndexUUIDMap/appliedTopo store the topology graph applied to cluster, which are only accessed
+	// by ApplyTopo/UpdateTopo. nil means unknown(e.g. reattaching), and all topologies will be updated.
+	appliedIndexUUIDMap map[int]string
+	appliedTopo         [][]int
+	appliedProperties   []topov1.LinkProperties
+
+	// routeIndexUUIDMap/appliedRoutes store the route table applied to cluster, which are only accessed
+	// by ApplyRoute/UpdateRoute. nil means unknown(e.g. reattaching), and all routes will be updated.
+	routeIndexUUIDMap map[int]string
+	appliedRoutes     *route.RouteTable
+}
+
+// AlternativeRoute is a route between two nodes other than the primary one.
+type AlternativeRoute struct {
+	Route   []string `json:"route"`
+	Latency float64  `json:"latency"`
 }
 
 // Function: NewSDNClient
 // Description: Create SDNClient with the address of the module that computes position of each node
 // 1. url: The address of the module that computes position of each node
-func NewSDNClient(url string) *SDNClient {
+// 2. config: Options of SDN server
+func NewSDNClient(url string, config *SDNConfig) *SDNClient {
 	params, err := util.Fetch(url)
 	if err != nil {
 		log.Fatal(err)
 	}
-	orbit := NewOrbitInfo(params)
+	var allocator *ipam.Allocator
+	if config.IPAMStore != nil {
+		if allocator, err = ipam.NewAllocator(config.IPAMStore, config.IPAMGracePeriod); err != nil {
+			log.Fatal(err)
+		}
+		// Slots left by a previous emulation are only kept when reattaching to it
+		if !config.Reattach {
+			allocator.Reset()
+		}
+	}
+	orbit := NewOrbitInfo(params, config.Filter, allocator)
 	return &SDNClient{
 		OrbitClient:   orbit,
-		NetworkClient: NewNetwork(orbit),
+		NetworkClient: NewNetwork(orbit, config),
 		PositionURL:   url,
 		RWLock:        new(sync.RWMutex),
+		addedUUIDs:       make(map[string]bool),
+		removedUUIDs:     make(map[string]bool),
+		uuidAllocNodeMap: make(map[string]string),
+		userPairing:      pod.NewUserPairing(),
 	}
 }
 
 // Function: FetchAndUpdate
 // Description: Update OrbitClient and NetworkClient.
 func (client *SDNClient) FetchAndUpdate() error {
+	// Link load is scraped before locking, so that requests are not blocked by a slow scrape
+	client.NetworkClient.ScrapeLoad()
 	client.RWLock.Lock()
 	defer client.RWLock.Unlock()
 	if params, err := util.Fetch(client.PositionURL); err != nil {
 		return fmt.Errorf("failed to update SDN: %v", err)
 	} else {
-		client.OrbitClient.Update(params)
+		added, removed := client.OrbitClient.Update(params)
+		for _, uuid := range removed {
+			// Nothing has been created for nodes added and removed before sync
+			if client.addedUUIDs[uuid] {
+				delete(client.addedUUIDs, uuid)
+			} else {
+				client.removedUUIDs[uuid] = true
+			}
+		}
+		for _, uuid := range added {
+			client.addedUUIDs[uuid] = true
+		}
+		if len(added) > 0 || len(removed) > 0 {
+			logrus.WithFields(logrus.Fields{
+				"added":   added,
+				"removed": removed,
+			}).Info("node set changed")
+		}
 		client.NetworkClient.UpdateNetwork(client.OrbitClient)
 		return nil
 	}
@@ -146,39 +221,65 @@ func (client *SDNClient) GetTopoInAscArrayHandler(w http.ResponseWriter, r *http
 }
 
 // Function: GetRouteFromAndTo
-// Description: Return the uuid of nodes in the route hops(uuid1, ..., uuid2) from Node(uuid1) to Node(uuid2).
+// Description: Return the uuid of nodes in the route hops(uuid1, ..., uuid2) from Node(uuid1) to Node(uuid2),
+// and error if Node(uuid2) is unreachable.
 // 1. uuid1: The src node's uuid.
 // 2. uuid2: The dst node's uuid.
 func (client *SDNClient) GetRouteFromAndTo(uuid1, uuid2 string) ([]string, error) {
+	result, _, _, err := client.getRouteWithLatency(uuid1, uuid2, false)
+	return result, err
+}
+
+// getRouteWithLatency returns route hops, end-to-end latency(ms) and alternative routes(if withAlternatives)
+// from Node(uuid1) to Node(uuid2) of the same update.
+func (client *SDNClient) getRouteWithLatency(uuid1, uuid2 string, withAlternatives bool) ([]string, float64, []AlternativeRoute, error) {
 	client.RWLock.RLock()
 	defer client.RWLock.RUnlock()
 	uuidIndexMap := client.OrbitClient.GetUUIDIndexMap()
 	indexUUIDMap := client.OrbitClient.GetIndexUUIDMap()
 	if uuid1_index, ok := uuidIndexMap[uuid1]; !ok {
-		return []string{}, fmt.Errorf("uuid %s does not exist", uuid1)
+		return []string{}, 0.0, nil, fmt.Errorf("uuid %s does not exist", uuid1)
 	} else if uuid2_index, ok := uuidIndexMap[uuid2]; !ok {
-		return []string{}, fmt.Errorf("uuid %s does not exist", uuid2)
+		return []string{}, 0.0, nil, fmt.Errorf("uuid %s does not exist", uuid2)
 	} else {
 		idxList := client.NetworkClient.GetRouteFromAndTo(uuid1_index, uuid2_index)
+		if idxList == nil {
+			return []string{}, 0.0, nil, fmt.Errorf("no route from %s to %s", uuid1, uuid2)
+		}
 		result := []string{}
 		for _, idx := range idxList {
 			result = append(result, indexUUIDMap[idx])
 		}
-		return result, nil
+		alternatives := []AlternativeRoute{}
+		if withAlternatives {
+			for _, hops := range client.NetworkClient.GetAlternativeRoutes(uuid1_index, uuid2_index) {
+				alternative := AlternativeRoute{Route: []string{}, Latency: client.NetworkClient.GetPathLatency(hops)}
+				for _, idx := range hops {
+					alternative.Route = append(alternative.Route, indexUUIDMap[idx])
+				}
+				alternatives = append(alternatives, alternative)
+			}
+		}
+		return result, client.NetworkClient.GetRouteLatency(uuid1_index, uuid2_index), alternatives, nil
 	}
 }
 
 // Function: GetRouteFromAndToHandler
-// Description: Description: Http handler wrapper for func GetRouteFromAndTo
+// Description: Description: Http handler wrapper for func GetRouteFromAndTo, with end-to-end latency(ms) of the route
+// and alternative routes if multipath is enabled
 func (client *SDNClient) GetRouteFromAndToHandler(w http.ResponseWriter, r *http.Request) {
 	params := r.URL.Query()
 	uuid1, uuid2 := params.Get("src"), params.Get("dst")
-	if routeHops, err := client.GetRouteFromAndTo(uuid1, uuid2); err != nil {
+	if routeHops, latency, alternatives, err := client.getRouteWithLatency(uuid1, uuid2, true); err != nil {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte(err.Error()))
 	} else {
 		result := map[string]interface{}{
-			"result": routeHops,
+			"result":  routeHops,
+			"latency": latency,
+		}
+		if len(alternatives) > 0 {
+			result["alternatives"] = alternatives
 		}
 		content, _ := json.Marshal(result)
 		w.WriteHeader(http.StatusOK)
@@ -187,7 +288,8 @@ func (client *SDNClient) GetRouteFromAndToHandler(w http.ResponseWriter, r *http
 }
 
 // Function: GetRouteHops
-// Description: Return the list of hop nums from Node(uuid1) to the nodes of which uuid is in uuidList.
+// Description: Return the list of hop nums from Node(uuid1) to the nodes of which uuid is in uuidList,
+// 0 means that the node is unreachable.
 // 1. uuid: The src node's uuid.
 // 2. uuidList: The dst nodes' uuid list.
 func (client *SDNClient) GetRouteHops(uuid, uuidList string) (string, error) {
@@ -265,6 +367,139 @@ func (client *SDNClient) GetDistanceHanlder(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// Function: GetLatency
+// Description: Return the end-to-end latency(ms) along the route from Node(uuid1) to Node(uuid2), -1 means no route.
+// 1. uuid1: The src node's uuid.
+// 2. uuid2: The dst node's uuid.
+func (client *SDNClient) GetLatency(uuid1, uuid2 string) (float64, error) {
+	_, latency, _, err := client.getRouteWithLatency(uuid1, uuid2, false)
+	return latency, err
+}
+
+// Function: GetLatencyHandler
+// Description: Http handler wrapper for GetLatency
+func (client *SDNClient) GetLatencyHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	uuid1, uuid2 := params.Get("src"), params.Get("dst")
+	if latency, err := client.GetLatency(uuid1, uuid2); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+	} else {
+		result := map[string]interface{}{
+			"result": latency,
+		}
+		content, _ := json.Marshal(result)
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+	}
+}
+
+// Function: GetLinkMetrics
+// Description: Return performance(distance, latency, SNR, rate) of the link between Node(uuid1) and Node(uuid2).
+// 1. uuid1: The first node's uuid.
+// 2. uuid2: The second node's uuid.
+func (client *SDNClient) GetLinkMetrics(uuid1, uuid2 string) (link.LinkMetrics, error) {
+	client.RWLock.RLock()
+	defer client.RWLock.RUnlock()
+	uuidIndexMap := client.OrbitClient.GetUUIDIndexMap()
+	if uuid1_index, ok := uuidIndexMap[uuid1]; !ok {
+		return link.LinkMetrics{}, fmt.Errorf("uuid %s does not exist", uuid1)
+	} else if uuid2_index, ok := uuidIndexMap[uuid2]; !ok {
+		return link.LinkMetrics{}, fmt.Errorf("uuid %s does not exist", uuid2)
+	} else if !client.NetworkClient.CheckConnection(uuid1_index, uuid2_index) {
+		return link.LinkMetrics{}, fmt.Errorf("%s and %s are not connected", uuid1, uuid2)
+	} else {
+		return client.NetworkClient.GetLinkMetrics(uuid1_index, uuid2_index), nil
+	}
+}
+
+// Function: GetLinkMetricsHandler
+// Description: Http handler wrapper for GetLinkMetrics
+func (client *SDNClient) GetLinkMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	uuid1, uuid2 := params.Get("src"), params.Get("dst")
+	if metrics, err := client.GetLinkMetrics(uuid1, uuid2); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+	} else {
+		utilization, _ := client.GetUtilization(uuid1, uuid2)
+		result := map[string]interface{}{
+			"result": map[string]float64{
+				"distance":    metrics.Distance,
+				"latency":     metrics.Latency,
+				"snr":         metrics.SNR,
+				"rate":        metrics.Rate,
+				"utilization": utilization,
+			},
+		}
+		content, _ := json.Marshal(result)
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+	}
+}
+
+// Function: GetUtilization
+// Description: Return utilization(0~1) of the link from Node(uuid1) to Node(uuid2) by which routes were computed,
+// which is 0 unless routes are load-aware.
+// 1. uuid1: The sender's uuid.
+// 2. uuid2: The receiver's uuid.
+func (client *SDNClient) GetUtilization(uuid1, uuid2 string) (float64, error) {
+	client.RWLock.RLock()
+	defer client.RWLock.RUnlock()
+	uuidIndexMap := client.OrbitClient.GetUUIDIndexMap()
+	if uuid1_index, ok := uuidIndexMap[uuid1]; !ok {
+		return 0, fmt.Errorf("uuid %s does not exist", uuid1)
+	} else if uuid2_index, ok := uuidIndexMap[uuid2]; !ok {
+		return 0, fmt.Errorf("uuid %s does not exist", uuid2)
+	} else {
+		return client.NetworkClient.GetUtilization(uuid1_index, uuid2_index), nil
+	}
+}
+
+// Function: ReportLinkLoad
+// Description: Record throughput of links observed by pods, by which routes are recomputed at the next update.
+// 1. loads: Throughput(Mbps) of directed links, each reported by the sender.
+func (client *SDNClient) ReportLinkLoad(loads []LinkLoad) error {
+	client.RWLock.RLock()
+	defer client.RWLock.RUnlock()
+	uuidIndexMap := client.OrbitClient.GetUUIDIndexMap()
+	for _, load := range loads {
+		if _, ok := uuidIndexMap[load.Src]; !ok {
+			return fmt.Errorf("uuid %s does not exist", load.Src)
+		} else if _, ok := uuidIndexMap[load.Dst]; !ok {
+			return fmt.Errorf("uuid %s does not exist", load.Dst)
+		} else if load.Throughput < 0 {
+			return fmt.Errorf("throughput %v from %s to %s should not be negative", load.Throughput, load.Src, load.Dst)
+		}
+	}
+	if !client.NetworkClient.ReportLoad(loads) {
+		return fmt.Errorf("load-aware routing is disabled")
+	}
+	return nil
+}
+
+// Function: ReportLinkLoadHandler
+// Description: Http handler wrapper for ReportLinkLoad, which accepts a JSON list of LinkLoad by POST.
+func (client *SDNClient) ReportLinkLoadHandler(w http.ResponseWriter, r *http.Request) {
+	loads := []LinkLoad{}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	} else if err := json.NewDecoder(r.Body).Decode(&loads); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("invalid link load: %v", err)))
+	} else if err := client.ReportLinkLoad(loads); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+	} else {
+		result := map[string]interface{}{
+			"result": len(loads),
+		}
+		content, _ := json.Marshal(result)
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+	}
+}
+
 // Function: GetSpreadArray
 // Descritpion: Return route spread array for given node.
 func (client *SDNClient) GetSpreadArray(uuid string) ([]SpreadLink, error) {
@@ -303,6 +538,45 @@ func (client *SDNClient) GetSpreadArrayHanlder(w http.ResponseWriter, r *http.Re
 	}
 }
 
+// Function: GetAccess
+// Description: Return the uuid of satellites accessed by terminal(uuid), empty means no coverage.
+// 1. uuid: The terminal's uuid.
+func (client *SDNClient) GetAccess(uuid string) ([]string, error) {
+	client.RWLock.RLock()
+	defer client.RWLock.RUnlock()
+	uuidIndexMap := client.OrbitClient.GetUUIDIndexMap()
+	indexUUIDMap := client.OrbitClient.GetIndexUUIDMap()
+	if uuid_index, ok := uuidIndexMap[uuid]; !ok {
+		return nil, fmt.Errorf("uuid %s does not exist", uuid)
+	} else if idxList, ok := client.NetworkClient.GetAccess(uuid_index); !ok {
+		return nil, fmt.Errorf("uuid %s is not a terminal", uuid)
+	} else {
+		result := []string{}
+		for _, idx := range idxList {
+			result = append(result, indexUUIDMap[idx])
+		}
+		return result, nil
+	}
+}
+
+// Function: GetAccessHandler
+// Description: Http handler wrapper for GetAccess.
+func (client *SDNClient) GetAccessHandler(w http.ResponseWriter, r *http.Request) {
+	uuid := r.URL.Query().Get("uuid")
+	if sats, err := client.GetAccess(uuid); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+	} else {
+		result := map[string]interface{}{
+			"result":   sats,
+			"coverage": len(sats) > 0,
+		}
+		content, _ := json.Marshal(result)
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+	}
+}
+
 // Function: GetFakeMetricsHandler
 // Description: Http handler wrapper for GetFakeStarMetrics & GetFakeLinkMetrics
 func (client *SDNClient) GetFakeMetricsHandler(w http.ResponseWriter, r *http.Request) {
@@ -317,20 +591,35 @@ func (client *SDNClient) GetFakeMetricsHandler(w http.ResponseWriter, r *http.Re
 	}
 }
 
+// getUserUUIDs returns uuids of all users in ascending order of their indices.
+func (client *SDNClient) getUserUUIDs() []string {
+	meta := client.OrbitClient.Metadata
+	result := []string{}
+	for _, index := range meta.GetIndexesByType(satv2.USER) {
+		result = append(result, meta.IndexUUIDMap[index])
+	}
+	return result
+}
+
 // Function: ApplyPod
 // Description: Apply pods according to infos in SDNClient
 func (client *SDNClient) ApplyPod(nodeNum int) error {
-	allocIdx, uuidAllocNodeMap := 0, map[string]string{}
+	allocIdx, uuidAllocNodeMap := 0, client.uuidAllocNodeMap
 	kubeNodeList, _ := util.GetSlaveNodes(nodeNum)
-	client.RWLock.RLock()
-	defer client.RWLock.RUnlock()
+	client.RWLock.Lock()
+	defer client.RWLock.Unlock()
 	logrus.WithField("node-num", nodeNum).Info("Applying pod...")
-	// Currently, we only need to allocate low-orbit satellites in one group to the same physical node.
+	// Currently, we only need to allocate satellites in one group to the same physical node.
 	capacity := map[string]int{}
 	for k, v := range util.NodeCapacity {
 		capacity[k] = v
 	}
-	for _, group := range client.OrbitClient.LowOrbitSats {
+	// Groups are sorted so that the allocation is the same when reattaching to existing pods
+	groups := append(
+		link.SortGroupsByTrackID(client.OrbitClient.LowOrbitSats),
+		link.SortGroupsByTrackID(client.OrbitClient.HighOrbitSats)...,
+	)
+	for _, group := range groups {
 		// Skip zero-capacity nodes.
 		for capacity[kubeNodeList[allocIdx]] == 0 {
 			allocIdx = (allocIdx + 1) % len(kubeNodeList)
@@ -346,33 +635,157 @@ func (client *SDNClient) ApplyPod(nodeNum int) error {
 			capacity[kubeNodeList[allocIdx]] = util.NodeCapacity[kubeNodeList[allocIdx]] // restore the value at beginning for next round
 		}
 	}
+	client.userPairing.Update(client.getUserUUIDs())
 	podMeta := pod.PodMetadata{
 		IndexUUIDMap: client.OrbitClient.GetIndexUUIDMap(),
-		UserIdxMin: client.OrbitClient.Metadata.LowOrbitNum +
-			client.OrbitClient.Metadata.HighOrbitNum +
-			client.OrbitClient.Metadata.GroundStationNum +
-			client.OrbitClient.Metadata.MissileNum,
-		UserNum: client.OrbitClient.Metadata.UserNum,
+		UUIDIndexMap: client.OrbitClient.Metadata.UUIDIndexMap,
+		Pairing:      client.userPairing,
 	}
 	return pod.PodSyncLoop(&podMeta, uuidAllocNodeMap)
 }
 
+// Function: SyncNodes
+// Description: Delete routes, topologies and pods of nodes removed since last sync,
+// then create topologies, pods and routes for nodes added since last sync.
+// Links and routes of new nodes are filled by following UpdateTopo and UpdateRoute.
+func (client *SDNClient) SyncNodes() error {
+	client.RWLock.Lock()
+	defer client.RWLock.Unlock()
+	if len(client.addedUUIDs) == 0 && len(client.removedUUIDs) == 0 {
+		return nil
+	}
+	added, removed := []string{}, []string{}
+	for uuid := range client.addedUUIDs {
+		added = append(added, uuid)
+	}
+	for uuid := range client.removedUUIDs {
+		removed = append(removed, uuid)
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	logrus.WithFields(logrus.Fields{
+		"added":   added,
+		"removed": removed,
+	}).Info("Syncing nodes...")
+
+	// Delete objects of removed nodes
+	if len(removed) > 0 {
+		if err := route.DeleteRoutes(removed); err != nil {
+			return err
+		}
+		if err := link.DeleteTopologies(removed); err != nil {
+			return err
+		}
+		if err := pod.DeletePods(removed); err != nil {
+			return err
+		}
+		for _, uuid := range removed {
+			delete(client.uuidAllocNodeMap, uuid)
+		}
+		client.removedUUIDs = make(map[string]bool)
+	}
+
+	// Create objects of added nodes, topologies should exist before pods are created
+	if len(added) > 0 {
+		meta := client.OrbitClient.Metadata
+		if err := link.CreateTopologies(added); err != nil {
+			return err
+		}
+		indexUUIDMap := map[int]string{}
+		for _, uuid := range added {
+			indexUUIDMap[meta.UUIDIndexMap[uuid]] = uuid
+			// New satellites are deployed to the same physical node as others in their group
+			node := meta.UUIDNodeMap[uuid]
+			if node.Type != satv2.LOWORBIT && node.Type != satv2.HIGHORBIT {
+				continue
+			}
+			groups := client.OrbitClient.LowOrbitSats
+			if node.Type == satv2.HIGHORBIT {
+				groups = client.OrbitClient.HighOrbitSats
+			}
+			if group, ok := groups[node.TrackID]; ok {
+				for _, other := range group.Nodes {
+					if allocNode, ok := client.uuidAllocNodeMap[other.UUID]; ok {
+						client.uuidAllocNodeMap[uuid] = allocNode
+						break
+					}
+				}
+			}
+		}
+		client.userPairing.Update(client.getUserUUIDs())
+		podMeta := pod.PodMetadata{
+			IndexUUIDMap: indexUUIDMap,
+			UUIDIndexMap: meta.UUIDIndexMap,
+			Pairing:      client.userPairing,
+		}
+		if err := pod.PodSyncLoop(&podMeta, client.uuidAllocNodeMap); err != nil {
+			return err
+		}
+		if err := route.CreateRoutes(added); err != nil {
+			return err
+		}
+		client.addedUUIDs = make(map[string]bool)
+	}
+	return nil
+}
+
 // Function: ApplyTopo
 // Description: Apply topologies according to infos in SDNClient
 func (client *SDNClient) ApplyTopo() error {
 	client.RWLock.RLock()
 	defer client.RWLock.RUnlock()
 	logrus.Info("Applying topology...")
-	return link.LinkSyncLoop(client.OrbitClient.GetIndexUUIDMap(), client.NetworkClient.GetTopoInAscArray(), true)
+	indexUUIDMap, topoAscArray := client.OrbitClient.GetIndexUUIDMap(), client.NetworkClient.GetTopoInAscArray()
+	linkProperties := client.NetworkClient.GetLinkProperties(topoAscArray)
+	if err := link.LinkSyncLoop(indexUUIDMap, topoAscArray, linkProperties, true); err != nil {
+		return err
+	}
+	client.setAppliedTopo(indexUUIDMap, topoAscArray, linkProperties)
+	return nil
 }
 
 // Function: UpdateTopo
-// Description: Update topologies according to infos in SDNClient
+// Description: Update topologies according to infos in SDNClient.
+// Only topologies whose links or link properties changed since last apply/update are updated.
 func (client *SDNClient) UpdateTopo() error {
 	client.RWLock.RLock()
 	defer client.RWLock.RUnlock()
 	logrus.Info("Updating topology...")
-	return link.LinkSyncLoop(client.OrbitClient.GetIndexUUIDMap(), client.NetworkClient.GetTopoInAscArray(), false)
+	indexUUIDMap, topoAscArray := client.OrbitClient.GetIndexUUIDMap(), client.NetworkClient.GetTopoInAscArray()
+	linkProperties := client.NetworkClient.GetLinkProperties(topoAscArray)
+	if client.appliedTopo == nil {
+		if err := link.LinkSyncLoop(indexUUIDMap, topoAscArray, linkProperties, false); err != nil {
+			return err
+		}
+	} else {
+		diff := link.DiffTopologies(
+			client.appliedIndexUUIDMap, client.appliedTopo, client.appliedProperties,
+			indexUUIDMap, topoAscArray, linkProperties,
+		)
+		logrus.WithFields(logrus.Fields{
+			"added-links":   len(diff.Added),
+			"removed-links": len(diff.Removed),
+			"updated-links": len(diff.Updated),
+			"topologies":    len(diff.Changed),
+		}).Info("topology changed")
+		if err := link.UpdateTopologies(indexUUIDMap, topoAscArray, linkProperties, diff.Changed); err != nil {
+			// Applied state is unknown after a partial failure, so fall back to full update next time
+			client.appliedTopo = nil
+			return err
+		}
+	}
+	client.setAppliedTopo(indexUUIDMap, topoAscArray, linkProperties)
+	return nil
+}
+
+// setAppliedTopo records the topology graph applied to cluster.
+func (client *SDNClient) setAppliedTopo(indexUUIDMap map[int]string, topoAscArray [][]int, linkProperties []topov1.LinkProperties) {
+	client.appliedIndexUUIDMap = make(map[int]string, len(indexUUIDMap))
+	for idx, uuid := range indexUUIDMap {
+		client.appliedIndexUUIDMap[idx] = uuid
+	}
+	client.appliedTopo = topoAscArray
+	client.appliedProperties = linkProperties
 }
 
 // Function: ApplyRoute
@@ -381,14 +794,115 @@ func (client *SDNClient) ApplyRoute() error {
 	client.RWLock.RLock()
 	defer client.RWLock.RUnlock()
 	logrus.Info("Applying route...")
-	return route.RouteSyncLoop(client.OrbitClient.GetIndexUUIDMap(), client.NetworkClient.RouteGraph, true)
+	indexUUIDMap := client.OrbitClient.GetIndexUUIDMap()
+	routeTable := client.validateRoutes(indexUUIDMap, nil)
+	if err := route.RouteSyncLoop(indexUUIDMap, routeTable, true); err != nil {
+		return err
+	}
+	client.setAppliedRoutes(indexUUIDMap, routeTable)
+	return nil
 }
 
 // Function: UpdateRoute
-// Description: Update routes according to infos in SDNClient
+// Description: Update routes according to infos in SDNClient.
+// If the node set is unchanged since last apply/update, only routes whose next hops changed are updated.
 func (client *SDNClient) UpdateRoute() error {
 	client.RWLock.RLock()
 	defer client.RWLock.RUnlock()
 	logrus.Info("Updating route...")
-	return route.RouteSyncLoop(client.OrbitClient.GetIndexUUIDMap(), client.NetworkClient.RouteGraph, false)
+	indexUUIDMap := client.OrbitClient.GetIndexUUIDMap()
+	// Transient loops are only possible when routes are updated node by node from applied ones
+	var prevTable *route.RouteTable
+	if client.sameRouteNodes(indexUUIDMap) {
+		prevTable = client.appliedRoutes
+	}
+	routeTable := client.validateRoutes(indexUUIDMap, prevTable)
+	if changed, ok := client.diffRoutes(indexUUIDMap, routeTable); ok {
+		logrus.WithField("routes", len(changed)).Info("route changed")
+		if err := route.UpdateRoutes(indexUUIDMap, routeTable, changed); err != nil {
+			// Applied state is unknown after a partial failure, so fall back to full update next time
+			client.appliedRoutes = nil
+			return err
+		}
+	} else if err := route.RouteSyncLoop(indexUUIDMap, routeTable, false); err != nil {
+		return err
+	}
+	client.setAppliedRoutes(indexUUIDMap, routeTable)
+	return nil
+}
+
+// diffRoutes returns indices of nodes whose next hops differ from applied routes,
+// and false if applied routes are unknown or the node set changed, in which case all routes change.
+func (client *SDNClient) diffRoutes(indexUUIDMap map[int]string, routeTable *route.RouteTable) ([]int, bool) {
+	if !client.sameRouteNodes(indexUUIDMap) || client.appliedRoutes.Len() != routeTable.Len() {
+		return nil, false
+	}
+	changed := []int{}
+	for idx := 0; idx < routeTable.Len(); idx++ {
+		if _, ok := indexUUIDMap[idx]; ok && !routeTable.EqualRow(client.appliedRoutes, idx) {
+			changed = append(changed, idx)
+		}
+	}
+	return changed, true
+}
+
+// sameRouteNodes returns whether applied routes are known and nodes have the same indices as them.
+func (client *SDNClient) sameRouteNodes(indexUUIDMap map[int]string) bool {
+	if client.appliedRoutes == nil || len(client.routeIndexUUIDMap) != len(indexUUIDMap) {
+		return false
+	}
+	for idx, uuid := range indexUUIDMap {
+		if client.routeIndexUUIDMap[idx] != uuid {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRoutes returns the route table of NetworkClient in which unreachable destinations, blackholes and loops
+// are omitted, then logs issues found. prevTable is the route table applied before, nil means no transition.
+// The route table is validated on a clone sharing rows with it, since it is read by handlers at the same time.
+func (client *SDNClient) validateRoutes(indexUUIDMap map[int]string, prevTable *route.RouteTable) *route.RouteTable {
+	routeTable := client.NetworkClient.RouteGraph.Clone()
+	issues := route.ValidateRoutes(client.NetworkClient.TopoGraph, indexUUIDMap, routeTable, prevTable, util.ThreadNums)
+	// Routes computed over lasting links should survive the look-ahead horizon, which is checked before installation
+	if plan := client.NetworkClient.ContactPlan; plan != nil {
+		issues = append(issues, plan.Validate(indexUUIDMap, routeTable, util.ThreadNums)...)
+	}
+	if len(issues) == 0 {
+		return routeTable
+	}
+	// Only a few routes of each kind are listed, since an isolated node alone makes routes to all others unreachable
+	const maxListed = 10
+	counts, listed := map[string]int{}, map[string][]string{}
+	for _, issue := range issues {
+		counts[issue.Kind]++
+		if len(listed[issue.Kind]) < maxListed {
+			listed[issue.Kind] = append(listed[issue.Kind], indexUUIDMap[issue.Src]+"->"+indexUUIDMap[issue.Dst])
+		}
+	}
+	for kind, count := range counts {
+		logger := logrus.WithFields(logrus.Fields{
+			"kind":   kind,
+			"count":  count,
+			"routes": listed[kind],
+		})
+		switch kind {
+		case route.TransientLoopIssue:
+			logger.Warn("routes may loop until all routes are updated")
+		default:
+			logger.Warn("invalid routes are omitted")
+		}
+	}
+	return routeTable
+}
+
+// setAppliedRoutes records the route table applied to cluster.
+// routeTable validated is created by each update and its rows are never modified in place, so it is not copied.
+func (client *SDNClient) setAppliedRoutes(indexUUIDMap map[int]string, routeTable *route.RouteTable) {
+	client.routeIndexUUIDMap = make(map[int]string, len(indexUUIDMap))
+	for idx, uuid := range indexUUIDMap {
+		client.routeIndexUUIDMap[idx] = uuid
+	}
+	client.appliedRoutes = routeTable
 }
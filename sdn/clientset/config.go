@@ -0,0 +1,70 @@
+package clientset
+
+import (
+	"time"
+
+	"ws/dtn-satellite-sdn/sdn/filter"
+	"ws/dtn-satellite-sdn/sdn/ipam"
+	"ws/dtn-satellite-sdn/sdn/link"
+	"ws/dtn-satellite-sdn/sdn/route"
+	"ws/dtn-satellite-sdn/sdn/util"
+)
+
+// DefaultRelayNum is the default number of low-orbit satellites connected by each high-orbit satellite.
+const DefaultRelayNum = 2
+
+// SDNConfig stores options of SDN server chosen by `sdnctl init`.
+type SDNConfig struct {
+	// Topology is the strategy to compute inter-satellite links
+	Topology TopologyStrategy
+
+	// Hysteresis keeps existing cross-plane links stable, nil means recomputing links from scratch
+	Hysteresis *HysteresisConfig
+
+	// CrossPlane disables cross-plane links in polar region and across the seam, nil means no restriction
+	CrossPlane *CrossPlaneConfig
+
+	// RelayNum is the number of low-orbit satellites connected by each high-orbit satellite
+	RelayNum int
+
+	// Filter decides which satellites take part in emulation, nil means keeping all satellites.
+	// The default filter excludes satellites 53302 and 53347.
+	Filter *filter.SatelliteFilter
+
+	// IPAMStore persists indices(and addresses derived from them) of nodes, nil means no persistence
+	IPAMStore ipam.Store
+
+	// IPAMGracePeriod is the time that indices of removed nodes are kept for them before reused by new nodes
+	IPAMGracePeriod time.Duration
+
+	// LinkModel derives latency of links, by which routes are computed, and properties applied to topologies
+	LinkModel *link.LinkModel
+
+	// Router computes routes with link latency(and positions, utilization, etc)
+	Router route.Router
+
+	// LookAhead routes only over links lasting the look-ahead horizon by their predicted snapshots, nil means routing on current topology
+	LookAhead *LookAheadConfig
+
+	// Load turns throughput reported by pods into utilization of links for load-aware routing, nil means ignoring traffic
+	Load *LoadConfig
+
+	// Multipath returns alternative routes by /getRoute, nil means only the installed route is returned
+	Multipath *MultipathConfig
+
+	// Reattach means that topologies, pods and routes already exist and are updated instead of created
+	Reattach bool
+}
+
+// Function: NewDefaultSDNConfig
+// Description: Return SDNConfig with the same behaviour as SDN server without options.
+func NewDefaultSDNConfig() *SDNConfig {
+	return &SDNConfig{
+		Topology:        &DefaultTopology{},
+		RelayNum:        DefaultRelayNum,
+		Filter:          filter.DefaultSatelliteFilter(),
+		LinkModel:       &link.LinkModel{LatencyStep: link.DefaultLatencyStep},
+		Router:          &route.ShortestRouter{RouteEngine: route.NewRouteEngine(route.DefaultWeightTolerance, util.ThreadNums)},
+		IPAMGracePeriod: ipam.DefaultGracePeriod,
+	}
+}
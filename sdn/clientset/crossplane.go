@@ -0,0 +1,157 @@
+package clientset
+
+import (
+	"math"
+	"sort"
+
+	"ws/dtn-satellite-sdn/sdn/link"
+	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CrossPlaneConfig decides where cross-plane links are disabled, as real +Grid constellations do.
+type CrossPlaneConfig struct {
+	// PolarLatitude is the latitude(degree) above which cross-plane links are disabled, 0 means no cutoff
+	PolarLatitude float64
+
+	// SuppressSeam disables cross-plane links across the seam, i.e. between the first and the last plane of a
+	// constellation whose planes spread over about 180 degrees of RAAN(e.g. Walker-star), which are counter-rotating
+	SuppressSeam bool
+
+	// SeamAngle is the min angle(degree) between the normals of the planes beside the seam to regard them as counter-rotating
+	SeamAngle float64
+}
+
+// seamGapRatio is the min ratio of the largest RAAN gap between adjacent planes to the mean of the other gaps,
+// above which the largest gap is regarded as the seam rather than ordinary spacing(e.g. of Walker-delta)
+const seamGapRatio = 1.5
+
+// crossPlaneFilter checks cross-plane links against CrossPlaneConfig at a given orbit info.
+type crossPlaneFilter struct {
+	config *CrossPlaneConfig
+	info   *OrbitInfo
+
+	// seam stores trackIDs of the two planes beside the seam, nil means there is no seam
+	seam *[2]int
+}
+
+func newCrossPlaneFilter(config *CrossPlaneConfig, info *OrbitInfo) *crossPlaneFilter {
+	filter := &crossPlaneFilter{
+		config: config,
+		info:   info,
+	}
+	if config.SuppressSeam {
+		normals := map[int][3]float64{}
+		for trackID, group := range info.LowOrbitSats {
+			x, y, z := link.GetPlaneNormal(group, info.Metadata.TimeStamp)
+			normals[trackID] = [3]float64{x, y, z}
+		}
+		filter.seam = findSeam(normals, config.SeamAngle)
+	}
+	return filter
+}
+
+// findSeam returns trackIDs of the two planes beside the seam, and nil if there is no seam.
+// Planes are ordered by RAAN, derived from normals along angular momentum. The seam lies in the largest gap
+// between adjacent planes if it is much larger than the others, and planes beside it move in opposite directions.
+func findSeam(normals map[int][3]float64, seamAngle float64) *[2]int {
+	type plane struct {
+		trackID int
+		raan    float64
+		normal  [3]float64
+	}
+	planes := []plane{}
+	for trackID, normal := range normals {
+		// Planes with less than two satellites have no normal
+		if normal == [3]float64{} {
+			continue
+		}
+		// The ascending node is along z × normal = (-normal.y, normal.x, 0)
+		raan := math.Atan2(normal[0], -normal[1]) * 180.0 / math.Pi
+		if raan < 0 {
+			raan += 360
+		}
+		planes = append(planes, plane{trackID: trackID, raan: raan, normal: normal})
+	}
+	if len(planes) < 3 {
+		return nil
+	}
+	sort.Slice(planes, func(i, j int) bool {
+		return planes[i].raan < planes[j].raan
+	})
+	// gap i is between planes[i] and the next plane in RAAN order
+	largest, largestGap := 0, 0.0
+	for i := range planes {
+		gap := planes[(i+1)%len(planes)].raan - planes[i].raan
+		if gap < 0 {
+			gap += 360
+		}
+		if gap > largestGap {
+			largest, largestGap = i, gap
+		}
+	}
+	meanGap := (360 - largestGap) / float64(len(planes)-1)
+	if largestGap < seamGapRatio*meanGap {
+		return nil
+	}
+	before, after := planes[largest], planes[(largest+1)%len(planes)]
+	cos := before.normal[0]*after.normal[0] + before.normal[1]*after.normal[1] + before.normal[2]*after.normal[2]
+	if cos >= math.Cos(seamAngle*math.Pi/180.0) {
+		return nil
+	}
+	return &[2]int{before.trackID, after.trackID}
+}
+
+// Function: Allow
+// Description: Return false if the link is a cross-plane link in polar region or across the seam.
+func (f *crossPlaneFilter) Allow(uuid1, uuid2 string) bool {
+	return f.AllowAt(uuid1, uuid2, f.info.Metadata.UUIDNodeMap)
+}
+
+// Function: AllowAt
+// Description: Return false if the link is a cross-plane link in polar region or across the seam, where nodes are
+// located by uuidNodeMap at another time(e.g. predicted within look-ahead horizon). Nodes missing in it are
+// located as in orbit info.
+func (f *crossPlaneFilter) AllowAt(uuid1, uuid2 string, uuidNodeMap map[string]*satv2.Node) bool {
+	node1 := f.info.Metadata.UUIDNodeMap[uuid1]
+	node2 := f.info.Metadata.UUIDNodeMap[uuid2]
+	if node1.Type != satv2.LOWORBIT || node2.Type != satv2.LOWORBIT || node1.TrackID == node2.TrackID {
+		return true
+	}
+	latitude1, latitude2 := node1.Latitude, node2.Latitude
+	if located, ok := uuidNodeMap[uuid1]; ok {
+		latitude1 = located.Latitude
+	}
+	if located, ok := uuidNodeMap[uuid2]; ok {
+		latitude2 = located.Latitude
+	}
+	if f.config.PolarLatitude > 0 &&
+		(math.Abs(latitude1) > f.config.PolarLatitude || math.Abs(latitude2) > f.config.PolarLatitude) {
+		return false
+	}
+	if f.seam != nil && (*f.seam == [2]int{node1.TrackID, node2.TrackID} || *f.seam == [2]int{node2.TrackID, node1.TrackID}) {
+		return false
+	}
+	return true
+}
+
+// Function: Apply
+// Description: Remove disabled cross-plane links from ISL map.
+// 1. islMap: Links in the form of UUID -> []UUID.
+func (f *crossPlaneFilter) Apply(islMap map[string][]string) map[string][]string {
+	result := map[string][]string{}
+	removed := 0
+	for uuid1, uuidList := range islMap {
+		result[uuid1] = []string{}
+		for _, uuid2 := range uuidList {
+			if f.Allow(uuid1, uuid2) {
+				result[uuid1] = append(result[uuid1], uuid2)
+			} else {
+				removed++
+			}
+		}
+	}
+	logrus.WithField("removed", removed).Debug("suppress cross-plane links in polar region and seam")
+	return result
+}
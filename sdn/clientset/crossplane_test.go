@@ -0,0 +1,31 @@
+package clientset
+
+import (
+	"math"
+	"testing"
+)
+
+// planeNormal returns the normal of a plane with inclination and RAAN in degrees.
+func planeNormal(inclination, raan float64) [3]float64 {
+	i, o := inclination*math.Pi/180.0, raan*math.Pi/180.0
+	return [3]float64{math.Sin(i) * math.Sin(o), -math.Sin(i) * math.Cos(o), math.Cos(i)}
+}
+
+func TestFindSeam(t *testing.T) {
+	// Walker-star: 6 polar planes over 180 degrees, the first and the last are counter-rotating
+	star := map[int][3]float64{}
+	for trackID := 0; trackID < 6; trackID++ {
+		star[trackID] = planeNormal(87, float64(trackID*30))
+	}
+	if seam := findSeam(star, 90); seam == nil || *seam != [2]int{5, 0} {
+		t.Errorf("Expect seam between plane 5 and 0, got %v", seam)
+	}
+	// Walker-delta: planes over 360 degrees have no seam, even if some of them are far apart
+	delta := map[int][3]float64{}
+	for trackID := 0; trackID < 6; trackID++ {
+		delta[trackID] = planeNormal(53, float64(trackID*60))
+	}
+	if seam := findSeam(delta, 90); seam != nil {
+		t.Errorf("Expect no seam in Walker-delta, got %v", *seam)
+	}
+}
@@ -0,0 +1,62 @@
+package clientset
+
+import (
+	"math"
+	"time"
+
+	"ws/dtn-satellite-sdn/sdn/filter"
+	"ws/dtn-satellite-sdn/sdn/link"
+	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Function: FilterGroups
+// Description: Remove satellites rejected by filter from groups, and delete empty groups.
+// The inclination of each satellite is estimated from the normal of its group(orbital plane),
+// and unknown for satellites alone in their planes, which match no inclination rule.
+// Return uuids of removed satellites.
+// 1. groups: Satellite groups indexed by trackID.
+// 2. satFilter: The satellite filter, nil means keeping all satellites.
+// 3. curTime: Standard time to compute positions.
+func FilterGroups(groups map[int]*satv2.Group, satFilter *filter.SatelliteFilter, curTime time.Time) []string {
+	removed := []string{}
+	if satFilter == nil {
+		return removed
+	}
+	for trackID, group := range groups {
+		inclination := groupInclination(group, curTime)
+		nodes := make([]satv2.Node, 0, group.Len())
+		for _, node := range group.Nodes {
+			if satFilter.Match(filter.FilterTarget{
+				UUID:        node.UUID,
+				Altitude:    node.Altitude,
+				Inclination: inclination,
+				TrackID:     trackID,
+			}) {
+				nodes = append(nodes, node)
+			} else {
+				removed = append(removed, node.UUID)
+			}
+		}
+		if len(nodes) == 0 {
+			delete(groups, trackID)
+		} else {
+			group.Nodes = nodes
+		}
+	}
+	if len(removed) > 0 {
+		logrus.WithField("satellites", removed).Info("satellites are filtered out")
+	}
+	return removed
+}
+
+// groupInclination returns the inclination(degree) of the orbital plane formed by group,
+// and NaN if the plane has no normal(less than two satellites).
+func groupInclination(group *satv2.Group, curTime time.Time) float64 {
+	x, y, z := link.GetPlaneNormal(group, curTime)
+	if x == 0 && y == 0 && z == 0 {
+		return math.NaN()
+	}
+	return math.Acos(math.Max(-1, math.Min(1, z))) * 180.0 / math.Pi
+}
@@ -0,0 +1,172 @@
+package clientset
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HysteresisConfig decides when an existing cross-plane link is released.
+// An existing link is kept while it is feasible(both ends exist and see each other) and
+//  1. its length is no more than ReleaseRange(0 means no limit);
+//  2. the central angle between its ends is no more than ReleaseAngle(0 means no limit);
+//  3. its length is no more than (1+Margin) times the length of the new candidate link.
+type HysteresisConfig struct {
+	// ReleaseRange is the max length(km) of a kept link
+	ReleaseRange float64
+
+	// ReleaseAngle is the max central angle(degree) between two ends of a kept link
+	ReleaseAngle float64
+
+	// Margin is the ratio by which a new candidate must be shorter to replace a kept link
+	Margin float64
+}
+
+// Function: Validate
+// Description: Check that thresholds are non-negative and the release angle is a central angle.
+func (h *HysteresisConfig) Validate() error {
+	if h.ReleaseRange < 0 {
+		return fmt.Errorf("release range %v should not be negative", h.ReleaseRange)
+	}
+	if h.ReleaseAngle < 0 || h.ReleaseAngle > 180 {
+		return fmt.Errorf("release angle %v should be in [0, 180]", h.ReleaseAngle)
+	}
+	if h.Margin < 0 {
+		return fmt.Errorf("margin %v should not be negative", h.Margin)
+	}
+	return nil
+}
+
+// linkKey identifies an undirected link by its ends' uuid in ascending order.
+type linkKey struct {
+	uuid1 string
+	uuid2 string
+}
+
+func newLinkKey(uuid1, uuid2 string) linkKey {
+	if uuid1 > uuid2 {
+		uuid1, uuid2 = uuid2, uuid1
+	}
+	return linkKey{uuid1: uuid1, uuid2: uuid2}
+}
+
+// Function: Apply
+// Description: Merge existing cross-plane links into links selected by topology strategy.
+// Each satellite keeps at most as many cross-plane links as the strategy selects for it,
+// and kept links take precedence over new candidates.
+// Return the merged ISL map and the set of cross-plane links in it.
+// 1. prevLinks: Cross-plane links in last update.
+// 2. islMap: Links selected by topology strategy in this update.
+// 3. info: Current orbit info.
+func (h *HysteresisConfig) Apply(prevLinks map[linkKey]bool, islMap map[string][]string, info *OrbitInfo) (map[string][]string, map[linkKey]bool) {
+	meta := info.Metadata
+	isCrossPlane := func(key linkKey) bool {
+		node1, node2 := meta.UUIDNodeMap[key.uuid1], meta.UUIDNodeMap[key.uuid2]
+		return node1.Type == satv2.LOWORBIT && node2.Type == satv2.LOWORBIT && node1.TrackID != node2.TrackID
+	}
+	distance := func(key linkKey) float64 {
+		return meta.UUIDNodeMap[key.uuid1].DistanceWithNodeAtTime(meta.UUIDNodeMap[key.uuid2], meta.TimeStamp)
+	}
+
+	// Split strategy's result into in-plane links and cross-plane candidates
+	result := map[string][]string{}
+	candidates := map[linkKey]bool{}
+	budget := map[string]int{}
+	for uuid1, uuidList := range islMap {
+		result[uuid1] = []string{}
+		for _, uuid2 := range uuidList {
+			key := newLinkKey(uuid1, uuid2)
+			if isCrossPlane(key) {
+				candidates[key] = true
+			} else {
+				result[uuid1] = append(result[uuid1], uuid2)
+			}
+		}
+	}
+	// The longest candidate of each satellite is the reference for margin.
+	// lengths caches the distance of links, which is computed from positions and reused by sorting.
+	longestCandidate := map[string]float64{}
+	lengths := map[linkKey]float64{}
+	for key := range candidates {
+		budget[key.uuid1]++
+		budget[key.uuid2]++
+		d := distance(key)
+		lengths[key] = d
+		longestCandidate[key.uuid1] = math.Max(longestCandidate[key.uuid1], d)
+		longestCandidate[key.uuid2] = math.Max(longestCandidate[key.uuid2], d)
+	}
+
+	// Filter existing links which are still acceptable
+	kept := []linkKey{}
+	for key := range prevLinks {
+		node1, ok1 := meta.UUIDNodeMap[key.uuid1]
+		node2, ok2 := meta.UUIDNodeMap[key.uuid2]
+		if !ok1 || !ok2 || !isCrossPlane(key) || !node1.LineOfSightWithNodeAtTime(node2, meta.TimeStamp) {
+			continue
+		}
+		d := distance(key)
+		if h.ReleaseRange > 0 && d > h.ReleaseRange {
+			continue
+		}
+		if h.ReleaseAngle > 0 && centralAngle(node1, node2, info) > h.ReleaseAngle {
+			continue
+		}
+		if !candidates[key] &&
+			(d > (1+h.Margin)*longestCandidate[key.uuid1] || d > (1+h.Margin)*longestCandidate[key.uuid2]) {
+			continue
+		}
+		lengths[key] = d
+		kept = append(kept, key)
+	}
+
+	// Fill links greedily: kept links first, then new candidates, both from short to long
+	newLinks := make([]linkKey, 0, len(candidates))
+	for key := range candidates {
+		newLinks = append(newLinks, key)
+	}
+	sortLinks := func(links []linkKey) {
+		sort.Slice(links, func(i, j int) bool {
+			di, dj := lengths[links[i]], lengths[links[j]]
+			if di != dj {
+				return di < dj
+			}
+			return links[i].uuid1+links[i].uuid2 < links[j].uuid1+links[j].uuid2
+		})
+	}
+	sortLinks(kept)
+	sortLinks(newLinks)
+	degree := map[string]int{}
+	crossLinks := map[linkKey]bool{}
+	keptNum := 0
+	for idx, key := range append(kept, newLinks...) {
+		if crossLinks[key] || degree[key.uuid1] >= budget[key.uuid1] || degree[key.uuid2] >= budget[key.uuid2] {
+			continue
+		}
+		crossLinks[key] = true
+		degree[key.uuid1]++
+		degree[key.uuid2]++
+		result[key.uuid1] = append(result[key.uuid1], key.uuid2)
+		if idx < len(kept) {
+			keptNum++
+		}
+	}
+	logrus.WithFields(logrus.Fields{
+		"kept":    keptNum,
+		"changed": len(crossLinks) - keptNum,
+	}).Debug("apply hysteresis to cross-plane links")
+	return result, crossLinks
+}
+
+// centralAngle returns the angle(degree) between two nodes seen from the Earth's center.
+func centralAngle(node1, node2 *satv2.Node, info *OrbitInfo) float64 {
+	x1, y1, z1 := node1.PositionAtTime(info.Metadata.TimeStamp)
+	x2, y2, z2 := node2.PositionAtTime(info.Metadata.TimeStamp)
+	r1 := math.Sqrt(x1*x1 + y1*y1 + z1*z1)
+	r2 := math.Sqrt(x2*x2 + y2*y2 + z2*z2)
+	cos := (x1*x2 + y1*y2 + z1*z2) / (r1 * r2)
+	return math.Acos(math.Max(-1, math.Min(1, cos))) * 180.0 / math.Pi
+}
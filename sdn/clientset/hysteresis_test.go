@@ -0,0 +1,92 @@
+package clientset
+
+import "testing"
+
+func TestHysteresisConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config HysteresisConfig
+		valid  bool
+	}{
+		{"no limit", HysteresisConfig{Margin: 0.2}, true},
+		{"limits", HysteresisConfig{ReleaseRange: 5000, ReleaseAngle: 30, Margin: 0}, true},
+		{"negative range", HysteresisConfig{ReleaseRange: -1}, false},
+		{"angle beyond 180", HysteresisConfig{ReleaseAngle: 181}, false},
+		{"negative margin", HysteresisConfig{Margin: -0.1}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got error %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+func TestHysteresisConfigApply(t *testing.T) {
+	// Satellites on the equator in two planes, whose cross-plane links are 9 or 10 degrees long.
+	// A link spanning 10 degrees is about 1206km and one spanning 9 degrees is about 1086km.
+	info := NewOrbitInfo(newTestParams([]map[string]interface{}{
+		newTestSat("a", 0, 0, 0),
+		newTestSat("d", 0, 1, 19),
+		newTestSat("e", 0, 2, -20),
+		newTestSat("b", 1, 0, 10),
+		newTestSat("c", 1, 1, -9),
+	}, nil), nil, nil)
+	kept := map[linkKey]bool{newLinkKey("a", "b"): true}
+	// The strategy replaces a-b by a-c and b-d, and keeps the in-plane link a-e
+	islMap := map[string][]string{"a": {"c", "e"}, "b": {"d"}}
+
+	tests := []struct {
+		name   string
+		config HysteresisConfig
+		islMap map[string][]string
+		want   []linkKey
+	}{
+		{
+			name:   "kept within margin",
+			config: HysteresisConfig{Margin: 0.2},
+			islMap: islMap,
+			want:   []linkKey{newLinkKey("a", "b")},
+		},
+		{
+			name:   "candidate beats margin",
+			config: HysteresisConfig{Margin: 0.05},
+			islMap: islMap,
+			want:   []linkKey{newLinkKey("a", "c"), newLinkKey("b", "d")},
+		},
+		{
+			name:   "released past release range",
+			config: HysteresisConfig{ReleaseRange: 1150, Margin: 0.2},
+			islMap: islMap,
+			want:   []linkKey{newLinkKey("a", "c"), newLinkKey("b", "d")},
+		},
+		{
+			name:   "released past release angle",
+			config: HysteresisConfig{ReleaseAngle: 9.5, Margin: 0.2},
+			islMap: islMap,
+			want:   []linkKey{newLinkKey("a", "c"), newLinkKey("b", "d")},
+		},
+		{
+			// b has no candidate in this update, so it has no budget for the kept link
+			name:   "budget cap when candidates are lost",
+			config: HysteresisConfig{Margin: 0.2},
+			islMap: map[string][]string{"a": {"c", "e"}},
+			want:   []linkKey{newLinkKey("a", "c")},
+		},
+	}
+	for _, tt := range tests {
+		result, crossLinks := tt.config.Apply(kept, tt.islMap, info)
+		if len(crossLinks) != len(tt.want) {
+			t.Errorf("%s: expected cross-plane links %v, got %v", tt.name, tt.want, crossLinks)
+			continue
+		}
+		for _, key := range tt.want {
+			if !crossLinks[key] {
+				t.Errorf("%s: expected cross-plane links %v, got %v", tt.name, tt.want, crossLinks)
+				break
+			}
+		}
+		if links := result["a"]; len(links) == 0 || links[0] != "e" {
+			t.Errorf("%s: in-plane link a-e is lost: %v", tt.name, result)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package clientset
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultLoadSmoothing is the default weight of the latest utilization in its moving average
+	DefaultLoadSmoothing = 0.3
+
+	// DefaultLoadExpiry is the default age after which throughput reported is regarded as stale
+	DefaultLoadExpiry = time.Minute
+
+	// DefaultLinkCapacity is the default capacity(Mbps) of links whose rate is modelled by neither budget nor bandwidth
+	DefaultLinkCapacity = 100.0
+
+	// DefaultScrapeTimeout is the time limit of scraping throughput, after which throughput reported before is used
+	DefaultScrapeTimeout = 5 * time.Second
+)
+
+// LoadConfig decides how throughput observed on links is turned into utilization for load-aware routing.
+type LoadConfig struct {
+	// Smoothing(0~1] is the weight of the latest utilization in its exponential moving average at each update.
+	// Smaller value damps oscillation of routes, since traffic moved away by routes does not flip weights at once.
+	Smoothing float64
+
+	// Expiry is the age after which throughput reported is regarded as stale, and the link as idle
+	Expiry time.Duration
+
+	// Capacity is the capacity(Mbps) of links whose capacity is unknown to link model
+	Capacity float64
+
+	// ScrapeURL is the address which returns throughput of links in the format of /reportLinkLoad at each update,
+	// empty means throughput is only reported to /reportLinkLoad
+	ScrapeURL string
+}
+
+// LinkLoad is the throughput of a directed link observed by its sender.
+type LinkLoad struct {
+	Src        string  `json:"src"`
+	Dst        string  `json:"dst"`
+	Throughput float64 `json:"throughput"`
+}
+
+// Function: Validate
+// Description: Check smoothing, expiry and capacity.
+func (c *LoadConfig) Validate() error {
+	if c.Smoothing <= 0 || c.Smoothing > 1 {
+		return fmt.Errorf("smoothing %v should be in (0, 1]", c.Smoothing)
+	}
+	if c.Expiry <= 0 {
+		return fmt.Errorf("expiry %v should be positive", c.Expiry)
+	}
+	if c.Capacity <= 0 {
+		return fmt.Errorf("link capacity %v should be positive", c.Capacity)
+	}
+	return nil
+}
+
+// LoadMonitor stores the latest throughput of directed links reported by pods, which is safe for concurrent use.
+type LoadMonitor struct {
+	lock sync.Mutex
+
+	// samples stores [src uuid, dst uuid] -> the latest throughput(Mbps) and when it was reported
+	samples map[[2]string]loadSample
+
+	// client scrapes throughput with DefaultScrapeTimeout
+	client *http.Client
+}
+
+type loadSample struct {
+	throughput float64
+	time       time.Time
+}
+
+func NewLoadMonitor() *LoadMonitor {
+	return &LoadMonitor{
+		samples: make(map[[2]string]loadSample),
+		client:  &http.Client{Timeout: DefaultScrapeTimeout},
+	}
+}
+
+// Function: Report
+// Description: Record throughput of links observed at time t, which replaces throughput reported before.
+// 1. loads: Throughput of directed links.
+// 2. t: The time of observation.
+func (m *LoadMonitor) Report(loads []LinkLoad, t time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, load := range loads {
+		m.samples[[2]string{load.Src, load.Dst}] = loadSample{throughput: load.Throughput, time: t}
+	}
+}
+
+// Function: Throughput
+// Description: Return throughput(Mbps) of the link from src to dst, and 0 if it is not reported within expiry.
+// Stale samples are dropped.
+// 1. src: The sender's uuid.
+// 2. dst: The receiver's uuid.
+// 3. now: The current time.
+// 4. expiry: The age after which samples are stale.
+func (m *LoadMonitor) Throughput(src, dst string, now time.Time, expiry time.Duration) float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := [2]string{src, dst}
+	sample, ok := m.samples[key]
+	if !ok {
+		return 0
+	}
+	if now.Sub(sample.time) > expiry {
+		delete(m.samples, key)
+		return 0
+	}
+	return sample.throughput
+}
+
+// Function: Scrape
+// Description: Fetch throughput of links from url and record it at time t, which fails after DefaultScrapeTimeout.
+// 1. url: The address which returns a JSON list of LinkLoad.
+// 2. t: The time of observation.
+func (m *LoadMonitor) Scrape(url string, t time.Time) error {
+	resp, err := m.client.Get(url)
+	if err != nil {
+		return fmt.Errorf("scrape link load from %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("scrape link load from %s failed: StatusCode %d", url, resp.StatusCode)
+	}
+	loads := []LinkLoad{}
+	if err := json.NewDecoder(resp.Body).Decode(&loads); err != nil {
+		return fmt.Errorf("invalid link load from %s: %v", url, err)
+	}
+	m.Report(loads, t)
+	return nil
+}
+
+// ScrapeLoad fetches throughput of links from the scrape address if load-aware routing scrapes it.
+// It should be called before UpdateNetwork without holding the lock of SDNClient, since the address may be slow.
+func (n *Network) ScrapeLoad() {
+	if n.Config.Load == nil || n.Config.Load.ScrapeURL == "" {
+		return
+	}
+	if err := n.load.Scrape(n.Config.Load.ScrapeURL, time.Now()); err != nil {
+		logrus.WithError(err).Warn("route with link load reported before")
+	}
+}
+
+// computeUtilization returns utilization(0~1) of directed links in TopoGraph, smoothed by moving average
+// with utilization of last update. Links not in TopoGraph are forgotten.
+func (n *Network) computeUtilization() map[[2]int]float64 {
+	config, now := n.Config.Load, time.Now()
+	prev := n.smoothedLoad
+	n.smoothedLoad = make(map[[2]string]float64)
+	result := make(map[[2]int]float64)
+	for from, edges := range n.TopoGraph {
+		for _, edge := range edges {
+			key := [2]string{n.Metadata.IndexUUIDMap[from], n.Metadata.IndexUUIDMap[edge.To]}
+			capacity := n.Config.LinkModel.Capacity(n.GetLinkMetrics(from, edge.To))
+			if capacity <= 0 {
+				capacity = config.Capacity
+			}
+			utilization := math.Min(n.load.Throughput(key[0], key[1], now, config.Expiry)/capacity, 1)
+			// Links that just appeared start from the observed utilization
+			if last, ok := prev[key]; ok {
+				utilization = config.Smoothing*utilization + (1-config.Smoothing)*last
+			}
+			n.smoothedLoad[key] = utilization
+			if utilization > 0 {
+				result[[2]int{from, edge.To}] = utilization
+			}
+		}
+	}
+	return result
+}
+
+// GetUtilization returns utilization(0~1) of the link from idx1 to idx2 by which routes were computed,
+// and 0 if load-aware routing is disabled.
+func (n *Network) GetUtilization(idx1, idx2 int) float64 {
+	return n.utilization[[2]int{idx1, idx2}]
+}
+
+// ReportLoad records throughput of links observed now, and false if load-aware routing is disabled.
+func (n *Network) ReportLoad(loads []LinkLoad) bool {
+	if n.Config.Load == nil {
+		return false
+	}
+	n.load.Report(loads, time.Now())
+	return true
+}
@@ -0,0 +1,161 @@
+package clientset
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"ws/dtn-satellite-sdn/sdn/route"
+	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
+	"ws/dtn-satellite-sdn/sdn/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultHorizonStep is the default interval between snapshots sampled within the look-ahead horizon.
+const DefaultHorizonStep = 10 * time.Second
+
+// LookAheadConfig decides how the contact plan within the look-ahead horizon is predicted,
+// by which routes installed only use links lasting until the next update.
+type LookAheadConfig struct {
+	// Horizon is the look-ahead time over which routes should remain valid in simulated time of timestamps,
+	// 0 means the gap between timestamps of the last two updates, i.e. the update interval scaled by clock speed
+	Horizon time.Duration
+
+	// Step is the interval between snapshots sampled within the horizon in simulated time
+	Step time.Duration
+
+	// Predictor predicts positions of nodes at sample times
+	Predictor PositionPredictor
+}
+
+// PositionPredictor predicts positions of nodes at a future time.
+type PositionPredictor interface {
+	// PredictNodes returns UUID -> node whose latitude/longitude/altitude are at time t.
+	// Nodes missing in the result are regarded as removed at time t.
+	PredictNodes(t time.Time) (map[string]*satv2.Node, error)
+}
+
+// RemotePredictor predicts positions by the position computing module, which propagates satellites from TLE.
+type RemotePredictor struct {
+	// URL is the address of position computing module, which accepts `time` in unix milliseconds
+	URL string
+}
+
+// Function: PredictNodes
+// Description: Fetch locations of nodes at time t from the position computing module.
+// 1. t: The time to predict positions at.
+func (p *RemotePredictor) PredictNodes(t time.Time) (map[string]*satv2.Node, error) {
+	requestURL, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid position url %s: %v", p.URL, err)
+	}
+	query := requestURL.Query()
+	query.Set("time", strconv.FormatInt(t.UnixMilli(), 10))
+	requestURL.RawQuery = query.Encode()
+	params, err := util.Fetch(requestURL.String())
+	if err != nil {
+		return nil, err
+	}
+	_, satellites, stations, missiles, users := ParseParamsQimeng(params)
+	result := map[string]*satv2.Node{}
+	for _, sat := range satellites {
+		node := satv2.NewSatNode(satv2.LOWORBIT, sat)
+		result[node.UUID] = &node
+	}
+	for _, others := range []struct {
+		nodeType satv2.NodeType
+		params   []map[string]interface{}
+	}{{satv2.GROUNDSTATION, stations}, {satv2.MISSILE, missiles}, {satv2.USER, users}} {
+		for _, param := range others.params {
+			node := satv2.NewOtherNode(others.nodeType, param)
+			result[node.UUID] = &node
+		}
+	}
+	return result, nil
+}
+
+// Function: SampleTimes
+// Description: Return times of snapshots after start within the horizon, the last one is at the end of horizon.
+// 1. start: The time of the current snapshot.
+// 2. horizon: The look-ahead time after start.
+func (c *LookAheadConfig) SampleTimes(start time.Time, horizon time.Duration) []time.Time {
+	result := []time.Time{}
+	for offset := c.Step; ; offset += c.Step {
+		// Non-positive step means sampling only the end of horizon
+		if offset >= horizon || c.Step <= 0 {
+			return append(result, start.Add(horizon))
+		}
+		result = append(result, start.Add(offset))
+	}
+}
+
+// predictSnapshots returns the current TopoGraph followed by its snapshots predicted at sample times.
+// A link is kept in a snapshot while it is in line of sight, above the terminal's elevation mask,
+// within max range of max-range topology, and allowed by cross-plane cutoffs at predicted latitudes.
+func (n *Network) predictSnapshots(info *OrbitInfo) ([]route.Graph, error) {
+	var maxRange float64
+	if strategy, ok := n.Config.Topology.(*MaxRangeTopology); ok {
+		maxRange = strategy.MaxRange
+	}
+	horizon := n.Config.LookAhead.Horizon
+	if horizon <= 0 {
+		// Timestamps are in simulated time, which may run faster or slower than the update interval
+		if n.lastTimeStamp.IsZero() || !n.Metadata.TimeStamp.After(n.lastTimeStamp) {
+			return nil, fmt.Errorf("horizon is unknown until timestamps of two updates are seen")
+		}
+		horizon = n.Metadata.TimeStamp.Sub(n.lastTimeStamp)
+	}
+	var crossPlane *crossPlaneFilter
+	if n.Config.CrossPlane != nil {
+		crossPlane = newCrossPlaneFilter(n.Config.CrossPlane, info)
+	}
+	snapshots := []route.Graph{n.TopoGraph}
+	// broken counts links missing from the last snapshot
+	broken := 0
+	for _, t := range n.Config.LookAhead.SampleTimes(n.Metadata.TimeStamp, horizon) {
+		uuidNodeMap, err := n.Config.LookAhead.Predictor.PredictNodes(t)
+		if err != nil {
+			return nil, fmt.Errorf("predict positions at %v failed: %v", t, err)
+		}
+		broken = 0
+		positions := n.computePositions(uuidNodeMap, t)
+		snapshot := route.NewGraph(len(n.TopoGraph))
+		for idx1, edges := range n.TopoGraph {
+			for _, edge := range edges {
+				if edge.To < idx1 {
+					continue
+				}
+				geometry, ok := n.getLinkGeometry(idx1, edge.To, positions)
+				if ok && maxRange > 0 && n.isLowOrbitLink(idx1, edge.To) && geometry.Distance > maxRange {
+					ok = false
+				}
+				if ok && crossPlane != nil &&
+					!crossPlane.AllowAt(n.Metadata.IndexUUIDMap[idx1], n.Metadata.IndexUUIDMap[edge.To], uuidNodeMap) {
+					ok = false
+				}
+				if !ok {
+					broken++
+					continue
+				}
+				latency := n.Config.LinkModel.Evaluate(geometry).Latency
+				snapshot.SetEdge(idx1, edge.To, latency)
+				snapshot.SetEdge(edge.To, idx1, latency)
+			}
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	logrus.WithFields(logrus.Fields{
+		"horizon":      horizon,
+		"snapshots":    len(snapshots),
+		"broken-links": broken,
+	}).Debug("predict topology over look-ahead horizon")
+	return snapshots, nil
+}
+
+func (n *Network) isLowOrbitLink(idx1, idx2 int) bool {
+	node1 := n.Metadata.UUIDNodeMap[n.Metadata.IndexUUIDMap[idx1]]
+	node2 := n.Metadata.UUIDNodeMap[n.Metadata.IndexUUIDMap[idx2]]
+	return node1.Type == satv2.LOWORBIT && node2.Type == satv2.LOWORBIT
+}
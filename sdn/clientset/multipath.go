@@ -0,0 +1,50 @@
+package clientset
+
+import (
+	"fmt"
+
+	"ws/dtn-satellite-sdn/sdn/route"
+)
+
+// MultipathConfig decides how many alternative routes are returned by /getRoute between each pair of nodes.
+// Routes installed to pods are still single path, since podserver(electronicwaste/podserver:v29) only installs
+// the next hop NextIP of each subpath.
+type MultipathConfig struct {
+	// K is the max number of alternative routes returned by /getRoute, including the primary one
+	K int
+
+	// Disjoint is route.LinkDisjoint or route.NodeDisjoint for disjoint alternative routes,
+	// empty means k-shortest alternative routes
+	Disjoint string
+}
+
+// Function: Validate
+// Description: Check the number of paths and disjointness.
+func (c *MultipathConfig) Validate() error {
+	if c.K < 2 {
+		return fmt.Errorf("the number of paths should be at least 2, got %d", c.K)
+	}
+	if c.Disjoint != "" && c.Disjoint != route.LinkDisjoint && c.Disjoint != route.NodeDisjoint {
+		return fmt.Errorf("disjointness should be %s or %s, got %s", route.LinkDisjoint, route.NodeDisjoint, c.Disjoint)
+	}
+	return nil
+}
+
+// GetAlternativeRoutes returns at most K routes from idx1 to idx2 including the primary one by multipath config,
+// and nil if multipath is disabled.
+func (n *Network) GetAlternativeRoutes(idx1, idx2 int) [][]int {
+	if n.Config.Multipath == nil {
+		return nil
+	}
+	var paths []route.Path
+	if n.Config.Multipath.Disjoint == "" {
+		paths = route.KShortestPaths(n.Config.Router.Graph(), idx1, idx2, n.Config.Multipath.K)
+	} else {
+		paths = route.DisjointPaths(n.Config.Router.Graph(), idx1, idx2, n.Config.Multipath.K, n.Config.Multipath.Disjoint)
+	}
+	result := make([][]int, len(paths))
+	for i, path := range paths {
+		result[i] = path.Nodes
+	}
+	return result
+}
@@ -3,7 +3,9 @@ package clientset
 import (
 	"container/list"
 	"fmt"
+	"math"
 	"sync"
+	"time"
 
 	"ws/dtn-satellite-sdn/sdn/link"
 	"ws/dtn-satellite-sdn/sdn/route"
@@ -11,6 +13,7 @@ import (
 	"ws/dtn-satellite-sdn/sdn/util"
 
 	"github.com/sirupsen/logrus"
+	topov1 "github.com/y-young/kube-dtn/api/v1"
 )
 
 type NetworkInterface interface {
@@ -20,28 +23,67 @@ type NetworkInterface interface {
 	GetRouteFromAndTo(idx1, idx2 int) []int
 	GetRouteHops(idx int, idxList []int) []int
 	GetDistance(idx1, idx2 int) float64
+	GetRouteLatency(idx1, idx2 int) float64
+	GetPathLatency(hops []int) float64
+	GetAlternativeRoutes(idx1, idx2 int) [][]int
+	GetLinkMetrics(idx1, idx2 int) link.LinkMetrics
+	GetUtilization(idx1, idx2 int) float64
 	GetSpreadArray(idx int) [][]int
+	GetAccess(idx int) ([]int, bool)
 }
 
 type Network struct {
-	// TopoGraph is the topology connection graph.
-	// TopoGraph[i][j] means that node i and node j are directly connected.
-	TopoGraph [][]bool
+	// TopoGraph is the topology connection graph in adjacency list.
+	// TopoGraph[i] stores nodes directly connected to node i and link latency(ms), in ascending order of index.
+	TopoGraph route.Graph
 
-	// RouteGraph is the route connection graph.
-	// RouteGraph[i][j] means that the next hop of i to j is RouteGraph[i][j].
-	// if i == j, then RouteGraph[i][j] = 0
-	RouteGraph [][]int
+	// RouteGraph is the route table in sparse form.
+	// RouteGraph.NextHop(i, j) is the next hop of i to j, which is i if i == j and route.NoRoute if j is unreachable.
+	RouteGraph *route.RouteTable
 
-	// DistanceMap is the map of two nodes to the distance between them
-	DistanceMap [][]float64
+	// ContactPlan is the contact plan within look-ahead horizon by which RouteGraph is computed,
+	// nil means that routes are computed on current topology.
+	ContactPlan *route.ContactPlan
+
+	// AccessMap is the map of terminal(ground station/missile/user) to satellites it accesses.
+	// An empty list means that the terminal has no coverage.
+	AccessMap map[int][]int
 
 	// Metadata is the metadata of current orbit info
 	Metadata *OrbitMeta
+
+	// linkMetrics stores performance of links in TopoGraph, indexed by [idx1, idx2] with idx1 < idx2
+	linkMetrics map[[2]int]link.LinkMetrics
+
+	// positions stores the position of each node at Metadata.TimeStamp, by which distance is computed on demand.
+	// nil means a hole left by removed node.
+	positions []*[3]float64
+
+	// Config stores options about how to build network
+	Config *SDNConfig
+
+	// crossLinks stores cross-plane links of last update for hysteresis
+	crossLinks map[linkKey]bool
+
+	// load stores throughput of links reported by pods for load-aware routing
+	load *LoadMonitor
+
+	// smoothedLoad stores utilization of directed links [uuid1, uuid2] of last update for moving average
+	smoothedLoad map[[2]string]float64
+
+	// utilization stores utilization of directed links [idx1, idx2] by which routes were computed,
+	// nil means that load-aware routing is disabled
+	utilization map[[2]int]float64
+
+	// lastTimeStamp is Metadata.TimeStamp of last update, from which the look-ahead horizon is derived
+	lastTimeStamp time.Time
 }
 
-func NewNetwork(info *OrbitInfo) *Network {
-	network := Network{}
+func NewNetwork(info *OrbitInfo, config *SDNConfig) *Network {
+	network := Network{
+		Config: config,
+		load:   NewLoadMonitor(),
+	}
 	network.UpdateNetwork(info)
 	return &network
 }
@@ -49,165 +91,256 @@ func NewNetwork(info *OrbitInfo) *Network {
 func (n *Network) UpdateNetwork(info *OrbitInfo) {
 	// 1. Init some variables
 	n.Metadata = info.Metadata
-	totalNodesNum :=
-		n.Metadata.LowOrbitNum + n.Metadata.HighOrbitNum +
-		n.Metadata.GroundStationNum + n.Metadata.MissileNum +
-		n.Metadata.UserNum
+	// Holes left by removed nodes are isolated in TopoGraph
+	totalNodesNum := n.Metadata.IndexNum
 	// totalGroupsNum := len(info.LowOrbitSats) + len(info.HighOrbitSats) + 2
-	n.DistanceMap = make([][]float64, totalNodesNum)
-	n.TopoGraph = make([][]bool, totalNodesNum)
-	for i := 0; i < totalNodesNum; i++ {
-		n.DistanceMap[i] = make([]float64, totalNodesNum)
-		n.TopoGraph[i] = make([]bool, totalNodesNum)
-	}
+	n.TopoGraph = route.NewGraph(totalNodesNum)
+	n.linkMetrics = make(map[[2]int]link.LinkMetrics)
 
-	// 2. Compute DistanceMap(pure, not combined with topology)
-	var wg sync.WaitGroup
-	wg.Add(util.ThreadNums)
-	for threadId := 0; threadId < util.ThreadNums; threadId++ {
-		go func(id int) {
-			// Parition tasks with different nodes
-			for nodeID := id; nodeID < totalNodesNum; nodeID += util.ThreadNums {
-				node := n.Metadata.UUIDNodeMap[n.Metadata.IndexUUIDMap[nodeID]]
-				for idx := 0; idx < totalNodesNum; idx++ {
-					targetNode := n.Metadata.UUIDNodeMap[n.Metadata.IndexUUIDMap[idx]]
-					n.DistanceMap[nodeID][idx] = node.DistanceWithNodeAtTime(targetNode, n.Metadata.TimeStamp)
-				}
+	// 2. Compute positions, distance between nodes is computed from them on demand
+	n.positions = n.computePositions(n.Metadata.UUIDNodeMap, n.Metadata.TimeStamp)
+
+	// 3. Compute low-orbit topology with topology strategy
+	islMap := n.Config.Topology.ComputeISL(info, n.GetDistance)
+	prevLinks := n.crossLinks
+	if n.Config.CrossPlane != nil {
+		filter := newCrossPlaneFilter(n.Config.CrossPlane, info)
+		islMap = filter.Apply(islMap)
+		// Existing links which enter polar region or seam are released as well
+		for key := range prevLinks {
+			if !filter.Allow(key.uuid1, key.uuid2) {
+				delete(prevLinks, key)
 			}
-			wg.Done()
-		}(threadId)
+		}
+	}
+	if n.Config.Hysteresis != nil {
+		islMap, n.crossLinks = n.Config.Hysteresis.Apply(prevLinks, islMap, info)
+	}
+	for uuid1, uuidList := range islMap {
+		uuid1_idx := n.Metadata.UUIDIndexMap[uuid1]
+		for _, uuid2 := range uuidList {
+			uuid2_idx := n.Metadata.UUIDIndexMap[uuid2]
+			// A link exists if either side selects the other
+			n.addLink(uuid1_idx, uuid2_idx)
+		}
 	}
-	wg.Wait()
 
-	// 3. Compute low-orbit topology
-	wg.Add(util.ThreadNums)
-	lowOrbitGroupNum := len(info.LowOrbitSats)
-	lowOrbitGroupKeys := make([]int, 0, lowOrbitGroupNum) // Store trackID in LowOrbitSats
-	for key, _ := range info.LowOrbitSats {
-		lowOrbitGroupKeys = append(lowOrbitGroupKeys, key)
+	// 4. Compute high-orbit topology, including links in high-orbit layer and relay links to low-orbit layer
+	lowOrbitGroups := link.SortGroupsByTrackID(info.LowOrbitSats)
+	highOrbitGroups := link.SortGroupsByTrackID(info.HighOrbitSats)
+	// Like low-orbit satellites, each high-orbit satellite connects to 2 nearest satellites in other planes
+	highOrbitTopoMaps := []map[string][]string{
+		link.GetTopoAmongGroups(highOrbitGroups, 2, n.Metadata.TimeStamp),
+		link.GetCrossLayerTopo(highOrbitGroups, lowOrbitGroups, n.Config.RelayNum, n.Metadata.TimeStamp),
 	}
-	for threadId := 0; threadId < util.ThreadNums; threadId++ {
-		go func(id int) {
-			// Partition tasks with trackID in LowOrbitSats
-			for trackIDIdx := id; trackIDIdx < lowOrbitGroupNum; trackIDIdx += util.ThreadNums {
-				curTrackID := lowOrbitGroupKeys[trackIDIdx]
-				sameOrbitTopoMap := link.GetTopoInGroup(info.LowOrbitSats[curTrackID])
-				diffOrbitTopoMap := link.GetTopoAmongLowOrbitGroup(
-					info.LowOrbitSats[curTrackID], n.Metadata.LowOrbitNum, n.DistanceMap,
-					n.Metadata.IndexUUIDMap, n.Metadata.UUIDIndexMap,
-				)
-				// Apply result to TopoGraph
-				for uuid1, sameUUIDList := range sameOrbitTopoMap {
-					uuid1_idx := n.Metadata.UUIDIndexMap[uuid1]
-					for _, uuid2 := range sameUUIDList {
-						uuid2_idx := n.Metadata.UUIDIndexMap[uuid2]
-						n.TopoGraph[uuid1_idx][uuid2_idx] = true
-					}
-					diffUUIDList := diffOrbitTopoMap[uuid1]
-					for _, uuid2 := range diffUUIDList {
-						uuid2_idx := n.Metadata.UUIDIndexMap[uuid2]
-						n.TopoGraph[uuid1_idx][uuid2_idx] = true
-					}
-				}
-			}
-			wg.Done()
-		}(threadId)
+	for _, group := range highOrbitGroups {
+		highOrbitTopoMaps = append(highOrbitTopoMaps, link.GetTopoInGroup(group, n.Metadata.TimeStamp))
 	}
-	wg.Wait()
-	// Assert symmetry in topoGraph (Low-orbit Satellites)
-	for idx1 := 0; idx1 < n.Metadata.LowOrbitNum; idx1++ {
-		for idx2 := idx1 + 1; idx2 < n.Metadata.LowOrbitNum; idx2++ {
-			n.TopoGraph[idx2][idx1] = n.TopoGraph[idx1][idx2]
+	for _, topoMap := range highOrbitTopoMaps {
+		for uuid1, uuidList := range topoMap {
+			uuid1_idx := n.Metadata.UUIDIndexMap[uuid1]
+			for _, uuid2 := range uuidList {
+				uuid2_idx := n.Metadata.UUIDIndexMap[uuid2]
+				n.addLink(uuid1_idx, uuid2_idx)
+			}
 		}
 	}
 
-	// 4. Compute ground station & missile & users topology (with low-orbit satellites)
-	lowOrbitGroups := []*satv2.Group{}
-	for _, group := range info.LowOrbitSats {
-		lowOrbitGroups = append(lowOrbitGroups, group)
+	// 5. Compute ground station & missile & users topology (with satellites)
+	satGroups := make([]*satv2.Group, 0, len(lowOrbitGroups)+len(highOrbitGroups))
+	satGroups = append(append(satGroups, lowOrbitGroups...), highOrbitGroups...)
+	n.AccessMap = make(map[int][]int)
+	noCoverageList := []string{}
+	for _, terminals := range []*satv2.Group{info.GroundStations, info.Missiles, info.Users} {
+		for idx := range terminals.Nodes {
+			terminal := &terminals.Nodes[idx]
+			terminal_idx := n.Metadata.UUIDIndexMap[terminal.UUID]
+			n.AccessMap[terminal_idx] = []int{}
+			for _, sat_uuid := range link.GetAccessNodes(terminal, satGroups, n.Metadata.TimeStamp) {
+				sat_idx := n.Metadata.UUIDIndexMap[sat_uuid]
+				n.AccessMap[terminal_idx] = append(n.AccessMap[terminal_idx], sat_idx)
+				n.addLink(terminal_idx, sat_idx)
+			}
+			if len(n.AccessMap[terminal_idx]) == 0 {
+				noCoverageList = append(noCoverageList, terminal.UUID)
+			}
+		}
 	}
-	// Iterate GroundStations
-	for _, gs := range info.GroundStations.Nodes {
-		sat_uuid := link.GetMinDistanceNode(&gs, lowOrbitGroups, n.Metadata.TimeStamp)
-		gs_idx, sat_idx := n.Metadata.UUIDIndexMap[gs.UUID], n.Metadata.UUIDIndexMap[sat_uuid]
-		n.TopoGraph[gs_idx][sat_idx] = true
-		n.TopoGraph[sat_idx][gs_idx] = true
+	if len(noCoverageList) > 0 {
+		logrus.WithField("terminals", noCoverageList).Warn("no satellite is visible to terminals")
 	}
-	// Iterate Missiles
-	for _, missile := range info.Missiles.Nodes {
-		sat_uuid := link.GetMinDistanceNode(&missile, lowOrbitGroups, n.Metadata.TimeStamp)
-		missile_idx, sat_idx := n.Metadata.UUIDIndexMap[missile.UUID], n.Metadata.UUIDIndexMap[sat_uuid]
-		n.TopoGraph[missile_idx][sat_idx] = true
-		n.TopoGraph[sat_idx][missile_idx] = true
+
+	// 6. Compute RouteGraph with link latency in TopoGraph, or only over links lasting the look-ahead horizon if enabled
+	// Call router in package route, which may recompute only routes from sources affected by changed links
+	routeGraph := n.TopoGraph
+	n.ContactPlan = nil
+	if n.Config.LookAhead != nil {
+		if snapshots, err := n.predictSnapshots(info); err != nil {
+			logrus.WithError(err).Warn("route on current topology since prediction failed")
+		} else {
+			n.ContactPlan = route.NewContactPlan(snapshots)
+			routeGraph = n.ContactPlan.Lasting
+		}
 	}
-	// Iterate Users
-	for _, user := range info.Users.Nodes {
-		sat_uuid := link.GetMinDistanceNode(&user, lowOrbitGroups, n.Metadata.TimeStamp)
-		user_idx, sat_idx := n.Metadata.UUIDIndexMap[user.UUID], n.Metadata.UUIDIndexMap[sat_uuid]
-		n.TopoGraph[user_idx][sat_idx] = true
-		n.TopoGraph[sat_idx][user_idx] = true
+	// Utilization of links is smoothed across updates, so that routes do not flip as traffic moves
+	if n.Config.Load != nil {
+		n.utilization = n.computeUtilization()
+	} else {
+		n.utilization = nil
 	}
+	n.RouteGraph = n.Config.Router.ComputeRoutes(&route.RouteInput{
+		Graph:       routeGraph,
+		Positions:   n.positions,
+		Utilization: n.utilization,
+	})
+	n.lastTimeStamp = n.Metadata.TimeStamp
 
-	// 5. Compute RouteGraph
-	// Get distanceMap for routing(1e9 for edges not directly connected)
-	distanceMapForRoute := make([][]float64, totalNodesNum)
-	for i := 0; i < totalNodesNum; i++ {
-		distanceMapForRoute[i] = make([]float64, totalNodesNum)
-		for j := 0; j < totalNodesNum; j++ {
-			distanceMapForRoute[i][j] = 1e9
-		}
+	logrus.WithFields(logrus.Fields{
+		"name-map": n.Metadata.IndexUUIDMap,
+		"route-runs": n.RouteGraph.Runs(),
+	}).Debug("update network finished")
+}
+
+// addLink connects node idx1 and node idx2 in TopoGraph, weighted by link latency.
+func (n *Network) addLink(idx1, idx2 int) {
+	if idx1 > idx2 {
+		idx1, idx2 = idx2, idx1
 	}
+	geometry, _ := n.getLinkGeometry(idx1, idx2, n.positions)
+	metrics := n.Config.LinkModel.Evaluate(geometry)
+	n.linkMetrics[[2]int{idx1, idx2}] = metrics
+	n.TopoGraph.SetEdge(idx1, idx2, metrics.Latency)
+	n.TopoGraph.SetEdge(idx2, idx1, metrics.Latency)
+}
+
+// computePositions returns the position of each node in uuidNodeMap at time t,
+// and nil for holes left by removed nodes or nodes missing in uuidNodeMap.
+func (n *Network) computePositions(uuidNodeMap map[string]*satv2.Node, t time.Time) []*[3]float64 {
+	totalNodesNum := n.Metadata.IndexNum
+	positions := make([]*[3]float64, totalNodesNum)
+	var wg sync.WaitGroup
 	wg.Add(util.ThreadNums)
-	for threadID := 0; threadID < util.ThreadNums; threadID++ {
+	for threadId := 0; threadId < util.ThreadNums; threadId++ {
 		go func(id int) {
-			// Partition tasks with TopoGraph's row set.
-			for idx1 := id; idx1 < totalNodesNum; idx1 += util.ThreadNums {
-				for idx2 := 0; idx2 < totalNodesNum; idx2++ {
-					// Set distanceMapForRoute[idx1][idx2]
-					if n.TopoGraph[idx1][idx2] {
-						distanceMapForRoute[idx1][idx2] = n.DistanceMap[idx1][idx2]
-					}
+			// Parition tasks with different nodes
+			for nodeID := id; nodeID < totalNodesNum; nodeID += util.ThreadNums {
+				if node, ok := uuidNodeMap[n.Metadata.IndexUUIDMap[nodeID]]; ok {
+					x, y, z := node.PositionAtTime(t)
+					positions[nodeID] = &[3]float64{x, y, z}
 				}
 			}
 			wg.Done()
-		}(threadID)
+		}(threadId)
 	}
 	wg.Wait()
-	// Call route calculation func in package route
-	n.RouteGraph = route.ComputeRoutes(distanceMapForRoute, util.ThreadNums)
+	return positions
+}
 
-	logrus.WithFields(logrus.Fields{
-		"name-map": n.Metadata.IndexUUIDMap,
-		"route-graph": n.RouteGraph,
-	}).Debug("update network finished")
+// getLinkGeometry returns the geometry of link between node idx1 and node idx2 at given positions for link budget,
+// and false if the link is occluded by the Earth or below the terminal's elevation mask.
+func (n *Network) getLinkGeometry(idx1, idx2 int, positions []*[3]float64) (link.LinkGeometry, bool) {
+	p1, p2 := positions[idx1], positions[idx2]
+	if p1 == nil || p2 == nil {
+		return link.LinkGeometry{}, false
+	}
+	geometry := link.LinkGeometry{Distance: distanceBetween(p1, p2)}
+	node1 := n.Metadata.UUIDNodeMap[n.Metadata.IndexUUIDMap[idx1]]
+	node2 := n.Metadata.UUIDNodeMap[n.Metadata.IndexUUIDMap[idx2]]
+	if isSatellite(node1) && !isSatellite(node2) {
+		node1, node2 = node2, node1
+		p1, p2 = p2, p1
+	}
+	if !isSatellite(node1) && isSatellite(node2) {
+		// node1 is the terminal
+		geometry.Ground = true
+		geometry.TerminalAltitude = node1.Altitude
+		geometry.Elevation = satv2.Elevation(p1[0], p1[1], p1[2], p2[0], p2[1], p2[2])
+		if geometry.Elevation < node1.MinElevation {
+			return geometry, false
+		}
+	}
+	return geometry, satv2.LineOfSight(p1[0], p1[1], p1[2], p2[0], p2[1], p2[2], satv2.GrazingAltitude)
+}
+
+func isSatellite(node *satv2.Node) bool {
+	return node.Type == satv2.LOWORBIT || node.Type == satv2.HIGHORBIT
 }
 
 func (n *Network) CheckConnection(idx1, idx2 int) bool {
-	return n.TopoGraph[idx1][idx2]
+	_, ok := n.TopoGraph.Weight(idx1, idx2)
+	return ok
 }
 
 func (n *Network) GetTopoInAscArray() [][]int {
 	result := [][]int{}
-	totalNodesNum := len(n.TopoGraph)
-	for idx1 := 0; idx1 < totalNodesNum; idx1++ {
-		for idx2 := idx1 + 1; idx2 < totalNodesNum; idx2++ {
-			if n.TopoGraph[idx1][idx2] {
-				result = append(result, []int{idx1, idx2})
+	for idx1, edges := range n.TopoGraph {
+		for _, edge := range edges {
+			if edge.To > idx1 {
+				result = append(result, []int{idx1, edge.To})
 			}
 		}
 	}
 	return result
 }
 
+// GetRouteFromAndTo returns hops(idx1, ..., idx2) of the route from idx1 to idx2, and nil if idx2 is unreachable
+// or the route is broken(e.g. a next hop which is not linked, or a loop).
 func (n *Network) GetRouteFromAndTo(idx1, idx2 int) []int {
 	result := []int{idx1}
+	visited := map[int]bool{idx1: true}
 	for idx1 != idx2 {
-		idx1 = n.RouteGraph[idx1][idx2]
+		// route.NoRoute is never linked
+		next := n.RouteGraph.NextHop(idx1, idx2)
+		if _, ok := n.TopoGraph.Weight(idx1, next); !ok || visited[next] {
+			return nil
+		}
+		idx1 = next
+		visited[idx1] = true
 		result = append(result, idx1)
 	}
 	return result
 }
 
+// GetLinkProperties returns properties(latency, bandwidth, etc) of links in topoAscArray derived by link model.
+func (n *Network) GetLinkProperties(topoAscArray [][]int) []topov1.LinkProperties {
+	result := make([]topov1.LinkProperties, len(topoAscArray))
+	for linkIdx, linkPair := range topoAscArray {
+		result[linkIdx] = n.Config.LinkModel.Properties(n.GetLinkMetrics(linkPair[0], linkPair[1]))
+	}
+	return result
+}
+
+// GetLinkMetrics returns performance(latency, SNR, rate, etc) of the link between idx1 and idx2,
+// and zero value if they are not connected.
+func (n *Network) GetLinkMetrics(idx1, idx2 int) link.LinkMetrics {
+	if idx1 > idx2 {
+		idx1, idx2 = idx2, idx1
+	}
+	return n.linkMetrics[[2]int{idx1, idx2}]
+}
+
+// GetRouteLatency returns the end-to-end latency(ms) along the route from idx1 to idx2, and -1 if there is no route.
+func (n *Network) GetRouteLatency(idx1, idx2 int) float64 {
+	hops := n.GetRouteFromAndTo(idx1, idx2)
+	if hops == nil {
+		return -1
+	}
+	return n.GetPathLatency(hops)
+}
+
+// GetPathLatency returns the end-to-end latency(ms) along hops, and -1 if any pair of adjacent hops is not linked.
+func (n *Network) GetPathLatency(hops []int) float64 {
+	latency := 0.0
+	for i := 1; i < len(hops); i++ {
+		linkLatency, ok := n.TopoGraph.Weight(hops[i-1], hops[i])
+		if !ok {
+			return -1
+		}
+		latency += linkLatency
+	}
+	return latency
+}
+
 func (n *Network) GetRouteHops(idx int, idxList []int) []int {
 	result := []int{}
 	for _, target_idx := range idxList {
@@ -217,8 +350,23 @@ func (n *Network) GetRouteHops(idx int, idxList []int) []int {
 	return result
 }
 
+// GetDistance returns the distance between two nodes, and 0 if either is a hole left by removed node.
 func (n *Network) GetDistance(idx1, idx2 int) float64 {
-	return n.DistanceMap[idx1][idx2]
+	p1, p2 := n.positions[idx1], n.positions[idx2]
+	if p1 == nil || p2 == nil {
+		return 0
+	}
+	return distanceBetween(p1, p2)
+}
+
+func distanceBetween(p1, p2 *[3]float64) float64 {
+	return math.Sqrt((p2[0]-p1[0])*(p2[0]-p1[0]) + (p2[1]-p1[1])*(p2[1]-p1[1]) + (p2[2]-p1[2])*(p2[2]-p1[2]))
+}
+
+// GetAccess returns satellites accessed by terminal idx, and false if idx is not a terminal.
+func (n *Network) GetAccess(idx int) ([]int, bool) {
+	sats, ok := n.AccessMap[idx]
+	return sats, ok
 }
 
 type SpreadLink struct {
@@ -234,13 +382,16 @@ func (n *Network) GetSpreadArray(idx int) []SpreadLink {
 	q.Init().PushBack(idx)
 	visited[idx] = true
 	curLevel := 0
-	lowOrbitMaxIdx := n.Metadata.LowOrbitNum - 1
+	isLowOrbit := map[int]bool{}
+	for _, idx := range n.Metadata.GetIndexesByType(satv2.LOWORBIT) {
+		isLowOrbit[idx] = true
+	}
 	for q.Len() > 0 {
 		curLength := q.Len()
 		for i := 0; i < curLength; i++ {
 			from := q.Front().Value.(int)
-			for to := 0; to <= lowOrbitMaxIdx; to++ {
-				if n.TopoGraph[from][to] && !visited[to] {
+			for _, edge := range n.TopoGraph[from] {
+				if to := edge.To; isLowOrbit[to] && !visited[to] {
 					q.PushBack(to)
 					visited[to] = true
 					result = append(result, SpreadLink{
@@ -0,0 +1,324 @@
+package clientset
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"ws/dtn-satellite-sdn/sdn/link"
+	"ws/dtn-satellite-sdn/sdn/route"
+	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
+	"ws/dtn-satellite-sdn/sdn/util"
+)
+
+func TestNetworkSparseRoutes(t *testing.T) {
+	info := NewOrbitInfo(newTestParams([]map[string]interface{}{
+		newTestSat("sat0", 0, 0, 0),
+		newTestSat("sat1", 0, 1, 20),
+		newTestSat("sat2", 0, 2, 40),
+		newTestSat("sat3", 1, 0, 10),
+		newTestSat("sat4", 1, 1, 30),
+		newTestSat("sat5", 1, 2, 50),
+	}, []string{"user0"}), nil, nil)
+	config := NewDefaultSDNConfig()
+	config.Router, _ = route.NewRouter(route.ShortestRouterName, 0, util.ThreadNums)
+	network := NewNetwork(info, config)
+
+	// Routes on the sparse graph are the same as routes on the dense latency matrix
+	nodeNum := info.Metadata.IndexNum
+	distanceMap := make([][]float64, nodeNum)
+	for idx1 := 0; idx1 < nodeNum; idx1++ {
+		distanceMap[idx1] = make([]float64, nodeNum)
+		for idx2 := 0; idx2 < nodeNum; idx2++ {
+			distanceMap[idx1][idx2], _ = network.TopoGraph.Weight(idx1, idx2)
+		}
+	}
+	routeTable := route.ComputeRoutes(distanceMap, 4)
+	for idx1 := 0; idx1 < nodeNum; idx1++ {
+		for idx2 := 0; idx2 < nodeNum; idx2++ {
+			// Unreachable destinations are route.NoRoute instead of themselves in the sparse route table
+			expected := routeTable[idx1][idx2]
+			if idx1 != idx2 && expected == idx2 && !network.CheckConnection(idx1, idx2) {
+				expected = route.NoRoute
+			}
+			if next := network.RouteGraph.NextHop(idx1, idx2); next != expected {
+				t.Errorf("Expect next hop %d of %d to %d, got %d", expected, idx1, idx2, next)
+			}
+		}
+	}
+	for _, linkPair := range network.GetTopoInAscArray() {
+		if !network.CheckConnection(linkPair[1], linkPair[0]) || network.GetDistance(linkPair[0], linkPair[1]) <= 0 {
+			t.Errorf("Invalid link %v", linkPair)
+		}
+	}
+	if len(network.GetTopoInAscArray()) == 0 {
+		t.Errorf("Expect links in topology graph")
+	}
+}
+
+func TestNetworkRouteLatency(t *testing.T) {
+	info := NewOrbitInfo(newTestParams([]map[string]interface{}{
+		newTestSat("sat0", 0, 0, 0),
+		newTestSat("sat1", 0, 1, 20),
+		newTestSat("sat2", 0, 2, 40),
+	}, []string{}), nil, nil)
+	config := NewDefaultSDNConfig()
+	config.LinkModel.ProcessingDelay = 1
+	network := NewNetwork(info, config)
+
+	src, dst := info.Metadata.UUIDIndexMap["sat0"], info.Metadata.UUIDIndexMap["sat2"]
+	expected, hops := 0.0, network.GetRouteFromAndTo(src, dst)
+	for i := 1; i < len(hops); i++ {
+		expected += network.GetDistance(hops[i-1], hops[i])/link.LightSpeed*1000 + 1
+	}
+	if latency := network.GetRouteLatency(src, dst); len(hops) < 2 || math.Abs(latency-expected) > 1e-9 {
+		t.Errorf("Expect latency %v along %v, got %v", expected, hops, latency)
+	}
+}
+
+func TestNetworkUnreachableRoute(t *testing.T) {
+	// user0 is on the other side of the Earth and sees no satellite
+	info := NewOrbitInfo(newTestParams([]map[string]interface{}{
+		newTestSat("sat0", 0, 0, 0),
+		newTestSat("sat1", 0, 1, 20),
+	}, []string{"user0"}), nil, nil)
+	network := NewNetwork(info, NewDefaultSDNConfig())
+
+	src, dst := info.Metadata.UUIDIndexMap["sat0"], info.Metadata.UUIDIndexMap["user0"]
+	if hops := network.GetRouteFromAndTo(src, dst); hops != nil {
+		t.Errorf("Expect no route to unreachable node, got %v", hops)
+	}
+	if latency := network.GetRouteLatency(src, dst); latency != -1 {
+		t.Errorf("Expect latency -1 to unreachable node, got %v", latency)
+	}
+}
+
+// leavingPredictor predicts that node uuid moves to the other side of the Earth.
+type leavingPredictor struct {
+	info *OrbitInfo
+	uuid string
+}
+
+func (p *leavingPredictor) PredictNodes(t time.Time) (map[string]*satv2.Node, error) {
+	result := map[string]*satv2.Node{}
+	for uuid, node := range p.info.Metadata.UUIDNodeMap {
+		predicted := *node
+		if uuid == p.uuid {
+			predicted.Longitude += 180
+		}
+		result[uuid] = &predicted
+	}
+	return result, nil
+}
+
+// polarPredictor predicts that node uuid moves to latitude, which is close enough to keep its links in line of sight.
+type polarPredictor struct {
+	info     *OrbitInfo
+	uuid     string
+	latitude float64
+}
+
+func (p *polarPredictor) PredictNodes(t time.Time) (map[string]*satv2.Node, error) {
+	result := map[string]*satv2.Node{}
+	for uuid, node := range p.info.Metadata.UUIDNodeMap {
+		predicted := *node
+		if uuid == p.uuid {
+			predicted.Latitude = p.latitude
+		}
+		result[uuid] = &predicted
+	}
+	return result, nil
+}
+
+// routesVia returns whether any route passes node idx as an intermediate hop.
+func routesVia(network *Network, idx int) bool {
+	for src := 0; src < network.RouteGraph.Len(); src++ {
+		for dst := 0; dst < network.RouteGraph.Len(); dst++ {
+			hops := network.GetRouteFromAndTo(src, dst)
+			for i := 1; i < len(hops)-1; i++ {
+				if hops[i] == idx {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func TestNetworkLookAhead(t *testing.T) {
+	info := NewOrbitInfo(newTestParams([]map[string]interface{}{
+		newTestSat("sat0", 0, 0, 0),
+		newTestSat("sat1", 0, 1, 20),
+		newTestSat("sat2", 0, 2, 40),
+		newTestSat("sat3", 1, 0, 10),
+		newTestSat("sat4", 1, 1, 30),
+		newTestSat("sat5", 1, 2, 50),
+	}, []string{}), nil, nil)
+	config := NewDefaultSDNConfig()
+	config.Router, _ = route.NewRouter(route.ShortestRouterName, 0, util.ThreadNums)
+	network := NewNetwork(info, config)
+	sat0, sat1 := info.Metadata.UUIDIndexMap["sat0"], info.Metadata.UUIDIndexMap["sat1"]
+	if !routesVia(network, sat1) {
+		t.Fatalf("Expect routes via sat1 on current topology")
+	}
+
+	// sat1 is about to leave, so routes avoid it, and no route to it lasts the horizon
+	config.LookAhead = &LookAheadConfig{
+		Horizon:   time.Minute,
+		Step:      DefaultHorizonStep,
+		Predictor: &leavingPredictor{info: info, uuid: "sat1"},
+	}
+	network.UpdateNetwork(info)
+	if routesVia(network, sat1) {
+		t.Errorf("Expect routes to avoid leaving sat1")
+	}
+	if hops := network.GetRouteFromAndTo(sat0, sat1); hops != nil {
+		t.Errorf("Expect sat1 to be unreachable instead of routed over breaking links, got %v", hops)
+	}
+	if network.ContactPlan == nil || network.ContactPlan.Lasts(sat0, sat1) {
+		t.Errorf("Expect link sat0-sat1 to break in the contact plan")
+	}
+}
+
+func TestNetworkLookAheadDerivedHorizon(t *testing.T) {
+	info := NewOrbitInfo(newTestParams([]map[string]interface{}{
+		newTestSat("sat0", 0, 0, 0),
+		newTestSat("sat1", 0, 1, 20),
+		newTestSat("sat2", 0, 2, 40),
+		newTestSat("sat3", 1, 0, 10),
+		newTestSat("sat4", 1, 1, 30),
+		newTestSat("sat5", 1, 2, 50),
+	}, []string{}), nil, nil)
+	config := NewDefaultSDNConfig()
+	config.Router, _ = route.NewRouter(route.ShortestRouterName, 0, util.ThreadNums)
+	config.LookAhead = &LookAheadConfig{
+		Step:      DefaultHorizonStep,
+		Predictor: &leavingPredictor{info: info, uuid: "sat1"},
+	}
+	// The horizon is unknown at the first update, so routes are computed on current topology
+	network := NewNetwork(info, config)
+	sat1 := info.Metadata.UUIDIndexMap["sat1"]
+	if !routesVia(network, sat1) {
+		t.Fatalf("Expect routes via sat1 before the horizon is known")
+	}
+
+	// The horizon is the gap between timestamps, however fast the simulated clock runs
+	info.Metadata.TimeStamp = info.Metadata.TimeStamp.Add(time.Minute)
+	network.UpdateNetwork(info)
+	if routesVia(network, sat1) {
+		t.Errorf("Expect routes to avoid leaving sat1 within the derived horizon")
+	}
+}
+
+func TestNetworkLookAheadCrossPlane(t *testing.T) {
+	info := NewOrbitInfo(newTestParams([]map[string]interface{}{
+		newTestSat("sat0", 0, 0, 0),
+		newTestSat("sat1", 0, 1, 20),
+		newTestSat("sat2", 0, 2, 40),
+		newTestSat("sat3", 1, 0, 10),
+		newTestSat("sat4", 1, 1, 30),
+		newTestSat("sat5", 1, 2, 50),
+	}, []string{}), nil, nil)
+	sat3 := info.Metadata.UUIDIndexMap["sat3"]
+	crossPlaneLinks := func(network *Network) []int {
+		result := []int{}
+		for _, edge := range network.TopoGraph[sat3] {
+			if info.Metadata.UUIDNodeMap[info.Metadata.IndexUUIDMap[edge.To]].TrackID == 0 {
+				result = append(result, edge.To)
+			}
+		}
+		return result
+	}
+
+	// sat3 enters polar region within the horizon, while its links stay in line of sight
+	for _, polar := range []bool{false, true} {
+		config := NewDefaultSDNConfig()
+		config.Router, _ = route.NewRouter(route.ShortestRouterName, 0, util.ThreadNums)
+		config.CrossPlane = &CrossPlaneConfig{}
+		if polar {
+			config.CrossPlane.PolarLatitude = 10
+		}
+		config.LookAhead = &LookAheadConfig{
+			Horizon:   time.Minute,
+			Step:      DefaultHorizonStep,
+			Predictor: &polarPredictor{info: info, uuid: "sat3", latitude: 12},
+		}
+		network := NewNetwork(info, config)
+		neighbours := crossPlaneLinks(network)
+		if len(neighbours) == 0 {
+			t.Fatalf("Expect cross-plane links of sat3 at present")
+		}
+		for _, neighbour := range neighbours {
+			if lasts := network.ContactPlan.Lasts(sat3, neighbour); lasts == polar {
+				t.Errorf("Expect cross-plane link sat3-%d to last %v with polar cutoff %v", neighbour, !polar, polar)
+			}
+		}
+		if !polar {
+			continue
+		}
+		for src := 0; src < network.RouteGraph.Len(); src++ {
+			for dst := 0; dst < network.RouteGraph.Len(); dst++ {
+				hops := network.GetRouteFromAndTo(src, dst)
+				for i := 1; i < len(hops); i++ {
+					if (hops[i-1] == sat3 || hops[i] == sat3) && !network.ContactPlan.Lasts(hops[i-1], hops[i]) {
+						t.Errorf("Expect routes to avoid cross-plane links of sat3 entering polar region, got %v", hops)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestNetworkMultipath(t *testing.T) {
+	info := NewOrbitInfo(newTestParams([]map[string]interface{}{
+		newTestSat("sat0", 0, 0, 0),
+		newTestSat("sat1", 0, 1, 20),
+		newTestSat("sat2", 0, 2, 40),
+		newTestSat("sat3", 1, 0, 10),
+		newTestSat("sat4", 1, 1, 30),
+		newTestSat("sat5", 1, 2, 50),
+	}, []string{}), nil, nil)
+	config := NewDefaultSDNConfig()
+	config.Multipath = &MultipathConfig{K: 3}
+	network := NewNetwork(info, config)
+
+	sat0, sat5 := info.Metadata.UUIDIndexMap["sat0"], info.Metadata.UUIDIndexMap["sat5"]
+	alternatives := network.GetAlternativeRoutes(sat0, sat5)
+	if len(alternatives) != 3 || !reflect.DeepEqual(alternatives[0], network.GetRouteFromAndTo(sat0, sat5)) {
+		t.Errorf("Expect 3 routes starting with the primary one, got %v", alternatives)
+	}
+}
+
+func TestNetworkLoadAware(t *testing.T) {
+	info := NewOrbitInfo(newTestParams([]map[string]interface{}{
+		newTestSat("sat0", 0, 0, 0),
+		newTestSat("sat1", 0, 1, 20),
+		newTestSat("sat2", 0, 2, 40),
+		newTestSat("sat3", 1, 0, 10),
+		newTestSat("sat4", 1, 1, 30),
+		newTestSat("sat5", 1, 2, 50),
+	}, []string{}), nil, nil)
+	config := NewDefaultSDNConfig()
+	config.Router, _ = route.NewRouter(route.LoadAwareRouterName, 0, util.ThreadNums)
+	config.Load = &LoadConfig{Smoothing: 0.5, Expiry: time.Minute, Capacity: 100}
+	network := NewNetwork(info, config)
+	sat0, sat5 := info.Metadata.UUIDIndexMap["sat0"], info.Metadata.UUIDIndexMap["sat5"]
+	hops := network.GetRouteFromAndTo(sat0, sat5)
+
+	// Utilization approaches the observed one gradually
+	congested := LinkLoad{Src: "sat0", Dst: info.Metadata.IndexUUIDMap[hops[1]], Throughput: 100}
+	for _, expected := range []float64{0.5, 0.75} {
+		network.ReportLoad([]LinkLoad{congested})
+		network.UpdateNetwork(info)
+		if u := network.GetUtilization(hops[0], hops[1]); math.Abs(u-expected) > 1e-9 {
+			t.Errorf("Expect utilization %v, got %v", expected, u)
+		}
+		if rerouted := network.GetRouteFromAndTo(sat0, sat5); rerouted[1] == hops[1] {
+			t.Errorf("Expect routes to avoid congested link %v, got %v", hops[:2], rerouted)
+		}
+	}
+	if u := network.GetUtilization(hops[1], hops[0]); u != 0 {
+		t.Errorf("Expect the reverse link to be idle, got %v", u)
+	}
+}
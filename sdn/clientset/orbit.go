@@ -4,11 +4,16 @@ import (
 	"sort"
 	"time"
 
+	"ws/dtn-satellite-sdn/sdn/filter"
+	"ws/dtn-satellite-sdn/sdn/ipam"
+	"ws/dtn-satellite-sdn/sdn/link"
 	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
+
+	"github.com/sirupsen/logrus"
 )
 
 type OrbitInterface interface {
-	Update(params map[string]interface{})
+	Update(params map[string]interface{}) (added, removed []string)
 	UpdateMeta()
 	GetUUIDIndexMap() map[string]int
 	GetIndexUUIDMap() map[int]string
@@ -41,6 +46,11 @@ type OrbitMeta struct {
 
 	// UsersNum stores the number of users
 	UserNum int
+
+	// IndexNum is the size of index space.
+	// Indices of removed nodes are left as holes until they are reused by new nodes,
+	// so that indices(and IPs derived from them) of existing nodes keep stable.
+	IndexNum int
 }
 
 type OrbitInfo struct {
@@ -61,6 +71,15 @@ type OrbitInfo struct {
 
 	// Users stores the group of user nodes
 	Users *satv2.Group
+
+	// filter decides which satellites joining later are kept
+	filter *filter.SatelliteFilter
+
+	// rejected stores uuids of satellites rejected by filter
+	rejected map[string]bool
+
+	// ipam assigns persistent indices to nodes, nil means indices are assigned from 0 each time
+	ipam *ipam.Allocator
 }
 
 // Function: ParseParamsQimeng
@@ -94,7 +113,9 @@ func ParseParamsQimeng(params map[string]interface{}) (unixTimeStamp int64, sate
 // Function: NewOrbitInfo
 // Description: Create orbit info with JSON params
 // 1. params: Message from Qimeng
-func NewOrbitInfo(params map[string]interface{}) *OrbitInfo {
+// 2. filter: Satellite filter, nil means keeping all satellites
+// 3. allocator: IPAM which assigns persistent indices, nil means indices are assigned from 0
+func NewOrbitInfo(params map[string]interface{}, filter *filter.SatelliteFilter, allocator *ipam.Allocator) *OrbitInfo {
 	unixTimeStamp, satellites, stations, missiles, users := ParseParamsQimeng(params)
 	info := OrbitInfo{
 		LowOrbitSats:   make(map[int]*satv2.Group),
@@ -103,27 +124,27 @@ func NewOrbitInfo(params map[string]interface{}) *OrbitInfo {
 		Missiles:       satv2.NewOtherGroup(satv2.MISSILE),
 		Users:			satv2.NewOtherGroup(satv2.USER),
 		Metadata:       &OrbitMeta{},
+		filter:         filter,
+		rejected:       make(map[string]bool),
+		ipam:           allocator,
 	}
 	// Initialize low-orbit and high-orbit satellite groups
 	for _, sat := range satellites {
 		node := satv2.NewSatNode(satv2.LOWORBIT, sat)
-		if node.UUID == "53302" || node.UUID == "53347" {
-			continue
-		}
-		// Classify satellites by altitude 30000km
-		if node.Altitude < 30000 {
+		// Classify satellites by altitude
+		if node.Altitude < satv2.HighOrbitAltitude {
 			trackID := node.TrackID
 			if _, ok := info.LowOrbitSats[trackID]; !ok {
 				info.LowOrbitSats[trackID] = satv2.NewSatGroup(satv2.LOWORBIT, trackID)
 			}
 			info.LowOrbitSats[trackID].Nodes = append(info.LowOrbitSats[trackID].Nodes, node)
 		} else {
-			// trackID := node.TrackID
-			// node.Type = satv2.HIGHORBIT
-			// if _, ok := info.HighOrbitSats[trackID]; !ok {
-			// 	info.HighOrbitSats[trackID] = satv2.NewSatGroup(satv2.HIGHORBIT, trackID)
-			// }
-			// info.HighOrbitSats[trackID].Nodes = append(info.HighOrbitSats[trackID].Nodes, node)
+			trackID := node.TrackID
+			node.Type = satv2.HIGHORBIT
+			if _, ok := info.HighOrbitSats[trackID]; !ok {
+				info.HighOrbitSats[trackID] = satv2.NewSatGroup(satv2.HIGHORBIT, trackID)
+			}
+			info.HighOrbitSats[trackID].Nodes = append(info.HighOrbitSats[trackID].Nodes, node)
 		}
 	}
 	for _, group := range info.LowOrbitSats {
@@ -132,6 +153,12 @@ func NewOrbitInfo(params map[string]interface{}) *OrbitInfo {
 	for _, group := range info.HighOrbitSats {
 		sort.Sort(group)
 	}
+	// Remove satellites rejected by filter, satellites in later updates are looked up by existing groups
+	for _, groups := range []map[int]*satv2.Group{info.LowOrbitSats, info.HighOrbitSats} {
+		for _, uuid := range FilterGroups(groups, filter, time.UnixMilli(unixTimeStamp)) {
+			info.rejected[uuid] = true
+		}
+	}
 	// Initialzie station groups
 	for _, station := range stations {
 		info.GroundStations.Nodes = append(
@@ -156,127 +183,284 @@ func NewOrbitInfo(params map[string]interface{}) *OrbitInfo {
 
 	// Update Metadata
 	info.Metadata = info.UpdateMeta(unixTimeStamp)
+	info.saveIPAM()
 
 	return &info
 }
 
 // Function: Update
 // Description: Update orbit info with JSON params, also timestamp & uuidNodeMap in orbit metadata.
+// Nodes missing in params are removed and nodes appearing for the first time are added,
+// while indices of the other nodes keep unchanged.
+// Return uuids of nodes added and removed in this update.
 // 1. params: Message from Qimeng
-func (o *OrbitInfo) Update(params map[string]interface{}) {
+func (o *OrbitInfo) Update(params map[string]interface{}) (added, removed []string) {
 	unixTimeStamp, satellites, stations, missiles, users := ParseParamsQimeng(params)
-	// Initialize uuid->Node map
+	// Initialize uuid->Node map, newNodes keeps the order in params for deterministic indices
 	uuidNodeMap := make(map[string]satv2.Node)
+	newNodes := []satv2.Node{}
+	addNode := func(node satv2.Node) {
+		uuidNodeMap[node.UUID] = node
+		if _, ok := o.Metadata.UUIDIndexMap[node.UUID]; !ok && !o.rejected[node.UUID] {
+			newNodes = append(newNodes, node)
+		}
+	}
 	for _, sat := range satellites {
 		node := satv2.NewSatNode(satv2.LOWORBIT, sat)
-		if node.UUID == "53302" || node.UUID == "53347" {
-			continue
-		}
-		// Classify satellites by altitude 30000km
-		if node.Altitude < 30000 {
-			uuidNodeMap[node.UUID] = node
-		} else {
+		// Classify satellites by altitude
+		if node.Altitude >= satv2.HighOrbitAltitude {
 			node.Type = satv2.HIGHORBIT
-			uuidNodeMap[node.UUID] = node
 		}
+		addNode(node)
 	}
 	for _, station := range stations {
-		node := satv2.NewOtherNode(satv2.GROUNDSTATION, station)
-		uuidNodeMap[node.UUID] = node
+		addNode(satv2.NewOtherNode(satv2.GROUNDSTATION, station))
 	}
 	for _, missile := range missiles {
-		node := satv2.NewOtherNode(satv2.MISSILE, missile)
-		uuidNodeMap[node.UUID] = node
+		addNode(satv2.NewOtherNode(satv2.MISSILE, missile))
 	}
 	for _, user := range users {
-		node := satv2.NewOtherNode(satv2.USER, user)
-		uuidNodeMap[node.UUID] = node
+		addNode(satv2.NewOtherNode(satv2.USER, user))
 	}
 	// Update variables in o.Metadata
 	o.Metadata.TimeStamp = time.UnixMilli(unixTimeStamp)
-	// Update node and uuidNodeMap in o
-	for idx1, group := range o.LowOrbitSats {
-		for idx2, node := range group.Nodes {
-			o.LowOrbitSats[idx1].Nodes[idx2] = uuidNodeMap[node.UUID]
-			o.Metadata.UUIDNodeMap[node.UUID] = &o.LowOrbitSats[idx1].Nodes[idx2]
+	// Remove nodes missing in params and update the others
+	for _, groups := range []map[int]*satv2.Group{o.LowOrbitSats, o.HighOrbitSats} {
+		for trackID, group := range groups {
+			removed = append(removed, o.updateGroup(group, uuidNodeMap)...)
+			if group.Len() == 0 {
+				delete(groups, trackID)
+			}
+		}
+	}
+	for _, group := range []*satv2.Group{o.GroundStations, o.Missiles, o.Users} {
+		removed = append(removed, o.updateGroup(group, uuidNodeMap)...)
+	}
+	o.moveAcrossLayers()
+	// Slots of nodes removed long ago are free for nodes appearing for the first time
+	if o.ipam != nil {
+		now := time.Now()
+		o.ipam.Release(removed, now)
+		o.ipam.Reclaim(now)
+	}
+	// Add nodes appearing for the first time
+	for _, node := range newNodes {
+		if o.addNode(node) {
+			added = append(added, node.UUID)
+		}
+	}
+	o.updateNodeMap()
+	o.saveIPAM()
+	return added, removed
+}
+
+// saveIPAM persists indices of nodes, which is retried in next update if failed.
+func (o *OrbitInfo) saveIPAM() {
+	if o.ipam == nil {
+		return
+	}
+	if err := o.ipam.Save(); err != nil {
+		logrus.WithError(err).Error("save ipam failed")
+	}
+}
+
+// updateGroup updates nodes in group with uuidNodeMap, and removes nodes missing in uuidNodeMap.
+// Return uuids of removed nodes.
+func (o *OrbitInfo) updateGroup(group *satv2.Group, uuidNodeMap map[string]satv2.Node) []string {
+	removed := []string{}
+	nodes := group.Nodes[:0]
+	for _, node := range group.Nodes {
+		if newNode, ok := uuidNodeMap[node.UUID]; ok {
+			nodes = append(nodes, newNode)
+		} else {
+			removed = append(removed, node.UUID)
+			delete(o.Metadata.IndexUUIDMap, o.Metadata.UUIDIndexMap[node.UUID])
+			delete(o.Metadata.UUIDIndexMap, node.UUID)
+		}
+	}
+	group.Nodes = nodes
+	return removed
+}
+
+// moveAcrossLayers moves satellites which have crossed HighOrbitAltitude to the group of their new layer,
+// and their indices keep unchanged.
+func (o *OrbitInfo) moveAcrossLayers() {
+	moved := []satv2.Node{}
+	for _, groups := range []map[int]*satv2.Group{o.LowOrbitSats, o.HighOrbitSats} {
+		for trackID, group := range groups {
+			nodes := group.Nodes[:0]
+			for _, node := range group.Nodes {
+				if (node.Type == satv2.HIGHORBIT) == (group.Type == satv2.HIGHORBIT) {
+					nodes = append(nodes, node)
+				} else {
+					moved = append(moved, node)
+				}
+			}
+			group.Nodes = nodes
+			if group.Len() == 0 {
+				delete(groups, trackID)
+			}
 		}
 	}
-	for idx1, group := range o.HighOrbitSats {
-		for idx2, node := range group.Nodes {
-			o.HighOrbitSats[idx1].Nodes[idx2] = uuidNodeMap[node.UUID]
-			o.Metadata.UUIDNodeMap[node.UUID] = &o.HighOrbitSats[idx1].Nodes[idx2]
+	for _, node := range moved {
+		groups, groupType := o.LowOrbitSats, satv2.GroupType(satv2.LOWORBIT)
+		if node.Type == satv2.HIGHORBIT {
+			groups, groupType = o.HighOrbitSats, satv2.HIGHORBIT
+		}
+		group := groups[node.TrackID]
+		if group == nil {
+			group = satv2.NewSatGroup(groupType, node.TrackID)
+			groups[node.TrackID] = group
+		}
+		group.Nodes = append(group.Nodes, node)
+		sort.Sort(group)
+		logrus.WithFields(logrus.Fields{
+			"uuid":     node.UUID,
+			"altitude": node.Altitude,
+		}).Info("satellite moves across high-orbit altitude")
+	}
+}
+
+// addNode puts a new node into its group and allocates the smallest free index to it.
+// Return false if the node is a satellite rejected by filter.
+func (o *OrbitInfo) addNode(node satv2.Node) bool {
+	var group *satv2.Group
+	switch node.Type {
+	case satv2.LOWORBIT, satv2.HIGHORBIT:
+		groups, groupType := o.LowOrbitSats, satv2.GroupType(satv2.LOWORBIT)
+		if node.Type == satv2.HIGHORBIT {
+			groups, groupType = o.HighOrbitSats, satv2.HIGHORBIT
+		}
+		group = groups[node.TrackID]
+		if group == nil {
+			group = satv2.NewSatGroup(groupType, node.TrackID)
+		}
+		if o.filter != nil {
+			// The inclination is estimated from the plane that the satellite joins
+			candidate := satv2.NewSatGroup(groupType, node.TrackID)
+			candidate.Nodes = append(append(candidate.Nodes, group.Nodes...), node)
+			sort.Sort(candidate)
+			if !o.filter.Match(filter.FilterTarget{
+				UUID:        node.UUID,
+				Altitude:    node.Altitude,
+				Inclination: groupInclination(candidate, o.Metadata.TimeStamp),
+				TrackID:     node.TrackID,
+			}) {
+				o.rejected[node.UUID] = true
+				return false
+			}
+		}
+		groups[node.TrackID] = group
+		group.Nodes = append(group.Nodes, node)
+		sort.Sort(group)
+	case satv2.GROUNDSTATION:
+		group = o.GroundStations
+		group.Nodes = append(group.Nodes, node)
+	case satv2.MISSILE:
+		group = o.Missiles
+		group.Nodes = append(group.Nodes, node)
+	default:
+		group = o.Users
+		group.Nodes = append(group.Nodes, node)
+	}
+	idx := 0
+	if o.ipam != nil {
+		idx = o.ipam.Allocate([]string{node.UUID})[node.UUID]
+	} else {
+		for ; idx < o.Metadata.IndexNum; idx++ {
+			if _, ok := o.Metadata.IndexUUIDMap[idx]; !ok {
+				break
+			}
 		}
 	}
-	for idx, station := range o.GroundStations.Nodes {
-		o.GroundStations.Nodes[idx] = uuidNodeMap[station.UUID]
-		o.Metadata.UUIDNodeMap[station.UUID] = &o.GroundStations.Nodes[idx]
+	if idx >= o.Metadata.IndexNum {
+		o.Metadata.IndexNum = idx + 1
 	}
-	for idx, missile := range o.Missiles.Nodes {
-		o.Missiles.Nodes[idx] = uuidNodeMap[missile.UUID]
-		o.Metadata.UUIDNodeMap[missile.UUID] = &o.Missiles.Nodes[idx]
+	o.Metadata.IndexUUIDMap[idx] = node.UUID
+	o.Metadata.UUIDIndexMap[node.UUID] = idx
+	return true
+}
+
+// updateNodeMap points UUIDNodeMap to nodes in groups and recounts nodes of each type.
+func (o *OrbitInfo) updateNodeMap() {
+	meta := o.Metadata
+	meta.UUIDNodeMap = make(map[string]*satv2.Node)
+	meta.LowOrbitNum, meta.HighOrbitNum = 0, 0
+	for _, groups := range []map[int]*satv2.Group{o.LowOrbitSats, o.HighOrbitSats} {
+		for _, group := range groups {
+			for idx := range group.Nodes {
+				meta.UUIDNodeMap[group.Nodes[idx].UUID] = &group.Nodes[idx]
+			}
+			if group.Type == satv2.HIGHORBIT {
+				meta.HighOrbitNum += group.Len()
+			} else {
+				meta.LowOrbitNum += group.Len()
+			}
+		}
 	}
-	for idx, user := range o.Users.Nodes {
-		o.Users.Nodes[idx] = uuidNodeMap[user.UUID]
-		o.Metadata.UUIDNodeMap[user.UUID] = &o.Users.Nodes[idx]
+	for _, group := range []*satv2.Group{o.GroundStations, o.Missiles, o.Users} {
+		for idx := range group.Nodes {
+			meta.UUIDNodeMap[group.Nodes[idx].UUID] = &group.Nodes[idx]
+		}
 	}
+	meta.GroundStationNum = o.GroundStations.Len()
+	meta.MissileNum = o.Missiles.Len()
+	meta.UserNum = o.Users.Len()
 }
 
 // Function: UpdateMeta
-// Description: Update metadata when orbit info is created
+// Description: Update metadata when orbit info is created.
+// Nodes are ordered by type, trackID and inTrackID, so that indices never depend on map iteration order.
 // 1. unixTimeStamp: the timestamp passed by Qimeng
 func (o *OrbitInfo) UpdateMeta(unixTimeStamp int64) *OrbitMeta {
-	cur_idx := 0
 	meta := OrbitMeta{
 		TimeStamp:        time.UnixMilli(unixTimeStamp),
 		IndexUUIDMap:     make(map[int]string),
 		UUIDIndexMap:     make(map[string]int),
 		UUIDNodeMap:      make(map[string]*satv2.Node),
-		LowOrbitNum:      0,
-		HighOrbitNum:     0,
-		GroundStationNum: 0,
-		MissileNum:       0,
-		UserNum: 		  0,		
-	}
-	// Since we do not modify bucket, we can get same result each time we iterate map.
-	for idx1, group := range o.LowOrbitSats {
-		for idx2, node := range group.Nodes {
-			meta.LowOrbitNum++
-			meta.IndexUUIDMap[cur_idx] = node.UUID
-			meta.UUIDIndexMap[node.UUID] = cur_idx
-			meta.UUIDNodeMap[node.UUID] = &o.LowOrbitSats[idx1].Nodes[idx2]
-			cur_idx++
+	}
+	nodes := []*satv2.Node{}
+	for _, groups := range []map[int]*satv2.Group{o.LowOrbitSats, o.HighOrbitSats} {
+		for _, group := range link.SortGroupsByTrackID(groups) {
+			for idx := range group.Nodes {
+				nodes = append(nodes, &group.Nodes[idx])
+			}
 		}
 	}
-	for idx1, group := range o.HighOrbitSats {
-		for idx2, node := range group.Nodes {
-			meta.HighOrbitNum++
-			meta.IndexUUIDMap[cur_idx] = node.UUID
-			meta.UUIDIndexMap[node.UUID] = cur_idx
-			meta.UUIDNodeMap[node.UUID] = &o.HighOrbitSats[idx1].Nodes[idx2]
-			cur_idx++
+	for _, group := range []*satv2.Group{o.GroundStations, o.Missiles, o.Users} {
+		for idx := range group.Nodes {
+			nodes = append(nodes, &group.Nodes[idx])
 		}
 	}
-	for idx, node := range o.GroundStations.Nodes {
-		meta.GroundStationNum++
-		meta.IndexUUIDMap[cur_idx] = node.UUID
-		meta.UUIDIndexMap[node.UUID] = cur_idx
-		meta.UUIDNodeMap[node.UUID] = &o.GroundStations.Nodes[idx]
-		cur_idx++
-	}
-	for idx, node := range o.Missiles.Nodes {
-		meta.MissileNum++
-		meta.IndexUUIDMap[cur_idx] = node.UUID
-		meta.UUIDIndexMap[node.UUID] = cur_idx
-		meta.UUIDNodeMap[node.UUID] = &o.Missiles.Nodes[idx]
-		cur_idx++
-	}
-	for idx, node := range o.Users.Nodes {
-		meta.UserNum++
-		meta.IndexUUIDMap[cur_idx] = node.UUID
-		meta.UUIDIndexMap[node.UUID] = cur_idx
-		meta.UUIDNodeMap[node.UUID] = &o.Users.Nodes[idx]
-		cur_idx++
+	// Assign indices(persistent ones if IPAM is enabled)
+	uuids := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		uuids = append(uuids, node.UUID)
 	}
+	slots := map[string]int{}
+	if o.ipam != nil {
+		slots = o.ipam.Allocate(uuids)
+		// Slots of nodes absent since last run are reclaimed after grace period
+		o.ipam.Retain(uuids, time.Now())
+	} else {
+		for idx, uuid := range uuids {
+			slots[uuid] = idx
+		}
+	}
+	for _, node := range nodes {
+		idx := slots[node.UUID]
+		meta.IndexUUIDMap[idx] = node.UUID
+		meta.UUIDIndexMap[node.UUID] = idx
+		meta.UUIDNodeMap[node.UUID] = node
+		if idx >= meta.IndexNum {
+			meta.IndexNum = idx + 1
+		}
+	}
+	meta.LowOrbitNum = len(meta.GetIndexesByType(satv2.LOWORBIT))
+	meta.HighOrbitNum = len(meta.GetIndexesByType(satv2.HIGHORBIT))
+	meta.GroundStationNum = o.GroundStations.Len()
+	meta.MissileNum = o.Missiles.Len()
+	meta.UserNum = o.Users.Len()
 	return &meta
 }
 
@@ -287,3 +471,17 @@ func (o *OrbitInfo) GetIndexUUIDMap() map[int]string {
 func (o *OrbitInfo) GetUUIDIndexMap() map[string]int {
 	return o.Metadata.UUIDIndexMap
 }
+
+// Function: GetIndexesByType
+// Description: Return indices of nodes with given type in ascending order.
+// 1. nodeType: The type of nodes, e.g. satv2.LOWORBIT.
+func (m *OrbitMeta) GetIndexesByType(nodeType satv2.NodeType) []int {
+	result := []int{}
+	for uuid, node := range m.UUIDNodeMap {
+		if node.Type == nodeType {
+			result = append(result, m.UUIDIndexMap[uuid])
+		}
+	}
+	sort.Ints(result)
+	return result
+}
@@ -0,0 +1,119 @@
+package clientset
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
+)
+
+func newTestParams(sats []map[string]interface{}, users []string) map[string]interface{} {
+	satList, userList := []interface{}{}, []interface{}{}
+	for _, sat := range sats {
+		satList = append(satList, sat)
+	}
+	for _, user := range users {
+		userList = append(userList, map[string]interface{}{
+			"uuid": user, "lat": 31.0, "lon": 121.0, "height": 0.0,
+		})
+	}
+	return map[string]interface{}{
+		"unixTimeStamp": 1700000000000.0,
+		"satellites":    satList,
+		"userDatas":     userList,
+	}
+}
+
+func newTestSat(uuid string, trackID, inTrackID int, lon float64) map[string]interface{} {
+	return map[string]interface{}{
+		"uuid": uuid, "trackID": float64(trackID), "inTrackID": float64(inTrackID),
+		"lat": 0.0, "lon": lon, "height": 550.0,
+	}
+}
+
+func TestOrbitInfoUpdateNodeSet(t *testing.T) {
+	info := NewOrbitInfo(newTestParams([]map[string]interface{}{
+		newTestSat("sat0", 0, 0, 0),
+		newTestSat("sat1", 0, 1, 20),
+		newTestSat("sat2", 1, 0, 90),
+	}, []string{"user0"}), nil, nil)
+	oldIndexMap := map[string]int{}
+	for uuid, idx := range info.Metadata.UUIDIndexMap {
+		oldIndexMap[uuid] = idx
+	}
+
+	// sat1 deorbits, sat3 is launched and user1 powers on
+	added, removed := info.Update(newTestParams([]map[string]interface{}{
+		newTestSat("sat0", 0, 0, 1),
+		newTestSat("sat2", 1, 0, 91),
+		newTestSat("sat3", 1, 1, 110),
+	}, []string{"user0", "user1"}))
+	sort.Strings(added)
+	if !reflect.DeepEqual(added, []string{"sat3", "user1"}) || !reflect.DeepEqual(removed, []string{"sat1"}) {
+		t.Fatalf("added %v and removed %v are unexpected", added, removed)
+	}
+
+	meta := info.Metadata
+	for _, uuid := range []string{"sat0", "sat2", "user0"} {
+		if meta.UUIDIndexMap[uuid] != oldIndexMap[uuid] {
+			t.Errorf("index of %s changes from %d to %d", uuid, oldIndexMap[uuid], meta.UUIDIndexMap[uuid])
+		}
+	}
+	if _, ok := meta.UUIDIndexMap["sat1"]; ok {
+		t.Errorf("sat1 should be removed")
+	}
+	// The hole left by sat1 is reused first
+	if idx := meta.UUIDIndexMap["sat3"]; idx != oldIndexMap["sat1"] {
+		t.Errorf("sat3 should reuse index %d, got %d", oldIndexMap["sat1"], idx)
+	}
+	if meta.IndexNum != 5 || meta.LowOrbitNum != 3 || meta.UserNum != 2 {
+		t.Errorf("unexpected metadata: index num %d, low-orbit num %d, user num %d",
+			meta.IndexNum, meta.LowOrbitNum, meta.UserNum)
+	}
+	if meta.UUIDNodeMap["sat0"].Longitude != 1 || info.LowOrbitSats[1].Len() != 2 {
+		t.Errorf("nodes are not updated")
+	}
+	if len(info.LowOrbitSats[0].Nodes) != 1 {
+		t.Errorf("sat1 should be removed from its group")
+	}
+}
+
+func TestOrbitInfoHighOrbitLayer(t *testing.T) {
+	withHeight := func(sat map[string]interface{}, height float64) map[string]interface{} {
+		sat["height"] = height
+		return sat
+	}
+	// Satellites at and above HighOrbitAltitude are in the high-orbit layer
+	info := NewOrbitInfo(newTestParams([]map[string]interface{}{
+		newTestSat("leo", 0, 0, 0),
+		withHeight(newTestSat("below", 1, 0, 0), 9999),
+		withHeight(newTestSat("meo", 2, 0, 0), 10000),
+	}, nil), nil, nil)
+	meta := info.Metadata
+	if meta.LowOrbitNum != 2 || meta.HighOrbitNum != 1 || info.HighOrbitSats[2] == nil || info.LowOrbitSats[1] == nil {
+		t.Fatalf("satellites are not split by %v km: low-orbit groups %v, high-orbit groups %v",
+			satv2.HighOrbitAltitude, info.LowOrbitSats, info.HighOrbitSats)
+	}
+	oldIndex := meta.UUIDIndexMap["below"]
+
+	// "below" climbs above HighOrbitAltitude and moves to the high-orbit layer with its index
+	info.Update(newTestParams([]map[string]interface{}{
+		newTestSat("leo", 0, 0, 0),
+		withHeight(newTestSat("below", 1, 0, 0), 10001),
+		withHeight(newTestSat("meo", 2, 0, 0), 10000),
+	}, nil))
+	if _, ok := info.LowOrbitSats[1]; ok {
+		t.Errorf("low-orbit group of the moved satellite should be removed")
+	}
+	if group := info.HighOrbitSats[1]; group == nil || group.Len() != 1 || group.Nodes[0].UUID != "below" {
+		t.Errorf("satellite should move to high-orbit group, got %v", info.HighOrbitSats)
+	}
+	if meta.LowOrbitNum != 1 || meta.HighOrbitNum != 2 || meta.UUIDIndexMap["below"] != oldIndex {
+		t.Errorf("unexpected metadata: low-orbit num %d, high-orbit num %d, index %d",
+			meta.LowOrbitNum, meta.HighOrbitNum, meta.UUIDIndexMap["below"])
+	}
+	if node := meta.UUIDNodeMap["below"]; node.Type != satv2.HIGHORBIT || node.Altitude != 10001 {
+		t.Errorf("node is not updated: %v", node)
+	}
+}
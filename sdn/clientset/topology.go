@@ -0,0 +1,202 @@
+package clientset
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"ws/dtn-satellite-sdn/sdn/link"
+	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
+	"ws/dtn-satellite-sdn/sdn/util"
+)
+
+const (
+	DefaultTopologyName  = "default"
+	GridTopologyName     = "grid"
+	NearestKTopologyName = "nearest-k"
+	MaxRangeTopologyName = "max-range"
+	MotifTopologyName    = "motif"
+)
+
+// TopologyStrategy decides inter-satellite links among low-orbit satellites.
+type TopologyStrategy interface {
+	// Name returns the name used by `sdnctl init --topology`
+	Name() string
+
+	// ComputeISL returns inter-satellite links in the form of UUID -> []UUID.
+	// The result may be asymmetric, a link exists if either side selects the other.
+	ComputeISL(info *OrbitInfo, distance link.DistanceFunc) map[string][]string
+}
+
+// DefaultTopology connects satellites as a ring within each group plus two nearest satellites in other groups.
+type DefaultTopology struct{}
+
+// MotifTopology connects every satellite to satellites at the same relative positions in the plane grid.
+type MotifTopology struct {
+	Motif []link.MotifOffset
+
+	// name distinguishes well-known motifs(e.g. grid) from user-defined ones
+	name string
+}
+
+// NearestKTopology connects every satellite to its k nearest visible satellites.
+type NearestKTopology struct {
+	K int
+}
+
+// MaxRangeTopology connects every pair of visible satellites within MaxRange(km).
+type MaxRangeTopology struct {
+	MaxRange float64
+}
+
+// Function: NewTopologyStrategy
+// Description: Create topology strategy by name.
+// 1. name: default/grid/nearest-k/max-range/motif.
+// 2. k: The number of neighbours for nearest-k.
+// 3. maxRange: The max link length(km) for max-range.
+// 4. motif: Motif for motif topology in the form of "planeOffset:indexOffset,...", e.g. "0:1,1:0".
+func NewTopologyStrategy(name string, k int, maxRange float64, motif string) (TopologyStrategy, error) {
+	switch name {
+	case DefaultTopologyName:
+		return &DefaultTopology{}, nil
+	case GridTopologyName:
+		return NewGridTopology(), nil
+	case NearestKTopologyName:
+		if k <= 0 {
+			return nil, fmt.Errorf("k should be positive, got %d", k)
+		}
+		return &NearestKTopology{K: k}, nil
+	case MaxRangeTopologyName:
+		if maxRange <= 0 {
+			return nil, fmt.Errorf("max range should be positive, got %v", maxRange)
+		}
+		return &MaxRangeTopology{MaxRange: maxRange}, nil
+	case MotifTopologyName:
+		offsets, err := ParseMotif(motif)
+		if err != nil {
+			return nil, err
+		}
+		return &MotifTopology{Motif: offsets}, nil
+	default:
+		return nil, fmt.Errorf("unknown topology strategy: %s", name)
+	}
+}
+
+// Function: NewGridTopology
+// Description: Create +Grid topology, in which every satellite connects to its neighbours in the same plane
+// and fixed left/right neighbours in adjacent planes.
+func NewGridTopology() *MotifTopology {
+	return &MotifTopology{
+		Motif: []link.MotifOffset{
+			{PlaneOffset: 0, IndexOffset: 1},
+			{PlaneOffset: 1, IndexOffset: 0},
+		},
+		name: GridTopologyName,
+	}
+}
+
+// Function: ParseMotif
+// Description: Parse motif string like "0:1,1:0" into motif offsets.
+func ParseMotif(motif string) ([]link.MotifOffset, error) {
+	result := []link.MotifOffset{}
+	for _, item := range strings.Split(motif, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		planeStr, indexStr, found := strings.Cut(item, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid motif offset %s, expected planeOffset:indexOffset", item)
+		}
+		planeOffset, err := strconv.Atoi(strings.TrimSpace(planeStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid plane offset in %s: %v", item, err)
+		}
+		indexOffset, err := strconv.Atoi(strings.TrimSpace(indexStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid index offset in %s: %v", item, err)
+		}
+		if planeOffset == 0 && indexOffset == 0 {
+			return nil, fmt.Errorf("motif offset 0:0 connects a satellite to itself")
+		}
+		result = append(result, link.MotifOffset{PlaneOffset: planeOffset, IndexOffset: indexOffset})
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("motif is empty")
+	}
+	return result, nil
+}
+
+func (t *DefaultTopology) Name() string {
+	return DefaultTopologyName
+}
+
+func (t *DefaultTopology) ComputeISL(info *OrbitInfo, distance link.DistanceFunc) map[string][]string {
+	result := map[string][]string{}
+	mutex := new(sync.Mutex)
+	lowOrbitIdxs := info.Metadata.GetIndexesByType(satv2.LOWORBIT)
+	lowOrbitGroupNum := len(info.LowOrbitSats)
+	lowOrbitGroupKeys := make([]int, 0, lowOrbitGroupNum) // Store trackID in LowOrbitSats
+	for key := range info.LowOrbitSats {
+		lowOrbitGroupKeys = append(lowOrbitGroupKeys, key)
+	}
+	var wg sync.WaitGroup
+	wg.Add(util.ThreadNums)
+	for threadId := 0; threadId < util.ThreadNums; threadId++ {
+		go func(id int) {
+			// Partition tasks with trackID in LowOrbitSats
+			for trackIDIdx := id; trackIDIdx < lowOrbitGroupNum; trackIDIdx += util.ThreadNums {
+				curTrackID := lowOrbitGroupKeys[trackIDIdx]
+				sameOrbitTopoMap := link.GetTopoInGroup(info.LowOrbitSats[curTrackID], info.Metadata.TimeStamp)
+				diffOrbitTopoMap := link.GetTopoAmongLowOrbitGroup(
+					info.LowOrbitSats[curTrackID], lowOrbitIdxs, distance,
+					info.Metadata.IndexUUIDMap, info.Metadata.UUIDIndexMap,
+					info.Metadata.UUIDNodeMap, info.Metadata.TimeStamp,
+				)
+				mutex.Lock()
+				for uuid, sameUUIDList := range sameOrbitTopoMap {
+					result[uuid] = append(result[uuid], sameUUIDList...)
+					result[uuid] = append(result[uuid], diffOrbitTopoMap[uuid]...)
+				}
+				mutex.Unlock()
+			}
+			wg.Done()
+		}(threadId)
+	}
+	wg.Wait()
+	return result
+}
+
+func (t *MotifTopology) Name() string {
+	if t.name != "" {
+		return t.name
+	}
+	return MotifTopologyName
+}
+
+func (t *MotifTopology) ComputeISL(info *OrbitInfo, distance link.DistanceFunc) map[string][]string {
+	return link.GetTopoByMotif(info.LowOrbitSats, t.Motif, info.Metadata.TimeStamp)
+}
+
+func (t *NearestKTopology) Name() string {
+	return NearestKTopologyName
+}
+
+func (t *NearestKTopology) ComputeISL(info *OrbitInfo, distance link.DistanceFunc) map[string][]string {
+	return link.GetNearestKTopo(
+		info.Metadata.GetIndexesByType(satv2.LOWORBIT), t.K, distance,
+		info.Metadata.IndexUUIDMap, info.Metadata.UUIDNodeMap, info.Metadata.TimeStamp,
+	)
+}
+
+func (t *MaxRangeTopology) Name() string {
+	return MaxRangeTopologyName
+}
+
+func (t *MaxRangeTopology) ComputeISL(info *OrbitInfo, distance link.DistanceFunc) map[string][]string {
+	return link.GetMaxRangeTopo(
+		info.Metadata.GetIndexesByType(satv2.LOWORBIT), t.MaxRange, distance,
+		info.Metadata.IndexUUIDMap, info.Metadata.UUIDNodeMap, info.Metadata.TimeStamp,
+	)
+}
@@ -0,0 +1,175 @@
+// Package filter declares rules deciding which satellites take part in emulation.
+// It depends on no other package of this module, so that both position module and SDN server import it.
+package filter
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+
+	"sigs.k8s.io/yaml"
+)
+
+// SatelliteFilter decides which satellites take part in emulation.
+// A satellite is kept if it matches any include rule(or there is no include rule) and matches no exclude rule.
+// It can be written in YAML or JSON, e.g.
+//
+//	exclude:
+//	  - uuids: ["53302", "53347"]
+//	  - namePattern: "^STARLINK-1[0-9]{3}$"
+//	    maxAltitude: 400
+//	include:
+//	  - minInclination: 50
+//	    maxInclination: 56
+type SatelliteFilter struct {
+	Include []FilterRule `json:"include,omitempty"`
+	Exclude []FilterRule `json:"exclude,omitempty"`
+}
+
+// FilterRule matches a satellite if all of its specified conditions are satisfied.
+type FilterRule struct {
+	// UUIDs matches satellites whose uuid(name) is in the list
+	UUIDs []string `json:"uuids,omitempty"`
+
+	// NamePattern matches satellites whose uuid(name) matches the regular expression
+	NamePattern string `json:"namePattern,omitempty"`
+
+	// MinAltitude/MaxAltitude matches satellites in the altitude(km) band
+	MinAltitude *float64 `json:"minAltitude,omitempty"`
+	MaxAltitude *float64 `json:"maxAltitude,omitempty"`
+
+	// MinInclination/MaxInclination matches satellites in the inclination(degree) band
+	MinInclination *float64 `json:"minInclination,omitempty"`
+	MaxInclination *float64 `json:"maxInclination,omitempty"`
+
+	// Tracks matches satellites whose trackID is in the list
+	Tracks []int `json:"tracks,omitempty"`
+
+	namePattern *regexp.Regexp
+}
+
+// FilterTarget stores attributes of a satellite to be checked by SatelliteFilter.
+type FilterTarget struct {
+	UUID     string
+	Altitude float64
+
+	// Inclination is NaN if it is unknown, e.g. for a satellite alone in its plane, which is in no inclination band
+	Inclination float64
+
+	TrackID int
+}
+
+// Function: DefaultSatelliteFilter
+// Description: Return the filter used by SDN server without --filter, which excludes satellites 53302 and 53347
+// as before filter rules were configurable.
+func DefaultSatelliteFilter() *SatelliteFilter {
+	return &SatelliteFilter{
+		Exclude: []FilterRule{{UUIDs: []string{"53302", "53347"}}},
+	}
+}
+
+// Function: LoadSatelliteFilter
+// Description: Load satellite filter from a YAML/JSON file.
+// 1. filePath: The filter file's path.
+func LoadSatelliteFilter(filePath string) (*SatelliteFilter, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("read filter file %s failed: %v", filePath, err)
+	}
+	filter := SatelliteFilter{}
+	if err := yaml.UnmarshalStrict(content, &filter); err != nil {
+		return nil, fmt.Errorf("parse filter file %s failed: %v", filePath, err)
+	}
+	if err := filter.Compile(); err != nil {
+		return nil, fmt.Errorf("invalid filter file %s: %v", filePath, err)
+	}
+	return &filter, nil
+}
+
+// Function: Compile
+// Description: Check rules and compile name patterns, which should be called before Match.
+func (f *SatelliteFilter) Compile() error {
+	for _, rules := range [][]FilterRule{f.Include, f.Exclude} {
+		for idx := range rules {
+			rule := &rules[idx]
+			if rule.NamePattern != "" {
+				pattern, err := regexp.Compile(rule.NamePattern)
+				if err != nil {
+					return fmt.Errorf("invalid name pattern %s: %v", rule.NamePattern, err)
+				}
+				rule.namePattern = pattern
+			}
+			if rule.MinAltitude != nil && rule.MaxAltitude != nil && *rule.MinAltitude > *rule.MaxAltitude {
+				return fmt.Errorf("min altitude %v is larger than max altitude %v", *rule.MinAltitude, *rule.MaxAltitude)
+			}
+			if rule.MinInclination != nil && rule.MaxInclination != nil && *rule.MinInclination > *rule.MaxInclination {
+				return fmt.Errorf("min inclination %v is larger than max inclination %v", *rule.MinInclination, *rule.MaxInclination)
+			}
+		}
+	}
+	return nil
+}
+
+// Function: Match
+// Description: Return true if the satellite should be kept. A nil filter keeps all satellites.
+// 1. target: Attributes of the satellite.
+func (f *SatelliteFilter) Match(target FilterTarget) bool {
+	if f == nil {
+		return true
+	}
+	included := len(f.Include) == 0
+	for idx := range f.Include {
+		if f.Include[idx].match(target) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for idx := range f.Exclude {
+		if f.Exclude[idx].match(target) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *FilterRule) match(target FilterTarget) bool {
+	if len(r.UUIDs) > 0 && !containsString(r.UUIDs, target.UUID) {
+		return false
+	}
+	if r.namePattern != nil && !r.namePattern.MatchString(target.UUID) {
+		return false
+	}
+	if (r.MinAltitude != nil && target.Altitude < *r.MinAltitude) ||
+		(r.MaxAltitude != nil && target.Altitude > *r.MaxAltitude) {
+		return false
+	}
+	if (r.MinInclination != nil || r.MaxInclination != nil) && math.IsNaN(target.Inclination) {
+		return false
+	}
+	if (r.MinInclination != nil && target.Inclination < *r.MinInclination) ||
+		(r.MaxInclination != nil && target.Inclination > *r.MaxInclination) {
+		return false
+	}
+	if len(r.Tracks) > 0 {
+		for _, trackID := range r.Tracks {
+			if trackID == target.TrackID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
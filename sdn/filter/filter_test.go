@@ -0,0 +1,48 @@
+package filter
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSatelliteFilter(t *testing.T) {
+	filter, err := LoadSatelliteFilter("../data/filter.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.Match(FilterTarget{UUID: "53302"}) {
+		t.Errorf("53302 should be excluded")
+	}
+	if !filter.Match(FilterTarget{UUID: "STARLINK-1007"}) {
+		t.Errorf("STARLINK-1007 should be kept")
+	}
+	if DefaultSatelliteFilter().Match(FilterTarget{UUID: "53347"}) || !DefaultSatelliteFilter().Match(FilterTarget{UUID: "STARLINK-1007"}) {
+		t.Errorf("default filter should only exclude 53302 and 53347")
+	}
+
+	minIncl, maxAlt := 50.0, 600.0
+	filter = &SatelliteFilter{
+		Include: []FilterRule{{MinInclination: &minIncl}},
+		Exclude: []FilterRule{{NamePattern: "^STARLINK-1[0-9]{3}$", MaxAltitude: &maxAlt}, {Tracks: []int{3}}},
+	}
+	if err := filter.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		target   FilterTarget
+		expected bool
+	}{
+		{FilterTarget{UUID: "STARLINK-2000", Altitude: 550, Inclination: 53}, true},
+		{FilterTarget{UUID: "STARLINK-2000", Altitude: 550, Inclination: 43}, false},
+		{FilterTarget{UUID: "STARLINK-1007", Altitude: 550, Inclination: 53}, false},
+		{FilterTarget{UUID: "STARLINK-1007", Altitude: 1100, Inclination: 53}, true},
+		{FilterTarget{UUID: "STARLINK-2000", Altitude: 550, Inclination: 53, TrackID: 3}, false},
+		// Unknown inclination is in no inclination band
+		{FilterTarget{UUID: "STARLINK-2000", Altitude: 550, Inclination: math.NaN()}, false},
+	}
+	for _, c := range cases {
+		if filter.Match(c.target) != c.expected {
+			t.Errorf("match %+v should be %v", c.target, c.expected)
+		}
+	}
+}
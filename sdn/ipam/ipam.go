@@ -0,0 +1,302 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"ws/dtn-satellite-sdn/sdn/util"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// SlotsKey is the key in ConfigMap's data which stores uuid -> slot map in JSON.
+const SlotsKey = "slots"
+
+// DefaultGracePeriod is the default time that slots of removed nodes are kept for them before reused by others.
+const DefaultGracePeriod = 10 * time.Minute
+
+// Store persists uuid -> slot map, where slot is the node's index from which its addresses are derived.
+type Store interface {
+	Load() (map[string]int, error)
+	Save(slots map[string]int) error
+}
+
+// ConfigMapStore stores slots in a ConfigMap of current namespace.
+type ConfigMapStore struct {
+	Name string
+
+	// Client is the clientset to access the ConfigMap, nil means util.GetClientset()
+	Client kubernetes.Interface
+
+	// Namespace is the namespace of the ConfigMap, empty means util.GetNamespace()
+	Namespace string
+}
+
+// MemoryStore stores slots in memory, which is used by test mode.
+type MemoryStore struct {
+	slots map[string]int
+}
+
+// Allocator assigns every uuid a slot which never changes while the node exists,
+// so that global IP(util.GetGlobalIP) and link IPs(util.GetVxlanIP) derived from slots survive restarts.
+// Slots of removed nodes are reused by others after a grace period, in which nodes rejoining get their slots back.
+type Allocator struct {
+	mu    sync.Mutex
+	store Store
+
+	// gracePeriod is the time that slots of removed nodes are kept for them
+	gracePeriod time.Duration
+
+	// slots stores uuid -> slot of all nodes allocated and not reclaimed
+	slots map[string]int
+
+	// used stores slots which have been assigned
+	used map[int]bool
+
+	// released stores uuid -> when the node was removed, whose slot is reclaimed after grace period
+	released map[string]time.Time
+
+	// dirty means that slots have changed since last save
+	dirty bool
+}
+
+// Function: NewAllocator
+// Description: Create allocator with slots loaded from store.
+// 1. store: Where slots are persisted.
+// 2. gracePeriod: The time that slots of removed nodes are kept for them.
+func NewAllocator(store Store, gracePeriod time.Duration) (*Allocator, error) {
+	slots, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("load slots failed: %v", err)
+	}
+	allocator := &Allocator{
+		store:       store,
+		gracePeriod: gracePeriod,
+		slots:       map[string]int{},
+		used:        map[int]bool{},
+		released:    map[string]time.Time{},
+	}
+	for uuid, slot := range slots {
+		if slot < 0 || allocator.used[slot] {
+			return nil, fmt.Errorf("invalid slot %d of %s", slot, uuid)
+		}
+		allocator.slots[uuid] = slot
+		allocator.used[slot] = true
+	}
+	return allocator, nil
+}
+
+// Function: Allocate
+// Description: Return slot of each uuid. Uuids allocated before keep their slots,
+// and the others take the smallest free slots in the order of uuids.
+// 1. uuids: Nodes' uuid in a deterministic order.
+func (a *Allocator) Allocate(uuids []string) map[string]int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	result := map[string]int{}
+	nextSlot := 0
+	for _, uuid := range uuids {
+		if slot, ok := a.slots[uuid]; ok {
+			// Nodes rejoining within grace period get their slots back
+			delete(a.released, uuid)
+			result[uuid] = slot
+			continue
+		}
+		for a.used[nextSlot] {
+			nextSlot++
+		}
+		a.slots[uuid] = nextSlot
+		a.used[nextSlot] = true
+		a.dirty = true
+		result[uuid] = nextSlot
+	}
+	return result
+}
+
+// Function: Release
+// Description: Mark slots of removed nodes as released at t, which are reclaimed after grace period.
+// 1. uuids: Uuids of removed nodes.
+// 2. t: The time of removal.
+func (a *Allocator) Release(uuids []string, t time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, uuid := range uuids {
+		if _, ok := a.slots[uuid]; !ok {
+			continue
+		}
+		if _, ok := a.released[uuid]; !ok {
+			a.released[uuid] = t
+		}
+	}
+}
+
+// Function: Retain
+// Description: Mark slots of all nodes except uuids as released at t, e.g. slots loaded from store
+// whose nodes are absent after restart.
+// 1. uuids: Uuids of current nodes.
+// 2. t: The time of removal.
+func (a *Allocator) Retain(uuids []string, t time.Time) {
+	current := map[string]bool{}
+	for _, uuid := range uuids {
+		current[uuid] = true
+	}
+	absent := []string{}
+	a.mu.Lock()
+	for uuid := range a.slots {
+		if !current[uuid] {
+			absent = append(absent, uuid)
+		}
+	}
+	a.mu.Unlock()
+	a.Release(absent, t)
+}
+
+// Function: Reclaim
+// Description: Free slots released for longer than grace period, so that new nodes can take them.
+// Return uuids whose slots are freed.
+// 1. now: The current time.
+func (a *Allocator) Reclaim(now time.Time) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	result := []string{}
+	for uuid, t := range a.released {
+		if now.Sub(t) < a.gracePeriod {
+			continue
+		}
+		delete(a.used, a.slots[uuid])
+		delete(a.slots, uuid)
+		delete(a.released, uuid)
+		a.dirty = true
+		result = append(result, uuid)
+	}
+	return result
+}
+
+// Function: Reset
+// Description: Forget all slots, e.g. those left by a previous emulation when a new one is created.
+func (a *Allocator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.slots) == 0 {
+		return
+	}
+	a.slots = map[string]int{}
+	a.used = map[int]bool{}
+	a.released = map[string]time.Time{}
+	a.dirty = true
+}
+
+// Function: Save
+// Description: Persist slots if they have changed since last save.
+func (a *Allocator) Save() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.dirty {
+		return nil
+	}
+	if err := a.store.Save(a.slots); err != nil {
+		return fmt.Errorf("save slots failed: %v", err)
+	}
+	a.dirty = false
+	return nil
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{slots: map[string]int{}}
+}
+
+func (s *MemoryStore) Load() (map[string]int, error) {
+	result := map[string]int{}
+	for uuid, slot := range s.slots {
+		result[uuid] = slot
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) Save(slots map[string]int) error {
+	s.slots = map[string]int{}
+	for uuid, slot := range slots {
+		s.slots[uuid] = slot
+	}
+	return nil
+}
+
+// configMaps returns the interface to ConfigMaps in the namespace of the store.
+func (s *ConfigMapStore) configMaps() (typedcorev1.ConfigMapInterface, error) {
+	client, namespace := s.Client, s.Namespace
+	if client == nil {
+		clientset, err := util.GetClientset()
+		if err != nil {
+			return nil, fmt.Errorf("create clientset error: %v", err)
+		}
+		client = clientset
+	}
+	if namespace == "" {
+		var err error
+		if namespace, err = util.GetNamespace(); err != nil {
+			return nil, fmt.Errorf("get namespace error: %v", err)
+		}
+	}
+	return client.CoreV1().ConfigMaps(namespace), nil
+}
+
+func (s *ConfigMapStore) Load() (map[string]int, error) {
+	configMaps, err := s.configMaps()
+	if err != nil {
+		return nil, err
+	}
+	slots := map[string]int{}
+	configMap, err := configMaps.Get(context.TODO(), s.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return slots, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("get configmap %s error: %v", s.Name, err)
+	}
+	if content, ok := configMap.Data[SlotsKey]; ok {
+		if err := json.Unmarshal([]byte(content), &slots); err != nil {
+			return nil, fmt.Errorf("parse configmap %s error: %v", s.Name, err)
+		}
+	}
+	return slots, nil
+}
+
+func (s *ConfigMapStore) Save(slots map[string]int) error {
+	configMaps, err := s.configMaps()
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(slots)
+	if err != nil {
+		return err
+	}
+	configMap, err := configMaps.Get(context.TODO(), s.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: s.Name,
+			},
+			Data: map[string]string{SlotsKey: string(content)},
+		}
+		if _, err := configMaps.Create(context.TODO(), configMap, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("create configmap %s error: %v", s.Name, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("get configmap %s error: %v", s.Name, err)
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[SlotsKey] = string(content)
+	if _, err := configMaps.Update(context.TODO(), configMap, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update configmap %s error: %v", s.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package ipam
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAllocatorSurvivesRestart(t *testing.T) {
+	store := NewMemoryStore()
+	allocator, err := NewAllocator(store, DefaultGracePeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := allocator.Allocate([]string{"sat0", "sat1", "sat2"})
+	if err := allocator.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restarted allocator sees nodes in another order and a new node
+	allocator, err = NewAllocator(store, DefaultGracePeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := allocator.Allocate([]string{"sat3", "sat2", "sat0"})
+	for _, uuid := range []string{"sat0", "sat2"} {
+		if first[uuid] != second[uuid] {
+			t.Errorf("slot of %s changes from %d to %d", uuid, first[uuid], second[uuid])
+		}
+	}
+	// Slot of sat1 is reserved even if it is absent
+	if second["sat3"] != 3 {
+		t.Errorf("sat3 should take slot 3, got %d", second["sat3"])
+	}
+}
+
+func TestAllocatorReclaim(t *testing.T) {
+	allocator, err := NewAllocator(NewMemoryStore(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	allocator.Allocate([]string{"sat0", "sat1", "sat2"})
+	allocator.Release([]string{"sat0", "sat1"}, start)
+
+	// sat0 rejoins within grace period and keeps its slot, while the slot of sat1 is still reserved
+	if slot := allocator.Allocate([]string{"sat0"})["sat0"]; slot != 0 {
+		t.Errorf("sat0 should get slot 0 back, got %d", slot)
+	}
+	if reclaimed := allocator.Reclaim(start.Add(30 * time.Second)); len(reclaimed) != 0 {
+		t.Errorf("no slot should be reclaimed within grace period, got %v", reclaimed)
+	}
+	if slot := allocator.Allocate([]string{"sat3"})["sat3"]; slot != 3 {
+		t.Errorf("sat3 should take slot 3, got %d", slot)
+	}
+
+	// The slot of sat1 is reused after grace period
+	if reclaimed := allocator.Reclaim(start.Add(time.Minute)); len(reclaimed) != 1 || reclaimed[0] != "sat1" {
+		t.Errorf("only sat1 should be reclaimed, got %v", reclaimed)
+	}
+	if slot := allocator.Allocate([]string{"sat4"})["sat4"]; slot != 1 {
+		t.Errorf("sat4 should take slot 1, got %d", slot)
+	}
+}
+
+func TestAllocatorReset(t *testing.T) {
+	store := NewMemoryStore()
+	allocator, err := NewAllocator(store, DefaultGracePeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocator.Allocate([]string{"sat0", "sat1"})
+	if err := allocator.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new emulation starts from slot 0 and overwrites slots in store
+	allocator, err = NewAllocator(store, DefaultGracePeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocator.Reset()
+	if slot := allocator.Allocate([]string{"sat1"})["sat1"]; slot != 0 {
+		t.Errorf("sat1 should take slot 0 after reset, got %d", slot)
+	}
+	if err := allocator.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if slots, _ := store.Load(); len(slots) != 1 {
+		t.Errorf("store should only keep sat1, got %v", slots)
+	}
+}
+
+func TestConfigMapStore(t *testing.T) {
+	store := &ConfigMapStore{Name: "sdn-ipam", Client: fake.NewSimpleClientset(), Namespace: "default"}
+	// A missing ConfigMap means no slot
+	if slots, err := store.Load(); err != nil || len(slots) != 0 {
+		t.Fatalf("expected no slot, got %v and error %v", slots, err)
+	}
+
+	// The first save creates the ConfigMap and later ones update it
+	for _, expected := range []map[string]int{
+		{"sat0": 0, "sat1": 1},
+		{"sat0": 0, "sat2": 2},
+	} {
+		if err := store.Save(expected); err != nil {
+			t.Fatal(err)
+		}
+		slots, err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(slots, expected) {
+			t.Errorf("expected %v, got %v", expected, slots)
+		}
+	}
+
+	// Allocator restarted on the same ConfigMap keeps slots
+	allocator, err := NewAllocator(store, DefaultGracePeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slots := allocator.Allocate([]string{"sat2", "sat3"}); slots["sat2"] != 2 || slots["sat3"] != 1 {
+		t.Errorf("unexpected slots after restart: %v", slots)
+	}
+}
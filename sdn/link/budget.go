@@ -0,0 +1,151 @@
+package link
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// BoltzmannConstant is the Boltzmann constant(dBW/K/Hz) used to compute thermal noise.
+const BoltzmannConstant = -228.6
+
+// RadioConfig describes radios at both ends of one kind of link.
+type RadioConfig struct {
+	// TxPower is the transmit power(dBW)
+	TxPower float64 `json:"txPower"`
+
+	// TxGain/RxGain are antenna gains(dBi) of the transmitter and the receiver
+	TxGain float64 `json:"txGain"`
+	RxGain float64 `json:"rxGain"`
+
+	// Frequency is the carrier frequency(GHz)
+	Frequency float64 `json:"frequency"`
+
+	// Bandwidth is the channel bandwidth(MHz)
+	Bandwidth float64 `json:"bandwidth"`
+
+	// NoiseTemperature is the system noise temperature(K) of the receiver
+	NoiseTemperature float64 `json:"noiseTemperature"`
+
+	// OtherLosses is the sum of other losses(dB), e.g. pointing, polarization and atmospheric gases
+	OtherLosses float64 `json:"otherLosses,omitempty"`
+}
+
+// RainConfig describes rain attenuation on the slant path of ground links.
+type RainConfig struct {
+	// SpecificAttenuation is the attenuation(dB/km) through rain at the ground links' frequency
+	SpecificAttenuation float64 `json:"specificAttenuation"`
+
+	// Height is the rain height(km) above which there is no rain
+	Height float64 `json:"height"`
+}
+
+// LinkBudget computes SNR and achievable rate of links. It can be written in YAML or JSON, e.g.
+//
+//	isl:
+//	  txPower: 10
+//	  txGain: 38.5
+//	  rxGain: 38.5
+//	  frequency: 23
+//	  bandwidth: 400
+//	  noiseTemperature: 500
+//	ground:
+//	  ...
+//	rain:
+//	  specificAttenuation: 0.5
+//	  height: 5
+type LinkBudget struct {
+	// ISL is the radio of inter-satellite links
+	ISL RadioConfig `json:"isl"`
+
+	// Ground is the radio of links between terminals(ground station/missile/user) and satellites
+	Ground RadioConfig `json:"ground"`
+
+	// Rain is the rain attenuation of ground links, nil means clear sky
+	Rain *RainConfig `json:"rain,omitempty"`
+}
+
+// LinkGeometry stores the geometry of a link needed by link budget.
+type LinkGeometry struct {
+	// Distance is the length(km) of the link
+	Distance float64
+
+	// Ground means that the link is between a terminal and a satellite
+	Ground bool
+
+	// TerminalAltitude and Elevation(degree, satellite seen from the terminal) are only used by ground links
+	TerminalAltitude float64
+	Elevation        float64
+}
+
+// Function: LoadLinkBudget
+// Description: Load link budget from a YAML/JSON file.
+// 1. filePath: The link budget file's path.
+func LoadLinkBudget(filePath string) (*LinkBudget, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("read link budget file %s failed: %v", filePath, err)
+	}
+	budget := LinkBudget{}
+	if err := yaml.UnmarshalStrict(content, &budget); err != nil {
+		return nil, fmt.Errorf("parse link budget file %s failed: %v", filePath, err)
+	}
+	if err := budget.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid link budget file %s: %v", filePath, err)
+	}
+	return &budget, nil
+}
+
+// Function: Validate
+// Description: Check radios and rain of link budget.
+func (b *LinkBudget) Validate() error {
+	for name, radio := range map[string]*RadioConfig{"isl": &b.ISL, "ground": &b.Ground} {
+		if radio.Frequency <= 0 || radio.Bandwidth <= 0 || radio.NoiseTemperature <= 0 {
+			return fmt.Errorf("frequency, bandwidth and noise temperature of %s radio should be positive", name)
+		}
+	}
+	if b.Rain != nil && (b.Rain.SpecificAttenuation < 0 || b.Rain.Height < 0) {
+		return fmt.Errorf("specific attenuation and height of rain should not be negative")
+	}
+	return nil
+}
+
+// Function: FreeSpacePathLoss
+// Description: Return the free-space path loss(dB) of a link.
+// 1. distance: The length(km) of the link.
+// 2. frequency: The carrier frequency(GHz).
+func FreeSpacePathLoss(distance, frequency float64) float64 {
+	return 20*math.Log10(distance) + 20*math.Log10(frequency) + 92.45
+}
+
+// Function: RainAttenuation
+// Description: Return the rain attenuation(dB) along the slant path of a ground link.
+// Elevation below 5 degrees is regarded as 5 degrees since the slant path is not infinite.
+func (b *LinkBudget) RainAttenuation(geometry LinkGeometry) float64 {
+	if b.Rain == nil || !geometry.Ground || geometry.TerminalAltitude >= b.Rain.Height {
+		return 0
+	}
+	sinEl := math.Sin(math.Max(geometry.Elevation, 5) * math.Pi / 180.0)
+	pathLength := math.Min((b.Rain.Height-geometry.TerminalAltitude)/sinEl, geometry.Distance)
+	return b.Rain.SpecificAttenuation * pathLength
+}
+
+// Function: Evaluate
+// Description: Return SNR(dB) and achievable rate(Mbps) of a link by Shannon capacity.
+// 1. geometry: The geometry of the link.
+func (b *LinkBudget) Evaluate(geometry LinkGeometry) (snr, rate float64) {
+	radio := &b.ISL
+	if geometry.Ground {
+		radio = &b.Ground
+	}
+	// Links between co-located nodes are regarded as 1m long
+	distance := math.Max(geometry.Distance, 0.001)
+	received := radio.TxPower + radio.TxGain + radio.RxGain -
+		FreeSpacePathLoss(distance, radio.Frequency) - radio.OtherLosses - b.RainAttenuation(geometry)
+	noise := BoltzmannConstant + 10*math.Log10(radio.NoiseTemperature) + 10*math.Log10(radio.Bandwidth*1e6)
+	snr = received - noise
+	rate = radio.Bandwidth * math.Log2(1+math.Pow(10, snr/10))
+	return snr, rate
+}
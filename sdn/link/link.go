@@ -4,17 +4,20 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
 	"ws/dtn-satellite-sdn/sdn/util"
 
 	topov1 "github.com/y-young/kube-dtn/api/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Function: GetMinDistanceNode
-// Description: Given a node(most likely ground station/missile), return UUID of node most closest to it.
+// Description: Given a node(most likely ground station/missile), return UUID of visible node most closest to it.
+// If no node is visible, return "".
 // 1. node: The node(ground station / missile).
 // 2. groups: Node groups to select min node.
 // 3. curTime: Standard time to compute distance.
@@ -22,7 +25,8 @@ func GetMinDistanceNode(node *satv2.Node, groups []*satv2.Group, curTime time.Ti
 	minDistance, minUUID := 1e9, ""
 	for _, group := range groups {
 		for _, group_node := range group.Nodes {
-			if distance := node.DistanceWithNodeAtTime(&group_node, curTime); distance < minDistance {
+			if distance := node.DistanceWithNodeAtTime(&group_node, curTime); distance < minDistance &&
+				node.LineOfSightWithNodeAtTime(&group_node, curTime) {
 				minDistance = distance
 				minUUID = group_node.UUID
 			}
@@ -31,14 +35,60 @@ func GetMinDistanceNode(node *satv2.Node, groups []*satv2.Group, curTime time.Ti
 	return minUUID
 }
 
+// Function: GetAccessNodes
+// Description: Given a terminal(ground station/missile/user), return UUIDs of satellites it can access,
+// which are above its elevation mask and sorted by distance, at most node.MaxAccessNum.
+// An empty result means that the terminal has no coverage.
+// 1. node: The terminal node.
+// 2. groups: Node groups to select access nodes.
+// 3. curTime: Standard time to compute elevation and distance.
+func GetAccessNodes(node *satv2.Node, groups []*satv2.Group, curTime time.Time) []string {
+	type candidate struct {
+		uuid     string
+		distance float64
+	}
+	candidates := []candidate{}
+	for _, group := range groups {
+		for idx := range group.Nodes {
+			group_node := &group.Nodes[idx]
+			if node.ElevationWithNodeAtTime(group_node, curTime) < node.MinElevation ||
+				!node.LineOfSightWithNodeAtTime(group_node, curTime) {
+				continue
+			}
+			candidates = append(candidates, candidate{
+				uuid:     group_node.UUID,
+				distance: node.DistanceWithNodeAtTime(group_node, curTime),
+			})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+	result := []string{}
+	for idx := 0; idx < len(candidates) && idx < node.MaxAccessNum; idx++ {
+		result = append(result, candidates[idx].uuid)
+	}
+	return result
+}
+
 // Function: GetTopoInGroup
 // Description: Return connection graph for nodes(mainly satellites) in the same group(sorted)
+// Neighbours occluded by the Earth are not connected.
 // Return type: UUID -> []UUID
 // 1. group: The given Group(mainly satellite group)
-func GetTopoInGroup(group *satv2.Group) map[string][]string {
+// 2. curTime: Standard time to check line of sight.
+func GetTopoInGroup(group *satv2.Group, curTime time.Time) map[string][]string {
 	// Get edge set
 	result := map[string][]string{}
 	for i := 0; i < group.Len(); i++ {
+		if _, ok := result[group.Nodes[i].UUID]; !ok {
+			result[group.Nodes[i].UUID] = []string{}
+		}
+		// A group with single satellite(e.g. a GEO satellite in its own plane) has no neighbour
+		if group.Len() == 1 ||
+			!group.Nodes[i].LineOfSightWithNodeAtTime(&group.Nodes[(i+1)%group.Len()], curTime) {
+			continue
+		}
 		result[group.Nodes[i].UUID] = append(
 			result[group.Nodes[i].UUID],
 			group.Nodes[(i+1)%group.Len()].UUID,
@@ -53,14 +103,19 @@ func GetTopoInGroup(group *satv2.Group) map[string][]string {
 
 // Function: GetTopoAmongLowOrbitGroup
 // Description: Return connection graph between different constellations.
+// Satellites occluded by the Earth are never selected.
 // Return type: UUID -> []UUID
 // 1. curGroup: current low-orbit satellite group
-// 2. distanceMap: distance between nodes
-// 3. indexUUIDMap: map from index to uuid
-// 4. uuidIndexMap: map from uuid to index
+// 2. lowOrbitIdxs: indices of all low-orbit satellites
+// 3. distance: Function returning distance between nodes
+// 4. indexUUIDMap: map from index to uuid
+// 5. uuidIndexMap: map from uuid to index
+// 6. uuidNodeMap: map from uuid to node
+// 7. curTime: Standard time to check line of sight.
 func GetTopoAmongLowOrbitGroup(
-	curGroup *satv2.Group, lowOrbitNum int, distanceMap [][]float64,
-	indexUUIDMap map[int]string, uuidIndexMap map[string]int) map[string][]string {
+	curGroup *satv2.Group, lowOrbitIdxs []int, distance DistanceFunc,
+	indexUUIDMap map[int]string, uuidIndexMap map[string]int,
+	uuidNodeMap map[string]*satv2.Node, curTime time.Time) map[string][]string {
 	// Initialize some variables
 	result := map[string][]string{}
 	curNodeIdxs := []int{}
@@ -73,7 +128,7 @@ func GetTopoAmongLowOrbitGroup(
 		nodeIdx := uuidIndexMap[node.UUID]
 		// Get the nearst satellite
 		minDistance, minIdx := 1e9, -1
-		for otherNodeIdx := 0; otherNodeIdx < lowOrbitNum; otherNodeIdx++ {
+		for _, otherNodeIdx := range lowOrbitIdxs {
 			// If otherNodeIdx is in curNodeIdxs, continue
 			flag := false
 			for _, index := range curNodeIdxs {
@@ -86,14 +141,15 @@ func GetTopoAmongLowOrbitGroup(
 				continue
 			}
 			// Update min_distance & min_idx
-			if distanceMap[nodeIdx][otherNodeIdx] < minDistance {
-				minDistance = distanceMap[nodeIdx][otherNodeIdx]
+			if distance(nodeIdx, otherNodeIdx) < minDistance &&
+				node.LineOfSightWithNodeAtTime(uuidNodeMap[indexUUIDMap[otherNodeIdx]], curTime) {
+				minDistance = distance(nodeIdx, otherNodeIdx)
 				minIdx = otherNodeIdx
 			}
 		}
 		// Get the second nearst satellite
 		secondMinDistance, secondMinIdx := 1e9, -1
-		for otherNodeIdx := 0; otherNodeIdx < lowOrbitNum; otherNodeIdx++ {
+		for _, otherNodeIdx := range lowOrbitIdxs {
 			// If otherNodeIdx is in curNodeIdxs or equals to MinIdx, continue
 			flag := false
 			for _, index := range curNodeIdxs {
@@ -106,28 +162,43 @@ func GetTopoAmongLowOrbitGroup(
 				continue
 			}
 			// Update second_min_distance & second_min_idx
-			if distanceMap[nodeIdx][otherNodeIdx] < secondMinDistance {
-				secondMinDistance = distanceMap[nodeIdx][otherNodeIdx]
+			if distance(nodeIdx, otherNodeIdx) < secondMinDistance &&
+				node.LineOfSightWithNodeAtTime(uuidNodeMap[indexUUIDMap[otherNodeIdx]], curTime) {
+				secondMinDistance = distance(nodeIdx, otherNodeIdx)
 				secondMinIdx = otherNodeIdx
 			}
 		}
-		// Update connection graph
-		result[node.UUID] = []string{indexUUIDMap[minIdx], indexUUIDMap[secondMinIdx]}
+		// Update connection graph(skip if there is no visible satellite)
+		result[node.UUID] = []string{}
+		for _, idx := range []int{minIdx, secondMinIdx} {
+			if idx != -1 {
+				result[node.UUID] = append(result[node.UUID], indexUUIDMap[idx])
+			}
+		}
 	}
 
 	return result
 }
 
-// Function: LinkSyncLoop
-// Description: Apply topologies according to indexUUIDMap and topoAscArray
-// 1. indexUUIDMap: node's index -> node's uuid
-// 2. topoAscArray: Topology graph in ascend array
-// 3. isFistTime: true->create, false->update.
-func LinkSyncLoop(indexUUIDMap map[int]string, topoAscArray [][]int, isFirstTime bool) error {
-	// Initialize topologyList
+// linkUID identifies the link between two nodes by their indices, which is unique as long as indices fit in 32 bits.
+func linkUID(edgeFrom, edgeTo int) int {
+	return edgeFrom<<32 | edgeTo
+}
+
+// buildTopologyList constructs topologies of all nodes according to indexUUIDMap and topoAscArray.
+// linkProperties[i] is applied to both ends of topoAscArray[i], nil means no property.
+// itemIdxMap maps node's index to the position in topologyList since indices of removed nodes are holes.
+func buildTopologyList(indexUUIDMap map[int]string, topoAscArray [][]int,
+	linkProperties []topov1.LinkProperties) (*topov1.TopologyList, map[int]int) {
 	topoList := topov1.TopologyList{}
-	for idx := 0; idx < len(indexUUIDMap); idx++ {
-		// podIntfMap[idx] = 1
+	itemIdxMap := map[int]int{}
+	nodeIdxs := make([]int, 0, len(indexUUIDMap))
+	for idx := range indexUUIDMap {
+		nodeIdxs = append(nodeIdxs, idx)
+	}
+	sort.Ints(nodeIdxs)
+	for _, idx := range nodeIdxs {
+		itemIdxMap[idx] = len(topoList.Items)
 		topoList.Items = append(topoList.Items, topov1.Topology{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: indexUUIDMap[idx],
@@ -136,31 +207,48 @@ func LinkSyncLoop(indexUUIDMap map[int]string, topoAscArray [][]int, isFirstTime
 	}
 
 	// Construct topologyList according to topoAscArray
-	for _, linkPair := range topoAscArray {
+	for linkIdx, linkPair := range topoAscArray {
 		edgeFrom, edgeTo := linkPair[0], linkPair[1]
-		topoList.Items[edgeFrom].Spec.Links = append(
-			topoList.Items[edgeFrom].Spec.Links,
+		properties := topov1.LinkProperties{}
+		if linkProperties != nil {
+			properties = linkProperties[linkIdx]
+		}
+		topoList.Items[itemIdxMap[edgeFrom]].Spec.Links = append(
+			topoList.Items[itemIdxMap[edgeFrom]].Spec.Links,
 			topov1.Link{
-				UID:       (edgeFrom << 12) + edgeTo,
-				PeerPod:   indexUUIDMap[edgeTo],
-				LocalIntf: util.GetLinkName(indexUUIDMap[edgeTo]),
-				PeerIntf:  util.GetLinkName(indexUUIDMap[edgeFrom]),
-				LocalIP:   util.GetVxlanIP(uint(edgeFrom), uint(edgeTo)),
-				PeerIP:    util.GetVxlanIP(uint(edgeTo), uint(edgeFrom)),
+				UID:        linkUID(edgeFrom, edgeTo),
+				PeerPod:    indexUUIDMap[edgeTo],
+				LocalIntf:  util.GetLinkName(indexUUIDMap[edgeTo]),
+				PeerIntf:   util.GetLinkName(indexUUIDMap[edgeFrom]),
+				LocalIP:    util.GetVxlanIP(uint(edgeFrom), uint(edgeTo)),
+				PeerIP:     util.GetVxlanIP(uint(edgeTo), uint(edgeFrom)),
+				Properties: properties,
 			},
 		)
-		topoList.Items[edgeTo].Spec.Links = append(
-			topoList.Items[edgeTo].Spec.Links,
+		topoList.Items[itemIdxMap[edgeTo]].Spec.Links = append(
+			topoList.Items[itemIdxMap[edgeTo]].Spec.Links,
 			topov1.Link{
-				UID:       (edgeFrom << 12) + edgeTo,
-				PeerPod:   indexUUIDMap[edgeFrom],
-				LocalIntf: util.GetLinkName(indexUUIDMap[edgeFrom]),
-				PeerIntf:  util.GetLinkName(indexUUIDMap[edgeTo]),
-				LocalIP:   util.GetVxlanIP(uint(edgeTo), uint(edgeFrom)),
-				PeerIP:    util.GetVxlanIP(uint(edgeFrom), uint(edgeTo)),
+				UID:        linkUID(edgeFrom, edgeTo),
+				PeerPod:    indexUUIDMap[edgeFrom],
+				LocalIntf:  util.GetLinkName(indexUUIDMap[edgeFrom]),
+				PeerIntf:   util.GetLinkName(indexUUIDMap[edgeTo]),
+				LocalIP:    util.GetVxlanIP(uint(edgeTo), uint(edgeFrom)),
+				PeerIP:     util.GetVxlanIP(uint(edgeFrom), uint(edgeTo)),
+				Properties: properties,
 			},
 		)
 	}
+	return &topoList, itemIdxMap
+}
+
+// Function: LinkSyncLoop
+// Description: Apply topologies according to indexUUIDMap and topoAscArray
+// 1. indexUUIDMap: node's index -> node's uuid
+// 2. topoAscArray: Topology graph in ascend array
+// 3. linkProperties: Properties(latency, bandwidth, etc) of links in topoAscArray, nil means no property
+// 4. isFistTime: true->create, false->update.
+func LinkSyncLoop(indexUUIDMap map[int]string, topoAscArray [][]int, linkProperties []topov1.LinkProperties, isFirstTime bool) error {
+	topoList, _ := buildTopologyList(indexUUIDMap, topoAscArray, linkProperties)
 
 	// Get current namespace
 	namespace, err := util.GetNamespace()
@@ -233,3 +321,202 @@ func LinkSyncLoop(indexUUIDMap map[int]string, topoAscArray [][]int, isFirstTime
 
 	return nil
 }
+
+// TopoDiff stores the difference between two topology graphs, in which links are identified by their ends' uuid
+// so that a link to a node reusing a removed node's index is regarded as a new link.
+type TopoDiff struct {
+	// Added/Removed/Updated store links in the form of [uuid1, uuid2] with uuid1 < uuid2,
+	// Updated links exist in both graphs but their properties changed
+	Added   [][2]string
+	Removed [][2]string
+	Updated [][2]string
+
+	// Changed stores names of existing topologies whose links are changed
+	Changed []string
+}
+
+// Function: DiffTopologies
+// Description: Compute links added, removed and updated from the previous topology graph to the current one.
+// 1. prevIndexUUIDMap: node's index -> node's uuid of the previous graph.
+// 2. prevTopoAscArray: The previous topology graph in ascend array.
+// 3. prevProperties: Properties of links in prevTopoAscArray, nil means no property.
+// 4. indexUUIDMap: node's index -> node's uuid of the current graph.
+// 5. topoAscArray: The current topology graph in ascend array.
+// 6. linkProperties: Properties of links in topoAscArray, nil means no property.
+func DiffTopologies(prevIndexUUIDMap map[int]string, prevTopoAscArray [][]int, prevProperties []topov1.LinkProperties,
+	indexUUIDMap map[int]string, topoAscArray [][]int, linkProperties []topov1.LinkProperties) *TopoDiff {
+	linkSet := func(indexUUIDMap map[int]string, topoAscArray [][]int,
+		linkProperties []topov1.LinkProperties) map[[2]string]topov1.LinkProperties {
+		result := make(map[[2]string]topov1.LinkProperties, len(topoAscArray))
+		for linkIdx, linkPair := range topoAscArray {
+			uuid1, uuid2 := indexUUIDMap[linkPair[0]], indexUUIDMap[linkPair[1]]
+			if uuid1 > uuid2 {
+				uuid1, uuid2 = uuid2, uuid1
+			}
+			result[[2]string{uuid1, uuid2}] = topov1.LinkProperties{}
+			if linkProperties != nil {
+				result[[2]string{uuid1, uuid2}] = linkProperties[linkIdx]
+			}
+		}
+		return result
+	}
+	prevLinks := linkSet(prevIndexUUIDMap, prevTopoAscArray, prevProperties)
+	curLinks := linkSet(indexUUIDMap, topoAscArray, linkProperties)
+
+	diff := TopoDiff{Added: [][2]string{}, Removed: [][2]string{}, Updated: [][2]string{}, Changed: []string{}}
+	for key, properties := range curLinks {
+		if prevProperties, ok := prevLinks[key]; !ok {
+			diff.Added = append(diff.Added, key)
+		} else if prevProperties != properties {
+			diff.Updated = append(diff.Updated, key)
+		}
+	}
+	for key := range prevLinks {
+		if _, ok := curLinks[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	// Topologies of removed nodes are deleted instead of updated
+	exists := make(map[string]bool, len(indexUUIDMap))
+	for _, uuid := range indexUUIDMap {
+		exists[uuid] = true
+	}
+	changed := map[string]bool{}
+	for _, links := range [][][2]string{diff.Added, diff.Removed, diff.Updated} {
+		for _, key := range links {
+			for _, uuid := range key {
+				if exists[uuid] {
+					changed[uuid] = true
+				}
+			}
+		}
+	}
+	for uuid := range changed {
+		diff.Changed = append(diff.Changed, uuid)
+	}
+	sort.Strings(diff.Changed)
+	return &diff
+}
+
+// Function: UpdateTopologies
+// Description: Update only the given topologies according to indexUUIDMap and topoAscArray.
+// 1. indexUUIDMap: node's index -> node's uuid
+// 2. topoAscArray: Topology graph in ascend array
+// 3. linkProperties: Properties(latency, bandwidth, etc) of links in topoAscArray, nil means no property
+// 4. names: Names of topologies(nodes' uuid) to update.
+func UpdateTopologies(indexUUIDMap map[int]string, topoAscArray [][]int, linkProperties []topov1.LinkProperties, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	topoList, _ := buildTopologyList(indexUUIDMap, topoAscArray, linkProperties)
+	topoMap := make(map[string]*topov1.Topology, len(topoList.Items))
+	for idx := range topoList.Items {
+		topoMap[topoList.Items[idx].Name] = &topoList.Items[idx]
+	}
+
+	namespace, err := util.GetNamespace()
+	if err != nil {
+		return fmt.Errorf("get namespace error: %v", err)
+	}
+	restClient, err := util.GetTopoClient()
+	if err != nil {
+		return fmt.Errorf("config error: %v", err)
+	}
+	var errOnce sync.Once
+	var updateErr error
+	wg := new(sync.WaitGroup)
+	wg.Add(util.ThreadNums)
+	for threadId := 0; threadId < util.ThreadNums; threadId++ {
+		go func(id int) {
+			defer wg.Done()
+			for nameId := id; nameId < len(names); nameId += util.ThreadNums {
+				topo, ok := topoMap[names[nameId]]
+				if !ok {
+					continue
+				}
+				// Fetch resourceVersion of this topology only, instead of listing all topologies
+				current := topov1.Topology{}
+				err := restClient.Get().
+					Namespace(namespace).
+					Resource("topologies").
+					Name(topo.Name).
+					Do(context.TODO()).
+					Into(&current)
+				if err == nil {
+					topo.ResourceVersion = current.ResourceVersion
+					err = restClient.Put().
+						Namespace(namespace).
+						Resource("topologies").
+						Name(topo.Name).
+						Body(topo).
+						Do(context.TODO()).
+						Into(nil)
+				}
+				if err != nil {
+					errOnce.Do(func() {
+						updateErr = fmt.Errorf("update topology %s error: %v", topo.Name, err)
+					})
+				}
+			}
+		}(threadId)
+	}
+	wg.Wait()
+	return updateErr
+}
+
+// Function: CreateTopologies
+// Description: Create topologies without links for nodes joining the emulation,
+// which should be done before their pods are created. Existing topologies are left as they are,
+// e.g. when a failed sync is retried.
+// 1. names: Names of topologies(nodes' uuid) to create.
+func CreateTopologies(names []string) error {
+	namespace, err := util.GetNamespace()
+	if err != nil {
+		return fmt.Errorf("get namespace error: %v", err)
+	}
+	restClient, err := util.GetTopoClient()
+	if err != nil {
+		return fmt.Errorf("config error: %v", err)
+	}
+	for _, name := range names {
+		topo := topov1.Topology{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+		}
+		if err := restClient.Post().
+			Namespace(namespace).
+			Resource("topologies").
+			Body(&topo).
+			Do(context.TODO()).
+			Into(nil); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("create topology %s error: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Function: DeleteTopologies
+// Description: Delete topologies of nodes which have left the emulation.
+// 1. names: Names of topologies(nodes' uuid) to delete.
+func DeleteTopologies(names []string) error {
+	namespace, err := util.GetNamespace()
+	if err != nil {
+		return fmt.Errorf("get namespace error: %v", err)
+	}
+	restClient, err := util.GetTopoClient()
+	if err != nil {
+		return fmt.Errorf("config error: %v", err)
+	}
+	for _, name := range names {
+		if err := restClient.Delete().
+			Namespace(namespace).
+			Resource("topologies").
+			Name(name).
+			Do(context.TODO()).
+			Error(); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete topology %s error: %v", name, err)
+		}
+	}
+	return nil
+}
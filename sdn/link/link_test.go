@@ -1,9 +1,17 @@
 package link
 
 import (
+	"fmt"
+	"math"
+	"net"
+	"reflect"
 	"testing"
+	"time"
 
+	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
 	"ws/dtn-satellite-sdn/sdn/util"
+
+	topov1 "github.com/y-young/kube-dtn/api/v1"
 )
 
 func TestGenerateIP(t *testing.T) {
@@ -13,3 +21,215 @@ func TestGenerateIP(t *testing.T) {
 		t.Errorf("IP Dismatch!\n")
 	}
 }
+
+func TestGenerateIPv6(t *testing.T) {
+	if err := util.SetIPFamily(util.IPv6, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer util.SetIPFamily(util.IPv4, "", "")
+	// Two ends of a link are in the same /127 subnet
+	if ip := util.GetVxlanIP(1, 2); ip != "fd00:234::2:0:4/127" {
+		t.Errorf("IP Dismatch! %s\n", ip)
+	}
+	if ip := util.GetVxlanIP(2, 1); ip != "fd00:234::2:0:5/127" {
+		t.Errorf("IP Dismatch! %s\n", ip)
+	}
+	if ip := util.GetGlobalIP(70000); ip != "fd00:233::1:1170" {
+		t.Errorf("IP Dismatch! %s\n", ip)
+	}
+	if !util.IsGlobalIP(net.ParseIP("fd00:233::1:1170")) || util.IsGlobalIP(net.ParseIP("10.233.0.1")) {
+		t.Errorf("Global IPs should follow the address family\n")
+	}
+	if err := util.SetIPFamily(util.IPv6, "fd00:233::/64", "fd00:233::/48"); err == nil {
+		t.Errorf("Overlapped prefixes should be rejected\n")
+	}
+}
+
+func TestLinkUID(t *testing.T) {
+	// Shifting by 12 bits mixed up (1, 0) and (0, 4096)
+	uids := map[int][2]int{}
+	for _, pair := range [][2]int{{1, 0}, {0, 4096}, {0, 1}, {4096, 4097}, {4097, 4096}} {
+		uid := linkUID(pair[0], pair[1])
+		if other, ok := uids[uid]; ok {
+			t.Errorf("links %v and %v have the same uid %d", other, pair, uid)
+		}
+		uids[uid] = pair
+	}
+}
+
+func TestGetTopoByMotif(t *testing.T) {
+	// 3 planes with 4 satellites, neighbours are 90 degrees apart
+	groups := map[int]*satv2.Group{}
+	for trackID := 0; trackID < 3; trackID++ {
+		groups[trackID] = satv2.NewSatGroup(satv2.LOWORBIT, trackID)
+		for inTrackID := 0; inTrackID < 4; inTrackID++ {
+			groups[trackID].Nodes = append(groups[trackID].Nodes, satv2.Node{
+				Type:      satv2.LOWORBIT,
+				UUID:      fmt.Sprintf("sat%d-%d", trackID, inTrackID),
+				TrackID:   trackID,
+				InTrackID: inTrackID,
+				Latitude:  0,
+				Longitude: float64(inTrackID*90 + trackID*10),
+				Altitude:  8000,
+			})
+		}
+	}
+	topo := GetTopoByMotif(groups, []MotifOffset{{0, 1}, {1, 0}}, time.Now())
+	expected := []string{"sat0-1", "sat1-0"}
+	if !reflect.DeepEqual(topo["sat0-0"], expected) {
+		t.Errorf("expected %v, got %v", expected, topo["sat0-0"])
+	}
+	// The last plane wraps around to the first plane
+	expected = []string{"sat2-2", "sat0-1"}
+	if !reflect.DeepEqual(topo["sat2-1"], expected) {
+		t.Errorf("expected %v, got %v", expected, topo["sat2-1"])
+	}
+}
+
+func TestGetPlaneNormal(t *testing.T) {
+	// Equatorial plane moving eastward, whose normal is along +z
+	group := satv2.NewSatGroup(satv2.LOWORBIT, 0)
+	for inTrackID := 0; inTrackID < 4; inTrackID++ {
+		group.Nodes = append(group.Nodes, satv2.Node{
+			Type:      satv2.LOWORBIT,
+			UUID:      fmt.Sprintf("sat%d", inTrackID),
+			InTrackID: inTrackID,
+			Longitude: float64(inTrackID * 90),
+			Altitude:  550,
+		})
+	}
+	x, y, z := GetPlaneNormal(group, time.Now())
+	if math.Abs(x) > 1e-6 || math.Abs(y) > 1e-6 || math.Abs(z-1) > 1e-6 {
+		t.Errorf("normal of equatorial plane is (%v, %v, %v), expected (0, 0, 1)", x, y, z)
+	}
+}
+
+// newTestGroup returns a group whose satellites are on the equator at given longitudes and altitude.
+func newTestGroup(groupType satv2.GroupType, trackID int, altitude float64, longitudes ...float64) *satv2.Group {
+	group := satv2.NewSatGroup(groupType, trackID)
+	for inTrackID, longitude := range longitudes {
+		group.Nodes = append(group.Nodes, satv2.Node{
+			Type:      satv2.NodeType(groupType),
+			UUID:      fmt.Sprintf("sat%d-%d", trackID, inTrackID),
+			TrackID:   trackID,
+			InTrackID: inTrackID,
+			Longitude: longitude,
+			Altitude:  altitude,
+		})
+	}
+	return group
+}
+
+func TestGetTopoAmongGroups(t *testing.T) {
+	groups := []*satv2.Group{
+		newTestGroup(satv2.HIGHORBIT, 0, 20000, 0, 5),
+		newTestGroup(satv2.HIGHORBIT, 1, 20000, 10, 90),
+		newTestGroup(satv2.HIGHORBIT, 2, 20000, -20),
+	}
+	topo := GetTopoAmongGroups(groups, 2, time.Now())
+	// Satellites in the same group are never selected even if they are the nearest
+	if expected := []string{"sat1-0", "sat2-0"}; !reflect.DeepEqual(topo["sat0-0"], expected) {
+		t.Errorf("expected %v, got %v", expected, topo["sat0-0"])
+	}
+	if expected := []string{"sat0-1", "sat0-0"}; !reflect.DeepEqual(topo["sat1-0"], expected) {
+		t.Errorf("expected %v, got %v", expected, topo["sat1-0"])
+	}
+}
+
+func TestGetCrossLayerTopo(t *testing.T) {
+	high := []*satv2.Group{newTestGroup(satv2.HIGHORBIT, 0, 20000, 0)}
+	// The low-orbit satellite at 180 degrees is behind the Earth
+	low := []*satv2.Group{
+		newTestGroup(satv2.LOWORBIT, 0, 550, 180, 30),
+		newTestGroup(satv2.LOWORBIT, 1, 550, 2),
+	}
+	topo := GetCrossLayerTopo(high, low, 3, time.Now())
+	if expected := []string{"sat1-0", "sat0-1"}; !reflect.DeepEqual(topo["sat0-0"], expected) {
+		t.Errorf("expected %v, got %v", expected, topo["sat0-0"])
+	}
+	if len(topo) != 1 {
+		t.Errorf("only high-orbit satellites should select relays, got %v", topo)
+	}
+}
+
+func TestDiffTopologies(t *testing.T) {
+	prevIndexUUIDMap := map[int]string{0: "a", 1: "b", 2: "c", 3: "d"}
+	prevTopo := [][]int{{0, 1}, {1, 2}, {2, 3}}
+	// d leaves and e reuses its index, link b-c is replaced by a-c
+	indexUUIDMap := map[int]string{0: "a", 1: "b", 2: "c", 3: "e"}
+	topo := [][]int{{0, 1}, {0, 2}, {2, 3}}
+
+	diff := DiffTopologies(prevIndexUUIDMap, prevTopo, nil, indexUUIDMap, topo, nil)
+	if len(diff.Added) != 2 || len(diff.Removed) != 2 {
+		t.Errorf("Expect 2 added and 2 removed links, got %v and %v\n", diff.Added, diff.Removed)
+	}
+	if expected := []string{"a", "b", "c", "e"}; !reflect.DeepEqual(diff.Changed, expected) {
+		t.Errorf("Expect changed topologies %v, got %v\n", expected, diff.Changed)
+	}
+
+	diff = DiffTopologies(indexUUIDMap, topo, nil, indexUUIDMap, [][]int{{0, 1}, {0, 2}, {2, 3}}, nil)
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Changed) != 0 {
+		t.Errorf("Expect no change, got %v\n", diff)
+	}
+
+	// Only the latency of link a-c changes
+	prevProperties := []topov1.LinkProperties{{Latency: "1.000ms"}, {Latency: "2.000ms"}, {Latency: "3.000ms"}}
+	properties := []topov1.LinkProperties{{Latency: "1.000ms"}, {Latency: "2.100ms"}, {Latency: "3.000ms"}}
+	diff = DiffTopologies(indexUUIDMap, topo, prevProperties, indexUUIDMap, topo, properties)
+	if !reflect.DeepEqual(diff.Updated, [][2]string{{"a", "c"}}) || !reflect.DeepEqual(diff.Changed, []string{"a", "c"}) {
+		t.Errorf("Expect link a-c to be updated, got %v\n", diff)
+	}
+}
+
+func TestLinkModelProperties(t *testing.T) {
+	model := &LinkModel{ProcessingDelay: 0.5, Bandwidth: "100Mbps", Loss: 0.01, LatencyStep: 0.1}
+	if err := model.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	// 1000km takes 3.336ms to propagate
+	properties := model.Properties(model.Evaluate(LinkGeometry{Distance: 1000}))
+	expected := topov1.LinkProperties{Latency: "3.800ms", Rate: "100Mbps", Loss: "0.01"}
+	if properties != expected {
+		t.Errorf("Expect properties %v, got %v\n", expected, properties)
+	}
+	if err := (&LinkModel{Bandwidth: "fast"}).Validate(); err == nil {
+		t.Errorf("Invalid bandwidth should be rejected\n")
+	}
+	// tc counts bps in bytes per second
+	if capacity := model.Capacity(LinkMetrics{}); capacity != 800 {
+		t.Errorf("Expect capacity 800Mbit/s, got %v\n", capacity)
+	}
+	for rate, expected := range map[string]float64{"2500kbit": 2.5, "1Gibit": 1073.741824, "1000000": 1} {
+		if capacity, err := ParseRate(rate); err != nil || math.Abs(capacity-expected) > 1e-9 {
+			t.Errorf("Expect rate %s to be %vMbit/s, got %v(%v)\n", rate, expected, capacity, err)
+		}
+	}
+}
+
+func TestLinkBudget(t *testing.T) {
+	budget, err := LoadLinkBudget("../data/link-budget.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Longer links have lower SNR and rate
+	snr1, rate1 := budget.Evaluate(LinkGeometry{Distance: 1000})
+	snr2, rate2 := budget.Evaluate(LinkGeometry{Distance: 2000})
+	if math.Abs(snr1-snr2-20*math.Log10(2)) > 1e-9 || rate1 <= rate2 || rate2 <= 0 {
+		t.Errorf("Unexpected SNR %v, %v and rate %v, %v\n", snr1, snr2, rate1, rate2)
+	}
+	// Rain attenuates ground links more at low elevation
+	low := LinkGeometry{Distance: 1000, Ground: true, Elevation: 20}
+	high := LinkGeometry{Distance: 1000, Ground: true, Elevation: 80}
+	if budget.RainAttenuation(low) <= budget.RainAttenuation(high) || budget.RainAttenuation(LinkGeometry{Distance: 1000}) != 0 {
+		t.Errorf("Unexpected rain attenuation\n")
+	}
+
+	model := &LinkModel{Budget: budget, PacketSize: DefaultPacketSize}
+	metrics := model.Evaluate(LinkGeometry{Distance: 1000})
+	if metrics.Latency <= PropagationDelay(1000) || metrics.Rate != rate1 {
+		t.Errorf("Unexpected metrics %v\n", metrics)
+	}
+	if rate := model.Properties(metrics).Rate; !ratePattern.MatchString(rate) {
+		t.Errorf("Invalid rate %s\n", rate)
+	}
+}
@@ -0,0 +1,198 @@
+package link
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	topov1 "github.com/y-young/kube-dtn/api/v1"
+)
+
+// LightSpeed is the speed(km/s) of light in vacuum, at which signals propagate along links.
+const LightSpeed = 299792.458
+
+// DefaultLatencyStep is the default granularity(ms) of latency applied to links.
+const DefaultLatencyStep = 0.1
+
+// DefaultPacketSize is the default size(byte) of packets whose transmission delay is a part of link latency.
+const DefaultPacketSize = 1500
+
+// ratePattern is the format of bandwidth accepted by kube-dtn, e.g. 1000(bit/s), 100kbit, 100Mbps, 1Gibps.
+var ratePattern = regexp.MustCompile(`^\d+(\.\d+)?([KkMmGg]i?)?(bit|bps)?$`)
+
+// LinkModel derives performance of a link from its length.
+type LinkModel struct {
+	// ProcessingDelay is the delay(ms) added by each hop, e.g. queueing and switching in the sender
+	ProcessingDelay float64
+
+	// Bandwidth is the rate limit of each link in tc format, empty means no limit.
+	// It is ignored if Budget is set, in which case the rate of each link is its achievable rate.
+	Bandwidth string
+
+	// Loss is the loss rate(percentage) of each link, 0 means no loss
+	Loss float64
+
+	// LatencyStep is the granularity(ms) of latency applied to links, 0 means no rounding.
+	// Topologies are only updated when the rounded latency of their links changes.
+	LatencyStep float64
+
+	// Budget computes SNR and achievable rate of each link, nil means that capacity is not modelled
+	Budget *LinkBudget
+
+	// PacketSize is the size(byte) of packets whose transmission delay at achievable rate is added to latency,
+	// which makes routes prefer links with higher capacity. It is only used with Budget.
+	PacketSize float64
+}
+
+// LinkMetrics stores performance of a link at a time.
+type LinkMetrics struct {
+	// Distance is the length(km) of the link
+	Distance float64
+
+	// Latency is the one-way latency(ms) of the link
+	Latency float64
+
+	// SNR(dB) and Rate(Mbps) are computed by link budget, Rate is 0 if capacity is not modelled
+	SNR  float64
+	Rate float64
+}
+
+// Function: Validate
+// Description: Check options of link model, which should be called before Properties.
+func (m *LinkModel) Validate() error {
+	if m.ProcessingDelay < 0 {
+		return fmt.Errorf("processing delay %v should not be negative", m.ProcessingDelay)
+	}
+	if m.Bandwidth != "" && !ratePattern.MatchString(m.Bandwidth) {
+		return fmt.Errorf("invalid bandwidth %s, e.g. 100Mbps", m.Bandwidth)
+	}
+	if m.Loss < 0 || m.Loss > 100 {
+		return fmt.Errorf("loss %v should be in [0, 100]", m.Loss)
+	}
+	if m.LatencyStep < 0 {
+		return fmt.Errorf("latency step %v should not be negative", m.LatencyStep)
+	}
+	if m.PacketSize < 0 {
+		return fmt.Errorf("packet size %v should not be negative", m.PacketSize)
+	}
+	if m.Budget != nil {
+		return m.Budget.Validate()
+	}
+	return nil
+}
+
+// Function: ParseRate
+// Description: Return the rate(Mbps) of tc format, e.g. 100mbit(100), 100Mbps(800, bytes per second), 1Gibit(1073.741824).
+// 1. rate: The rate in tc format, a bare number is in bit/s.
+func ParseRate(rate string) (float64, error) {
+	match := ratePattern.FindStringSubmatch(rate)
+	if match == nil {
+		return 0, fmt.Errorf("invalid rate %s, e.g. 100Mbps", rate)
+	}
+	value, err := strconv.ParseFloat(rate[:len(rate)-len(match[2])-len(match[3])], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %s: %v", rate, err)
+	}
+	if match[3] == "bps" {
+		value *= 8
+	}
+	switch strings.ToLower(match[2]) {
+	case "k":
+		value *= 1e3
+	case "ki":
+		value *= 1 << 10
+	case "m":
+		value *= 1e6
+	case "mi":
+		value *= 1 << 20
+	case "g":
+		value *= 1e9
+	case "gi":
+		value *= 1 << 30
+	}
+	return value / 1e6, nil
+}
+
+// Function: Capacity
+// Description: Return the capacity(Mbps) of a link, which is its achievable rate with link budget,
+// or the bandwidth limit otherwise. 0 means that capacity is unknown.
+// 1. metrics: Performance of the link.
+func (m *LinkModel) Capacity(metrics LinkMetrics) float64 {
+	if m.Budget != nil {
+		return metrics.Rate
+	}
+	if m.Bandwidth != "" {
+		// Bandwidth has been checked by Validate
+		capacity, _ := ParseRate(m.Bandwidth)
+		return capacity
+	}
+	return 0
+}
+
+// Function: PropagationDelay
+// Description: Return the propagation delay(ms) of a link.
+// 1. distance: The length(km) of the link.
+func PropagationDelay(distance float64) float64 {
+	return distance / LightSpeed * 1000.0
+}
+
+// Function: Latency
+// Description: Return the one-way latency(ms) of a link, including propagation delay and processing delay.
+// 1. distance: The length(km) of the link.
+func (m *LinkModel) Latency(distance float64) float64 {
+	return PropagationDelay(distance) + m.ProcessingDelay
+}
+
+// Function: Evaluate
+// Description: Return performance of a link. With link budget, latency includes transmission delay of a packet.
+// 1. geometry: The geometry of the link.
+func (m *LinkModel) Evaluate(geometry LinkGeometry) LinkMetrics {
+	metrics := LinkMetrics{
+		Distance: geometry.Distance,
+		Latency:  m.Latency(geometry.Distance),
+	}
+	if m.Budget != nil {
+		metrics.SNR, metrics.Rate = m.Budget.Evaluate(geometry)
+		if metrics.Rate > 0 {
+			metrics.Latency += m.PacketSize * 8 / (metrics.Rate * 1e6) * 1000.0
+		}
+	}
+	return metrics
+}
+
+// Function: Properties
+// Description: Return properties applied to both ends of a link, which shape egress traffic of each end.
+// 1. metrics: Performance of the link.
+func (m *LinkModel) Properties(metrics LinkMetrics) topov1.LinkProperties {
+	latency := metrics.Latency
+	if m.LatencyStep > 0 {
+		latency = math.Round(latency/m.LatencyStep) * m.LatencyStep
+	}
+	properties := topov1.LinkProperties{
+		Latency: strconv.FormatFloat(latency, 'f', 3, 64) + "ms",
+		Rate:    m.Bandwidth,
+	}
+	if m.Budget != nil {
+		// 3 significant digits avoid updating topologies for tiny changes of rate
+		properties.Rate = strconv.FormatFloat(roundSignificant(metrics.Rate*1000, 3), 'f', -1, 64) + "kbit"
+	}
+	if m.Loss > 0 {
+		properties.Loss = strconv.FormatFloat(m.Loss, 'f', -1, 64)
+	}
+	return properties
+}
+
+// roundSignificant rounds x to n significant digits.
+func roundSignificant(x float64, n int) float64 {
+	if x <= 0 {
+		return 0
+	}
+	exp := float64(n) - math.Ceil(math.Log10(x))
+	if exp < 0 {
+		// Multiply by an integer power so that the result is an exact integer
+		return math.Round(x/math.Pow(10, -exp)) * math.Pow(10, -exp)
+	}
+	return math.Round(x*math.Pow(10, exp)) / math.Pow(10, exp)
+}
@@ -0,0 +1,230 @@
+package link
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	satv2 "ws/dtn-satellite-sdn/sdn/type/v2"
+)
+
+// DistanceFunc returns the distance(km) between two nodes by their indices,
+// which is computed on demand instead of stored in a dense matrix.
+type DistanceFunc func(idx1, idx2 int) float64
+
+// MotifOffset is a relative position in the (plane, in-plane index) grid.
+// e.g. {0, 1} means the next satellite in the same plane, {1, 0} means the satellite in the right plane.
+type MotifOffset struct {
+	PlaneOffset int
+	IndexOffset int
+}
+
+// Function: GetTopoByMotif
+// Description: Return connection graph in which every satellite connects to satellites at motif offsets.
+// Planes are ordered by trackID, and the in-plane index is scaled when adjacent planes have different sizes.
+// Return type: UUID -> []UUID
+// 1. groups: Satellite groups(orbital planes).
+// 2. motif: Relative positions to connect.
+// 3. curTime: Standard time to check line of sight.
+func GetTopoByMotif(groups map[int]*satv2.Group, motif []MotifOffset, curTime time.Time) map[string][]string {
+	planes := SortGroupsByTrackID(groups)
+	result := map[string][]string{}
+	for planeIdx, plane := range planes {
+		for idx := range plane.Nodes {
+			node := &plane.Nodes[idx]
+			if _, ok := result[node.UUID]; !ok {
+				result[node.UUID] = []string{}
+			}
+			for _, offset := range motif {
+				peerPlane := planes[((planeIdx+offset.PlaneOffset)%len(planes)+len(planes))%len(planes)]
+				if peerPlane.Len() == 0 {
+					continue
+				}
+				peerIdx := idx*peerPlane.Len()/plane.Len() + offset.IndexOffset
+				peerIdx = (peerIdx%peerPlane.Len() + peerPlane.Len()) % peerPlane.Len()
+				peer := &peerPlane.Nodes[peerIdx]
+				if peer.UUID == node.UUID || !node.LineOfSightWithNodeAtTime(peer, curTime) {
+					continue
+				}
+				result[node.UUID] = append(result[node.UUID], peer.UUID)
+			}
+		}
+	}
+	return result
+}
+
+// Function: GetNearestKTopo
+// Description: Return connection graph in which every satellite connects to its k nearest visible satellites.
+// Return type: UUID -> []UUID
+// 1. lowOrbitIdxs: Indices of low-orbit satellites.
+// 2. k: The number of neighbours selected by each satellite.
+// 3. distance: Function returning distance between nodes
+// 4. indexUUIDMap: map from index to uuid
+// 5. uuidNodeMap: map from uuid to node
+// 6. curTime: Standard time to check line of sight.
+func GetNearestKTopo(
+	lowOrbitIdxs []int, k int, distance DistanceFunc,
+	indexUUIDMap map[int]string, uuidNodeMap map[string]*satv2.Node, curTime time.Time) map[string][]string {
+	result := map[string][]string{}
+	for _, nodeIdx := range lowOrbitIdxs {
+		node := uuidNodeMap[indexUUIDMap[nodeIdx]]
+		candidates := make([]int, 0, len(lowOrbitIdxs))
+		distances := map[int]float64{}
+		for _, otherIdx := range lowOrbitIdxs {
+			if otherIdx != nodeIdx {
+				candidates = append(candidates, otherIdx)
+				distances[otherIdx] = distance(nodeIdx, otherIdx)
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			return distances[candidates[i]] < distances[candidates[j]]
+		})
+		result[node.UUID] = []string{}
+		for _, otherIdx := range candidates {
+			if len(result[node.UUID]) >= k {
+				break
+			}
+			if node.LineOfSightWithNodeAtTime(uuidNodeMap[indexUUIDMap[otherIdx]], curTime) {
+				result[node.UUID] = append(result[node.UUID], indexUUIDMap[otherIdx])
+			}
+		}
+	}
+	return result
+}
+
+// Function: GetMaxRangeTopo
+// Description: Return connection graph in which every pair of visible satellites within maxRange is connected.
+// Return type: UUID -> []UUID
+// 1. lowOrbitIdxs: Indices of low-orbit satellites.
+// 2. maxRange: The max length(km) of inter-satellite link.
+// 3. distance: Function returning distance between nodes
+// 4. indexUUIDMap: map from index to uuid
+// 5. uuidNodeMap: map from uuid to node
+// 6. curTime: Standard time to check line of sight.
+func GetMaxRangeTopo(
+	lowOrbitIdxs []int, maxRange float64, distance DistanceFunc,
+	indexUUIDMap map[int]string, uuidNodeMap map[string]*satv2.Node, curTime time.Time) map[string][]string {
+	result := map[string][]string{}
+	for _, nodeIdx := range lowOrbitIdxs {
+		node := uuidNodeMap[indexUUIDMap[nodeIdx]]
+		result[node.UUID] = []string{}
+		for _, otherIdx := range lowOrbitIdxs {
+			if otherIdx != nodeIdx && distance(nodeIdx, otherIdx) <= maxRange &&
+				node.LineOfSightWithNodeAtTime(uuidNodeMap[indexUUIDMap[otherIdx]], curTime) {
+				result[node.UUID] = append(result[node.UUID], indexUUIDMap[otherIdx])
+			}
+		}
+	}
+	return result
+}
+
+// Function: SortGroupsByTrackID
+// Description: Return groups in ascending order of trackID.
+func SortGroupsByTrackID(groups map[int]*satv2.Group) []*satv2.Group {
+	result := make([]*satv2.Group, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].TrackID < result[j].TrackID
+	})
+	return result
+}
+
+// Function: GetPlaneNormal
+// Description: Return the unit normal vector of an orbital plane, which points along the angular momentum.
+// Satellites in the group should be ordered by inTrackID, i.e. along the direction of motion.
+// Return (0, 0, 0) if the group has less than two satellites.
+// 1. group: Satellite group(orbital plane).
+// 2. curTime: Standard time to compute positions.
+func GetPlaneNormal(group *satv2.Group, curTime time.Time) (x, y, z float64) {
+	num := group.Len()
+	if num < 2 {
+		return 0, 0, 0
+	}
+	for idx := 0; idx < num; idx++ {
+		// Cross product of consecutive satellites' positions
+		x1, y1, z1 := group.Nodes[idx].PositionAtTime(curTime)
+		x2, y2, z2 := group.Nodes[(idx+1)%num].PositionAtTime(curTime)
+		x += y1*z2 - z1*y2
+		y += z1*x2 - x1*z2
+		z += x1*y2 - y1*x2
+		if num == 2 {
+			break
+		}
+	}
+	norm := math.Sqrt(x*x + y*y + z*z)
+	if norm == 0 {
+		return 0, 0, 0
+	}
+	return x / norm, y / norm, z / norm
+}
+
+// Function: GetTopoAmongGroups
+// Description: Return connection graph in which every satellite connects to its num nearest visible satellites
+// in other groups, which is used for the high-orbit layer.
+// Return type: UUID -> []UUID
+// 1. groups: Satellite groups(orbital planes).
+// 2. num: The number of neighbours in other groups selected by each satellite.
+// 3. curTime: Standard time to compute distance and check line of sight.
+func GetTopoAmongGroups(groups []*satv2.Group, num int, curTime time.Time) map[string][]string {
+	result := map[string][]string{}
+	for groupIdx, group := range groups {
+		others := make([]*satv2.Group, 0, len(groups)-1)
+		others = append(others, groups[:groupIdx]...)
+		others = append(others, groups[groupIdx+1:]...)
+		for idx := range group.Nodes {
+			node := &group.Nodes[idx]
+			result[node.UUID] = getNearestVisibleNodes(node, others, num, curTime)
+		}
+	}
+	return result
+}
+
+// Function: GetCrossLayerTopo
+// Description: Return connection graph in which every high-orbit satellite relays for its num nearest visible
+// low-orbit satellites.
+// Return type: UUID -> []UUID
+// 1. highGroups: High-orbit satellite groups.
+// 2. lowGroups: Low-orbit satellite groups.
+// 3. num: The number of low-orbit satellites connected by each high-orbit satellite.
+// 4. curTime: Standard time to compute distance and check line of sight.
+func GetCrossLayerTopo(highGroups, lowGroups []*satv2.Group, num int, curTime time.Time) map[string][]string {
+	result := map[string][]string{}
+	for _, group := range highGroups {
+		for idx := range group.Nodes {
+			node := &group.Nodes[idx]
+			result[node.UUID] = getNearestVisibleNodes(node, lowGroups, num, curTime)
+		}
+	}
+	return result
+}
+
+// getNearestVisibleNodes returns UUIDs of at most num nearest nodes in groups visible to node.
+func getNearestVisibleNodes(node *satv2.Node, groups []*satv2.Group, num int, curTime time.Time) []string {
+	type candidate struct {
+		uuid     string
+		distance float64
+	}
+	candidates := []candidate{}
+	for _, group := range groups {
+		for idx := range group.Nodes {
+			other := &group.Nodes[idx]
+			if other.UUID == node.UUID || !node.LineOfSightWithNodeAtTime(other, curTime) {
+				continue
+			}
+			candidates = append(candidates, candidate{
+				uuid:     other.UUID,
+				distance: node.DistanceWithNodeAtTime(other, curTime),
+			})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+	result := []string{}
+	for idx := 0; idx < len(candidates) && idx < num; idx++ {
+		result = append(result, candidates[idx].uuid)
+	}
+	return result
+}
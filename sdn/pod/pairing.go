@@ -0,0 +1,117 @@
+package pod
+
+import "sort"
+
+const (
+	// ClientRole is the label "type" of users running iperf clients
+	ClientRole = "client"
+
+	// ServerRole is the label "type" of users running iperf servers
+	ServerRole = "server"
+)
+
+// UserPairing pairs users as iperf clients and servers. Pods keep the role and the server they were created with,
+// so the pairing of existing users never changes as users join or leave.
+type UserPairing struct {
+	// roles stores user's uuid -> ClientRole or ServerRole
+	roles map[string]string
+
+	// servers stores client's uuid -> its server's uuid, clients whose servers have left are not stored
+	servers map[string]string
+
+	// waiting stores servers without clients in the order they joined
+	waiting []string
+}
+
+func NewUserPairing() *UserPairing {
+	return &UserPairing{
+		roles:   make(map[string]string),
+		servers: make(map[string]string),
+	}
+}
+
+// Function: Update
+// Description: Pair users joining since last update and forget users having left. Users of the first update are
+// split by halves, the first half are clients of the second half. Later a joining user becomes the client of
+// the earliest server without client, or a server waiting for a client if there is none.
+// 1. users: Uuids of all current users in ascending order of their indices.
+func (p *UserPairing) Update(users []string) {
+	current := make(map[string]bool, len(users))
+	for _, uuid := range users {
+		current[uuid] = true
+	}
+	// Leaving users are handled in order, so that servers of leaving clients wait in the same order every time
+	left := []string{}
+	for uuid := range p.roles {
+		if !current[uuid] {
+			left = append(left, uuid)
+		}
+	}
+	sort.Strings(left)
+	for _, uuid := range left {
+		role := p.roles[uuid]
+		delete(p.roles, uuid)
+		if role == ClientRole {
+			if server, ok := p.servers[uuid]; ok && current[server] {
+				p.waiting = append(p.waiting, server)
+			}
+			delete(p.servers, uuid)
+		}
+	}
+	// Servers which have left stop waiting, and their clients are orphaned but keep their role
+	waiting := []string{}
+	for _, server := range p.waiting {
+		if current[server] {
+			waiting = append(waiting, server)
+		}
+	}
+	p.waiting = waiting
+	for client, server := range p.servers {
+		if !current[server] {
+			delete(p.servers, client)
+		}
+	}
+
+	if len(p.roles) == 0 {
+		half := len(users) / 2
+		for i, uuid := range users {
+			if i < half {
+				p.roles[uuid] = ClientRole
+				p.servers[uuid] = users[i+half]
+			} else {
+				p.roles[uuid] = ServerRole
+			}
+		}
+		// The last server has no client if the number of users is odd
+		if len(users)%2 == 1 {
+			p.waiting = append(p.waiting, users[len(users)-1])
+		}
+		return
+	}
+	for _, uuid := range users {
+		if _, ok := p.roles[uuid]; ok {
+			continue
+		}
+		if len(p.waiting) > 0 {
+			p.roles[uuid] = ClientRole
+			p.servers[uuid] = p.waiting[0]
+			p.waiting = p.waiting[1:]
+		} else {
+			p.roles[uuid] = ServerRole
+			p.waiting = append(p.waiting, uuid)
+		}
+	}
+}
+
+// Function: Role
+// Description: Return ClientRole or ServerRole of the user, and "" if uuid is not a user.
+func (p *UserPairing) Role(uuid string) string {
+	return p.roles[uuid]
+}
+
+// Function: Server
+// Description: Return the server's uuid of the client, and false if uuid is not a client or its server has left.
+func (p *UserPairing) Server(uuid string) (string, bool) {
+	server, ok := p.servers[uuid]
+	return server, ok
+}
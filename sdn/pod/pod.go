@@ -3,11 +3,11 @@ package pod
 import (
 	"context"
 	"fmt"
-	"log"
 	"sync"
 	"ws/dtn-satellite-sdn/sdn/util"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	// "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/client-go/applyconfigurations/core/v1"
@@ -15,22 +15,21 @@ import (
 
 type PodMetadata struct {
 	IndexUUIDMap  map[int]string
-	UserIdxMin int
-	UserNum    int
+
+	// UUIDIndexMap stores uuid -> index of all nodes, by which addresses of servers are found
+	UUIDIndexMap map[string]int
+
+	// Pairing decides which users are clients and which are their servers
+	Pairing *UserPairing
 }
 
 func ParseLabels(index int, meta *PodMetadata) map[string]string {
 	result := map[string]string {
 		"k8s-app": "iperf",
 	}
-	if index >= meta.UserIdxMin && 
-		index < meta.UserIdxMin + meta.UserNum {
-		if index < meta.UserIdxMin + meta.UserNum / 2 {
-			result["type"] = "client"
-		} else {
-			result["type"] = "server"
-		}
-	} 
+	if role := meta.Pairing.Role(meta.IndexUUIDMap[index]); role != "" {
+		result["type"] = role
+	}
 	return result
 }
 
@@ -39,12 +38,13 @@ func ParseArgs(index int, meta *PodMetadata) string {
 		"./start.sh %s %d",
 		util.GetGlobalIP(uint(index)), index + 5000,
 	)
-	if index >= meta.UserIdxMin && 
-		index < meta.UserIdxMin + meta.UserNum {
-		if index < meta.UserIdxMin + meta.UserNum / 2 {
-			serverIP := util.GetGlobalIP(uint(index + meta.UserNum / 2))
-			result = fmt.Sprintf("echo %s > ip.conf;", serverIP) + result
-		} 
+	if util.IPFamily == util.IPv6 {
+		// podserver configures addresses and routes in IPv4 by default
+		result += " " + util.IPv6
+	}
+	if server, ok := meta.Pairing.Server(meta.IndexUUIDMap[index]); ok {
+		serverIP := util.GetGlobalIP(uint(meta.UUIDIndexMap[server]))
+		result = fmt.Sprintf("echo %s > ip.conf;", serverIP) + result
 	}
 	return result
 }
@@ -150,20 +150,44 @@ func PodSyncLoop(meta *PodMetadata, uuidAllocNodeMap map[string]string) error {
 	opts := metav1.ApplyOptions{
 		FieldManager: "application/apply-patch",
 	}
-	// Apply pods
+	// Apply pods, pods left by a failed sync are applied again
+	var errOnce sync.Once
+	var applyErr error
 	wg := new(sync.WaitGroup)
 	wg.Add(util.ThreadNums)
 	for threadId := 0; threadId < util.ThreadNums; threadId++ {
 		go func(id int) {
 			for podId := id; podId < len(podList); podId += util.ThreadNums {
 				pod := podList[podId]
-				if _, err := clientset.CoreV1().Pods(namespace).Apply(context.TODO(), pod, opts); err != nil {
-					log.Fatalf("apply pod error: %v", err)
+				if _, err := clientset.CoreV1().Pods(namespace).Apply(context.TODO(), pod, opts); err != nil && !apierrors.IsAlreadyExists(err) {
+					errOnce.Do(func() {
+						applyErr = fmt.Errorf("apply pod %s error: %v", *pod.Name, err)
+					})
 				}
 			}
 			wg.Done()
 		}(threadId)
 	}
 	wg.Wait()
+	return applyErr
+}
+
+// Function: DeletePods
+// Description: Delete pods of nodes which have left the emulation.
+// 1. names: Names of pods to delete.
+func DeletePods(names []string) error {
+	clientset, err := util.GetClientset()
+	if err != nil {
+		return fmt.Errorf("CREATE CLIENTSET ERROR: %v", err)
+	}
+	namespace, err := util.GetNamespace()
+	if err != nil {
+		return fmt.Errorf("GET NAMESPACE ERROR: %v", err)
+	}
+	for _, name := range names {
+		if err := clientset.CoreV1().Pods(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete pod %s error: %v", name, err)
+		}
+	}
 	return nil
 }
@@ -1,2 +1,43 @@
 package pod
 
+import "testing"
+
+func TestUserPairing(t *testing.T) {
+	pairing := NewUserPairing()
+	check := func(uuid, role, server string) {
+		t.Helper()
+		if got := pairing.Role(uuid); got != role {
+			t.Errorf("role of %s is %q, want %q", uuid, got, role)
+		}
+		got, ok := pairing.Server(uuid)
+		if server == "" && ok {
+			t.Errorf("%s should have no server, got %s", uuid, got)
+		} else if server != "" && got != server {
+			t.Errorf("server of %s is %q, want %q", uuid, got, server)
+		}
+	}
+
+	// Users of the first update are split by halves, and the last server waits for a client
+	pairing.Update([]string{"a", "b", "c", "d", "e"})
+	check("a", ClientRole, "c")
+	check("b", ClientRole, "d")
+	check("c", ServerRole, "")
+	check("e", ServerRole, "")
+
+	// A joining user is the client of the waiting server, and the next one waits as a server
+	pairing.Update([]string{"a", "b", "c", "d", "e", "f", "g"})
+	check("f", ClientRole, "e")
+	check("g", ServerRole, "")
+
+	// The server of a leaving client waits for a joining user, existing users keep their roles
+	pairing.Update([]string{"b", "c", "d", "e", "f", "g", "h", "i"})
+	check("a", "", "")
+	check("b", ClientRole, "d")
+	check("h", ClientRole, "g")
+	check("i", ClientRole, "c")
+
+	// The client of a leaving server is orphaned but is still a client
+	pairing.Update([]string{"b", "c", "e", "f", "g", "h", "i"})
+	check("b", ClientRole, "")
+	check("f", ClientRole, "e")
+}
@@ -0,0 +1,206 @@
+package route
+
+import (
+	"math"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultWeightTolerance is the default relative change of an edge's weight regarded as a change of topology,
+// 0 means that routes are always the shortest ones.
+const DefaultWeightTolerance = 0.0
+
+// RouteEngine keeps the route table between updates, and recomputes only sources whose shortest paths are affected
+// by changed edges. Sources affected are found by distances to ends of changed edges computed on demand,
+// so neither distances nor shortest path trees of all sources are kept.
+type RouteEngine struct {
+	// Tolerance is the relative change of an edge's weight below which the edge is regarded as unchanged,
+	// 0 means that any change of weight is a change of edge.
+	// Weights kept may be higher or lower than current ones by up to Tolerance, so routes computed with
+	// tolerance(<1) are at most (1+Tolerance)/(1-Tolerance) times longer than the shortest ones.
+	Tolerance float64
+
+	threadNum int
+
+	// weights stores weights of edges used by the last computation
+	weights Graph
+
+	// table stores next hops from all sources, whose rows are replaced by recomputation
+	table *RouteTable
+}
+
+// edgeChange is a directed edge whose weight changed from oldWeight to newWeight.
+type edgeChange struct {
+	from, to             int
+	oldWeight, newWeight float64
+}
+
+// Function: NewRouteEngine
+// Description: Create RouteEngine without any computed route.
+// 1. tolerance: The relative change of an edge's weight regarded as a change of edge.
+// 2. threadNum: The number of goroutines to recompute routes.
+func NewRouteEngine(tolerance float64, threadNum int) *RouteEngine {
+	return &RouteEngine{
+		Tolerance: tolerance,
+		threadNum: threadNum,
+	}
+}
+
+// Function: Update
+// Description: Update shortest paths with new graph, and return the route table(next hops) of all nodes.
+// Routes are recomputed from scratch when the number of nodes changes.
+// Return route table and sources whose next hops are recomputed. The route table shares unchanged rows
+// with the engine, which are never modified in place.
+// 1. graph: Weights of edges, which is not modified.
+func (e *RouteEngine) Update(graph Graph) (*RouteTable, []int) {
+	nodeCount := len(graph)
+	if len(e.weights) != nodeCount {
+		e.weights = NewGraph(nodeCount)
+		e.table = NewRouteTable(nodeCount)
+		sources := make([]int, nodeCount)
+		for i := 0; i < nodeCount; i++ {
+			e.weights[i] = append([]Edge{}, graph[i]...)
+			sources[i] = i
+		}
+		e.recompute(sources)
+		return e.table.Clone(), sources
+	}
+
+	// Collect edges changed beyond tolerance, weights of other edges are kept as before
+	changes := []edgeChange{}
+	weights := NewGraph(nodeCount)
+	for i := 0; i < nodeCount; i++ {
+		oldEdges, newEdges := e.weights[i], graph[i]
+		edges := make([]Edge, 0, len(newEdges))
+		for p, q := 0, 0; p < len(oldEdges) || q < len(newEdges); {
+			switch {
+			case q == len(newEdges) || (p < len(oldEdges) && oldEdges[p].To < newEdges[q].To):
+				// Removed edge
+				changes = append(changes, edgeChange{from: i, to: oldEdges[p].To, oldWeight: oldEdges[p].Weight, newWeight: Unreachable})
+				p++
+			case p == len(oldEdges) || newEdges[q].To < oldEdges[p].To:
+				// Added edge
+				changes = append(changes, edgeChange{from: i, to: newEdges[q].To, oldWeight: Unreachable, newWeight: newEdges[q].Weight})
+				edges = append(edges, newEdges[q])
+				q++
+			default:
+				if e.isChanged(oldEdges[p].Weight, newEdges[q].Weight) {
+					changes = append(changes, edgeChange{from: i, to: newEdges[q].To, oldWeight: oldEdges[p].Weight, newWeight: newEdges[q].Weight})
+					edges = append(edges, newEdges[q])
+				} else {
+					edges = append(edges, oldEdges[p])
+				}
+				p++
+				q++
+			}
+		}
+		weights[i] = edges
+	}
+
+	// Sources affected are found with weights before the changes
+	sources := e.affectedSources(changes)
+	e.weights = weights
+	e.recompute(sources)
+	logrus.WithFields(logrus.Fields{
+		"changed-edges":    len(changes),
+		"affected-sources": len(sources),
+	}).Debug("update routes incrementally")
+	return e.table.Clone(), sources
+}
+
+// affectedSources returns sources in ascending order whose shortest paths in weights may be changed by changes.
+// A source is affected if a longer edge is on one of its shortest paths(the edge is tight),
+// or a shorter edge shortens the path to its end, which is decided by distances from all sources to both ends
+// of the edge, i.e. dijkstra from both ends on the reverse graph. All sources are affected if there are so many
+// changes that computing these distances costs more than recomputing all sources.
+func (e *RouteEngine) affectedSources(changes []edgeChange) []int {
+	nodeCount := len(e.weights)
+	sources := []int{}
+	if 2*len(changes) >= nodeCount {
+		for src := 0; src < nodeCount; src++ {
+			sources = append(sources, src)
+		}
+		return sources
+	}
+	reverse := e.weights.Reverse()
+	affected := make([][]bool, e.threadNum)
+	var wg sync.WaitGroup
+	wg.Add(e.threadNum)
+	for threadID := 0; threadID < e.threadNum; threadID++ {
+		go func(id int) {
+			defer wg.Done()
+			affected[id] = make([]bool, nodeCount)
+			distToFrom, distToTo := make([]float64, nodeCount), make([]float64, nodeCount)
+			nextHop := make([]int, nodeCount)
+			for pos := id; pos < len(changes); pos += e.threadNum {
+				change := changes[pos]
+				dijkstra(reverse, change.from, distToFrom, nextHop)
+				dijkstra(reverse, change.to, distToTo, nextHop)
+				for src := 0; src < nodeCount; src++ {
+					if distToFrom[src] >= Unreachable {
+						continue
+					}
+					// Distances to both ends are summed in different orders, so ties are compared with a margin
+					margin := 1e-9 * math.Max(1, distToTo[src])
+					if (change.newWeight > change.oldWeight && distToFrom[src]+change.oldWeight <= distToTo[src]+margin) ||
+						(change.newWeight < change.oldWeight && distToFrom[src]+change.newWeight < distToTo[src]-margin) {
+						affected[id][src] = true
+					}
+				}
+			}
+		}(threadID)
+	}
+	wg.Wait()
+	for src := 0; src < nodeCount; src++ {
+		for id := range affected {
+			if affected[id][src] {
+				sources = append(sources, src)
+				break
+			}
+		}
+	}
+	return sources
+}
+
+func (e *RouteEngine) isChanged(oldWeight, newWeight float64) bool {
+	return math.Abs(newWeight-oldWeight) > e.Tolerance*oldWeight
+}
+
+// recompute runs dijkstra from sources in parallel, and replaces their rows in the route table.
+func (e *RouteEngine) recompute(sources []int) {
+	nodeCount := len(e.weights)
+	var wg sync.WaitGroup
+	wg.Add(e.threadNum)
+	for threadID := 0; threadID < e.threadNum; threadID++ {
+		go func(id int) {
+			defer wg.Done()
+			dist, nextHop := make([]float64, nodeCount), make([]int, nodeCount)
+			for pos := id; pos < len(sources); pos += e.threadNum {
+				src := sources[pos]
+				dijkstra(e.weights, src, dist, nextHop)
+				for dst := range nextHop {
+					if dist[dst] >= Unreachable {
+						nextHop[dst] = NoRoute
+					}
+				}
+				e.table.SetRow(src, nextHop)
+			}
+		}(threadID)
+	}
+	wg.Wait()
+}
+
+// Function: Graph
+// Description: Return weights of edges used by the last computation, which should not be modified.
+func (e *RouteEngine) Graph() Graph {
+	return e.weights
+}
+
+// Function: Distances
+// Description: Return distances of shortest paths from src to all nodes in Graph, which are computed on demand.
+func (e *RouteEngine) Distances(src int) []float64 {
+	dist, nextHop := make([]float64, len(e.weights)), make([]int, len(e.weights))
+	dijkstra(e.weights, src, dist, nextHop)
+	return dist
+}
@@ -0,0 +1,136 @@
+package route
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Unreachable is the distance of nodes not directly connected or not reachable.
+const Unreachable = 1e9
+
+// Edge is a directed edge to node To.
+type Edge struct {
+	To     int
+	Weight float64
+}
+
+// Graph is a sparse graph in adjacency list.
+// Graph[i] stores edges from node i in ascending order of To.
+type Graph [][]Edge
+
+// Function: NewGraph
+// Description: Create a graph with nodeCount nodes and no edge.
+func NewGraph(nodeCount int) Graph {
+	return make(Graph, nodeCount)
+}
+
+// Function: NewGraphFromMatrix
+// Description: Create a graph from the dense distance matrix.
+// 1. distanceMap: Unreachable for edges not directly connected, and the diagonal is ignored.
+func NewGraphFromMatrix(distanceMap [][]float64) Graph {
+	graph := NewGraph(len(distanceMap))
+	for i := range distanceMap {
+		for j, weight := range distanceMap[i] {
+			if i != j && weight < Unreachable {
+				graph[i] = append(graph[i], Edge{To: j, Weight: weight})
+			}
+		}
+	}
+	return graph
+}
+
+// Function: SetEdge
+// Description: Add the edge from node from to node to, or update its weight if it exists.
+func (g Graph) SetEdge(from, to int, weight float64) {
+	edges := g[from]
+	pos := sort.Search(len(edges), func(i int) bool { return edges[i].To >= to })
+	if pos < len(edges) && edges[pos].To == to {
+		edges[pos].Weight = weight
+		return
+	}
+	edges = append(edges, Edge{})
+	copy(edges[pos+1:], edges[pos:])
+	edges[pos] = Edge{To: to, Weight: weight}
+	g[from] = edges
+}
+
+// Function: Weight
+// Description: Return the weight of the edge from node from to node to, and false if it does not exist.
+func (g Graph) Weight(from, to int) (float64, bool) {
+	edges := g[from]
+	pos := sort.Search(len(edges), func(i int) bool { return edges[i].To >= to })
+	if pos < len(edges) && edges[pos].To == to {
+		return edges[pos].Weight, true
+	}
+	return Unreachable, false
+}
+
+// Function: Reverse
+// Description: Return the graph in which each edge is reversed, by which distances to a node are computed.
+func (g Graph) Reverse() Graph {
+	result := NewGraph(len(g))
+	// Edges are added in ascending order of from, so edges of each node stay in ascending order of To
+	for from, edges := range g {
+		for _, edge := range edges {
+			result[edge.To] = append(result[edge.To], Edge{To: from, Weight: edge.Weight})
+		}
+	}
+	return result
+}
+
+// distItem is an entry of the priority queue of dijkstra.
+type distItem struct {
+	node int
+	dist float64
+}
+
+// distHeap is a min heap of distItem, in which nodes with the same distance are ordered by index.
+type distHeap []distItem
+
+func (h distHeap) Len() int { return len(h) }
+func (h distHeap) Less(i, j int) bool {
+	if h[i].dist != h[j].dist {
+		return h[i].dist < h[j].dist
+	}
+	return h[i].node < h[j].node
+}
+func (h distHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *distHeap) Push(x interface{}) { *h = append(*h, x.(distItem)) }
+func (h *distHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// dijkstra computes shortest paths from src in graph with a binary heap.
+// dist and nextHop are filled with the distance and the next hop from src to each node.
+// The distance of an unreachable node is Unreachable and its next hop is itself.
+func dijkstra(graph Graph, src int, dist []float64, nextHop []int) {
+	for i := range graph {
+		dist[i] = Unreachable
+		nextHop[i] = i
+	}
+	dist[src] = 0
+	visited := make([]bool, len(graph))
+	h := &distHeap{{node: src, dist: 0}}
+	for h.Len() > 0 {
+		item := heap.Pop(h).(distItem)
+		if visited[item.node] {
+			continue
+		}
+		visited[item.node] = true
+		for _, edge := range graph[item.node] {
+			if visited[edge.To] || item.dist+edge.Weight >= dist[edge.To] {
+				continue
+			}
+			dist[edge.To] = item.dist + edge.Weight
+			if item.node == src {
+				nextHop[edge.To] = edge.To
+			} else {
+				nextHop[edge.To] = nextHop[item.node]
+			}
+			heap.Push(h, distItem{node: edge.To, dist: dist[edge.To]})
+		}
+	}
+}
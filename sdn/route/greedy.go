@@ -0,0 +1,142 @@
+package route
+
+import (
+	"math"
+	"sync"
+)
+
+// GreedyRouter forwards to the neighbour geographically closest to the destination, as long as it is closer than
+// the current node. Nodes at a local minimum, as well as nodes whose greedy next hops form a loop,
+// recover with the next hop of the shortest path.
+type GreedyRouter struct {
+	*RouteEngine
+}
+
+// routeFix is the next hop of src to dst replacing the greedy one.
+type routeFix struct {
+	src, dst, next int
+}
+
+func (r *GreedyRouter) Name() string {
+	return GreedyRouterName
+}
+
+// Function: ComputeRoutes
+// Description: Compute greedy next hops by positions, with shortest paths for recovery.
+func (r *GreedyRouter) ComputeRoutes(input *RouteInput) *RouteTable {
+	shortest, _ := r.Update(input.Graph)
+	nodeCount := len(input.Graph)
+	routeTable := NewRouteTable(nodeCount)
+	var wg sync.WaitGroup
+	wg.Add(r.threadNum)
+	for threadID := 0; threadID < r.threadNum; threadID++ {
+		go func(id int) {
+			defer wg.Done()
+			nextHops := make([]int, nodeCount)
+			for src := id; src < nodeCount; src += r.threadNum {
+				nextHops = shortest.Row(src, nextHops)
+				for dst, next := range nextHops {
+					if next != NoRoute {
+						nextHops[dst] = greedyNextHop(input, src, dst, next)
+					}
+				}
+				routeTable.SetRow(src, nextHops)
+			}
+		}(threadID)
+	}
+	wg.Wait()
+
+	// Loops to each destination are broken in parallel, while next hops are replaced afterwards
+	// since a row is shared by all destinations
+	fixes := make([][]routeFix, r.threadNum)
+	wg.Add(r.threadNum)
+	for threadID := 0; threadID < r.threadNum; threadID++ {
+		go func(id int) {
+			defer wg.Done()
+			for dst := id; dst < nodeCount; dst += r.threadNum {
+				fixes[id] = breakLoops(routeTable, shortest, dst, fixes[id])
+			}
+		}(threadID)
+	}
+	wg.Wait()
+	for _, threadFixes := range fixes {
+		for _, fix := range threadFixes {
+			routeTable.Set(fix.src, fix.dst, fix.next)
+		}
+	}
+	return routeTable
+}
+
+// greedyNextHop returns the neighbour of src closest to dst if it is closer than src, and fallback otherwise.
+func greedyNextHop(input *RouteInput, src, dst, fallback int) int {
+	if src == dst || input.Positions[src] == nil || input.Positions[dst] == nil {
+		return fallback
+	}
+	result, minDistance := fallback, euclidean(input.Positions[src], input.Positions[dst])
+	for _, edge := range input.Graph[src] {
+		if edge.To == dst {
+			return dst
+		}
+		if p := input.Positions[edge.To]; p != nil {
+			if distance := euclidean(p, input.Positions[dst]); distance < minDistance {
+				result, minDistance = edge.To, distance
+			}
+		}
+	}
+	return result
+}
+
+// breakLoops replaces next hops to dst of nodes on loops with next hops of shortest paths, until there is no loop,
+// and appends next hops replaced to fixes. Shortest next hops never loop, since the distance to dst decreases at each hop.
+func breakLoops(routeTable, shortest *RouteTable, dst int, fixes []routeFix) []routeFix {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	replaced := map[int]int{}
+	next := func(node int) int {
+		if hop, ok := replaced[node]; ok {
+			return hop
+		}
+		return routeTable.NextHop(node, dst)
+	}
+	for {
+		state := make([]int, routeTable.Len())
+		looped := false
+		for start := range state {
+			// Walk along next hops until dst, NoRoute, a node done before, or a node on the current walk(loop)
+			walk := []int{}
+			node := start
+			for node != dst && node != NoRoute && state[node] == unvisited {
+				state[node] = visiting
+				walk = append(walk, node)
+				node = next(node)
+			}
+			if node != dst && node != NoRoute && state[node] == visiting {
+				for looping := node; ; {
+					hop := next(looping)
+					replaced[looping] = shortest.NextHop(looping, dst)
+					if looping = hop; looping == node {
+						break
+					}
+				}
+				looped = true
+			}
+			for _, visited := range walk {
+				state[visited] = done
+			}
+		}
+		if !looped {
+			break
+		}
+	}
+	for node, hop := range replaced {
+		fixes = append(fixes, routeFix{src: node, dst: dst, next: hop})
+	}
+	return fixes
+}
+
+func euclidean(p1, p2 *[3]float64) float64 {
+	return math.Sqrt((p2[0]-p1[0])*(p2[0]-p1[0]) + (p2[1]-p1[1])*(p2[1]-p1[1]) + (p2[2]-p1[2])*(p2[2]-p1[2]))
+}
@@ -0,0 +1,183 @@
+package route
+
+import (
+	"container/heap"
+	"sort"
+)
+
+const (
+	// LinkDisjoint means that paths share no link
+	LinkDisjoint = "link"
+
+	// NodeDisjoint means that paths share no node except the source and the destination
+	NodeDisjoint = "node"
+)
+
+// Path is a loop-free path from Nodes[0] to Nodes[len(Nodes)-1] whose total weight is Weight.
+type Path struct {
+	Nodes  []int
+	Weight float64
+}
+
+// Function: KShortestPaths
+// Description: Return at most k loop-free paths from src to dst in ascending order of weight by Yen's algorithm.
+// 1. graph: Weights of edges.
+// 2. src: The source node.
+// 3. dst: The destination node.
+// 4. k: The max number of paths.
+func KShortestPaths(graph Graph, src, dst, k int) []Path {
+	result := []Path{}
+	first, ok := shortestPath(graph, src, dst, nil, nil)
+	if !ok || k <= 0 {
+		return result
+	}
+	result = append(result, first)
+	candidates := []Path{}
+	for len(result) < k {
+		prev := result[len(result)-1]
+		for i := 0; i < len(prev.Nodes)-1; i++ {
+			// Deviate from prev at the spur node, the root path before it is kept
+			spur, root := prev.Nodes[i], prev.Nodes[:i+1]
+			blockedEdges := map[[2]int]bool{}
+			for _, path := range result {
+				if len(path.Nodes) > i+1 && equalNodes(path.Nodes[:i+1], root) {
+					blockedEdges[[2]int{path.Nodes[i], path.Nodes[i+1]}] = true
+				}
+			}
+			blockedNodes := make([]bool, len(graph))
+			for _, node := range root[:i] {
+				blockedNodes[node] = true
+			}
+			spurPath, ok := shortestPath(graph, spur, dst, blockedNodes, blockedEdges)
+			if !ok {
+				continue
+			}
+			candidate := Path{
+				Nodes:  append(append([]int{}, root[:i]...), spurPath.Nodes...),
+				Weight: pathWeight(graph, root) + spurPath.Weight,
+			}
+			if !containsPath(result, candidate) && !containsPath(candidates, candidate) {
+				candidates = append(candidates, candidate)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		// Shorter paths and then fewer hops are preferred
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if candidates[i].Weight != candidates[j].Weight {
+				return candidates[i].Weight < candidates[j].Weight
+			}
+			return len(candidates[i].Nodes) < len(candidates[j].Nodes)
+		})
+		result = append(result, candidates[0])
+		candidates = candidates[1:]
+	}
+	return result
+}
+
+// Function: DisjointPaths
+// Description: Return at most k mutually disjoint paths from src to dst in ascending order of weight.
+// Paths are found greedily, each one is the shortest path avoiding links(or nodes) used by previous ones.
+// 1. graph: Weights of edges.
+// 2. src: The source node.
+// 3. dst: The destination node.
+// 4. k: The max number of paths.
+// 5. disjointness: LinkDisjoint or NodeDisjoint.
+func DisjointPaths(graph Graph, src, dst, k int, disjointness string) []Path {
+	result := []Path{}
+	blockedNodes := make([]bool, len(graph))
+	blockedEdges := map[[2]int]bool{}
+	for len(result) < k {
+		path, ok := shortestPath(graph, src, dst, blockedNodes, blockedEdges)
+		if !ok {
+			break
+		}
+		result = append(result, path)
+		// src is the same as dst
+		if len(path.Nodes) < 2 {
+			break
+		}
+		for i := 0; i < len(path.Nodes)-1; i++ {
+			blockedEdges[[2]int{path.Nodes[i], path.Nodes[i+1]}] = true
+			blockedEdges[[2]int{path.Nodes[i+1], path.Nodes[i]}] = true
+			if disjointness == NodeDisjoint && i > 0 {
+				blockedNodes[path.Nodes[i]] = true
+			}
+		}
+	}
+	return result
+}
+
+// shortestPath returns the shortest path from src to dst avoiding blocked nodes and edges, and false if there is none.
+// blockedNodes and blockedEdges may be nil.
+func shortestPath(graph Graph, src, dst int, blockedNodes []bool, blockedEdges map[[2]int]bool) (Path, bool) {
+	dist, parent := make([]float64, len(graph)), make([]int, len(graph))
+	for i := range graph {
+		dist[i] = Unreachable
+		parent[i] = -1
+	}
+	dist[src] = 0
+	visited := make([]bool, len(graph))
+	h := &distHeap{{node: src, dist: 0}}
+	for h.Len() > 0 {
+		item := heap.Pop(h).(distItem)
+		if visited[item.node] {
+			continue
+		}
+		visited[item.node] = true
+		if item.node == dst {
+			break
+		}
+		for _, edge := range graph[item.node] {
+			if visited[edge.To] || (blockedNodes != nil && blockedNodes[edge.To]) ||
+				blockedEdges[[2]int{item.node, edge.To}] || item.dist+edge.Weight >= dist[edge.To] {
+				continue
+			}
+			dist[edge.To] = item.dist + edge.Weight
+			parent[edge.To] = item.node
+			heap.Push(h, distItem{node: edge.To, dist: dist[edge.To]})
+		}
+	}
+	if dist[dst] >= Unreachable {
+		return Path{}, false
+	}
+	nodes := []int{}
+	for node := dst; node != -1; node = parent[node] {
+		nodes = append(nodes, node)
+	}
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	return Path{Nodes: nodes, Weight: dist[dst]}, true
+}
+
+func pathWeight(graph Graph, nodes []int) float64 {
+	weight := 0.0
+	for i := 0; i < len(nodes)-1; i++ {
+		w, _ := graph.Weight(nodes[i], nodes[i+1])
+		weight += w
+	}
+	return weight
+}
+
+func equalNodes(nodes1, nodes2 []int) bool {
+	if len(nodes1) != len(nodes2) {
+		return false
+	}
+	for i := range nodes1 {
+		if nodes1[i] != nodes2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsPath(paths []Path, path Path) bool {
+	for _, p := range paths {
+		if equalNodes(p.Nodes, path.Nodes) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package route
+
+import (
+	"math"
+	"sync"
+)
+
+// ContactPlan is the plan of contacts(links) of the current topology within a look-ahead horizon, predicted by
+// snapshots sampled over the horizon. Routes installed stay until the next update and packets never wait for
+// contacts appearing later, so a route is valid across the horizon only if each hop is a contact lasting all
+// snapshots, i.e. a path in the time-expanded graph which stays in the same links at every time step.
+type ContactPlan struct {
+	// Lasting stores contacts present in all snapshots, weighted by their worst weights within the horizon
+	Lasting Graph
+
+	// Breaking is the number of directed contacts of the current topology which break within the horizon
+	Breaking int
+}
+
+// Function: NewContactPlan
+// Description: Build the contact plan from snapshots of the topology.
+// 1. snapshots: Graphs with the same number of nodes at successive times, snapshots[0] is the current one.
+func NewContactPlan(snapshots []Graph) *ContactPlan {
+	plan := &ContactPlan{Lasting: NewGraph(0)}
+	if len(snapshots) == 0 {
+		return plan
+	}
+	current := snapshots[0]
+	plan.Lasting = NewGraph(len(current))
+	for i, edges := range current {
+		plan.Lasting[i] = make([]Edge, 0, len(edges))
+		for _, edge := range edges {
+			weight, lasting := edge.Weight, true
+			for _, snapshot := range snapshots[1:] {
+				w, ok := snapshot.Weight(i, edge.To)
+				if !ok {
+					lasting = false
+					break
+				}
+				weight = math.Max(weight, w)
+			}
+			if lasting {
+				plan.Lasting[i] = append(plan.Lasting[i], Edge{To: edge.To, Weight: weight})
+			} else {
+				plan.Breaking++
+			}
+		}
+	}
+	return plan
+}
+
+// Function: Lasts
+// Description: Return whether the contact from node from to node to lasts the whole horizon.
+func (p *ContactPlan) Lasts(from, to int) bool {
+	if from < 0 || from >= len(p.Lasting) {
+		return false
+	}
+	_, ok := p.Lasting.Weight(from, to)
+	return ok
+}
+
+// Function: Validate
+// Description: Check that routes survive the horizon, i.e. the next hop of each route is a lasting contact.
+// Routes whose next hops break within the horizon are set to NoRoute in routeTable, and returned as issues.
+// 1. nodes: Indices of nodes taking part in emulation, other indices are holes left by removed nodes.
+// 2. routeTable: Next hops to be installed, which are validated in place.
+// 3. threadNum: The number of goroutines to validate routes.
+func (p *ContactPlan) Validate(nodes map[int]string, routeTable *RouteTable, threadNum int) []RouteIssue {
+	nodeCount := routeTable.Len()
+	issues := make([][]RouteIssue, threadNum)
+	var wg sync.WaitGroup
+	wg.Add(threadNum)
+	for threadId := 0; threadId < threadNum; threadId++ {
+		go func(id int) {
+			defer wg.Done()
+			nextHops := make([]int, nodeCount)
+			for src := id; src < nodeCount; src += threadNum {
+				if _, ok := nodes[src]; !ok {
+					continue
+				}
+				// Each row is validated by one goroutine, which only replaces its own row
+				nextHops = routeTable.Row(src, nextHops)
+				breaking := false
+				for dst, next := range nextHops {
+					if _, ok := nodes[dst]; ok && dst != src && next != NoRoute && !p.Lasts(src, next) {
+						issues[id] = append(issues[id], RouteIssue{Src: src, Dst: dst, Kind: BreakingIssue})
+						nextHops[dst] = NoRoute
+						breaking = true
+					}
+				}
+				if breaking {
+					routeTable.SetRow(src, nextHops)
+				}
+			}
+		}(threadId)
+	}
+	wg.Wait()
+	merged := []RouteIssue{}
+	for _, threadIssues := range issues {
+		merged = append(merged, threadIssues...)
+	}
+	return merged
+}
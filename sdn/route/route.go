@@ -13,10 +13,11 @@ import (
 	"ws/dtn-satellite-sdn/sdn/util"
 
 	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func RouteSyncLoop(nameMap map[int]string, routeTable [][]int, isFirstTime bool) error {
+func RouteSyncLoop(nameMap map[int]string, routeTable *RouteTable, isFirstTime bool) error {
 	// Get RESTClient and clientset
 	restClient, err := util.GetRouteClient()
 	if err != nil {
@@ -76,34 +77,14 @@ func RouteSyncLoop(nameMap map[int]string, routeTable [][]int, isFirstTime bool)
 	// Construct routes
 	routeList := sdnv1.RouteList{}
 	routeKeyList := []string{}
-	for idx1 := range routeTable {
-		route := sdnv1.Route{
-			Spec: sdnv1.RouteSpec{
-				PodIP:    podIPTable[nameMap[idx1]],
-				SubPaths: []sdnv1.SubPath{},
-			},
-		}
-		route.APIVersion = "sdn.dtn-satellite-sdn/v1"
-		route.Kind = "Route"
-		route.Name = nameMap[idx1]
-		routeKeyList = append(
-			routeKeyList,
-			common.RouteKeyPrefix + common.VersionPrefix + "/" + route.Name,
-		)
-		for idx2 := range routeTable[idx1] {
-			if idx1 != idx2 {
-				// New routes for target Pod
-				route.Spec.SubPaths = append(
-					route.Spec.SubPaths,
-					sdnv1.SubPath{
-						Name:     nameMap[idx2],
-						TargetIP: util.GetGlobalIP(uint(idx2)),
-						NextIP:   util.GetVxlanIP(uint(routeTable[idx1][idx2]), uint(idx1)),
-					},
-				)
-			}
+	for idx1 := 0; idx1 < routeTable.Len(); idx1++ {
+		// Skip holes left by removed nodes
+		if _, ok := nameMap[idx1]; !ok {
+			continue
 		}
-		routeList.Items = append(routeList.Items, route)
+		route := buildRoute(nameMap, routeTable, idx1, podIPTable[nameMap[idx1]])
+		routeKeyList = append(routeKeyList, routeKey(route.Name))
+		routeList.Items = append(routeList.Items, *route)
 	}
 
 	// Create/update routeList with RESTClient according to variable isFirstTime
@@ -163,10 +144,185 @@ func RouteSyncLoop(nameMap map[int]string, routeTable [][]int, isFirstTime bool)
 	return nil
 }
 
+// buildRoute constructs the route of node idx1 which has subpaths to all other nodes reachable.
+func buildRoute(nameMap map[int]string, routeTable *RouteTable, idx1 int, podIP string) *sdnv1.Route {
+	route := sdnv1.Route{
+		Spec: sdnv1.RouteSpec{
+			PodIP:    podIP,
+			SubPaths: []sdnv1.SubPath{},
+		},
+	}
+	route.APIVersion = "sdn.dtn-satellite-sdn/v1"
+	route.Kind = "Route"
+	route.Name = nameMap[idx1]
+	for idx2, next := range routeTable.Row(idx1, nil) {
+		// Routes omitted by validation are not installed, so that packets to idx2 are dropped at idx1
+		if _, ok := nameMap[idx2]; ok && idx1 != idx2 && next != NoRoute {
+			// New routes for target Pod
+			route.Spec.SubPaths = append(
+				route.Spec.SubPaths,
+				sdnv1.SubPath{
+					Name:     nameMap[idx2],
+					TargetIP: util.GetGlobalIP(uint(idx2)),
+					NextIP:   util.GetVxlanIP(uint(next), uint(idx1)),
+				},
+			)
+		}
+	}
+	return &route
+}
+
+// routeKey returns the redis key storing resource version of route.
+func routeKey(name string) string {
+	return common.RouteKeyPrefix + common.VersionPrefix + "/" + name
+}
+
+// Function: UpdateRoutes
+// Description: Update only routes of the given nodes, whose next hops are changed.
+// 1. nameMap: node's index -> node's uuid.
+// 2. routeTable: Next hops of all nodes.
+// 3. idxs: Indices of nodes whose routes are updated.
+func UpdateRoutes(nameMap map[int]string, routeTable *RouteTable, idxs []int) error {
+	if len(idxs) == 0 {
+		return nil
+	}
+	restClient, err := util.GetRouteClient()
+	if err != nil {
+		return fmt.Errorf("config error: %v", err)
+	}
+	clientset, err := util.GetClientset()
+	if err != nil {
+		return fmt.Errorf("create clientset error: %v", err)
+	}
+	namespace, err := util.GetNamespace()
+	if err != nil {
+		return fmt.Errorf("get namespace error: %v", err)
+	}
+
+	// Construct routes
+	routeList := sdnv1.RouteList{}
+	routeKeyList := []string{}
+	for _, idx := range idxs {
+		name, ok := nameMap[idx]
+		if !ok {
+			continue
+		}
+		podIP := ""
+		if pod, err := clientset.CoreV1().Pods(namespace).Get(context.TODO(), name, v1.GetOptions{}); err == nil {
+			podIP = pod.Status.PodIP
+		}
+		routeList.Items = append(routeList.Items, *buildRoute(nameMap, routeTable, idx, podIP))
+		routeKeyList = append(routeKeyList, routeKey(name))
+	}
+	resourceVersionList, err := common.NewRedisClient().MultiGet(routeKeyList)
+	if err != nil {
+		return fmt.Errorf("fetch resource version error: %v", err)
+	}
+
+	var errOnce sync.Once
+	var updateErr error
+	wg := new(sync.WaitGroup)
+	wg.Add(util.ThreadNums)
+	for threadId := 0; threadId < util.ThreadNums; threadId++ {
+		go func(id int) {
+			defer wg.Done()
+			for routeId := id; routeId < len(routeList.Items); routeId += util.ThreadNums {
+				route := routeList.Items[routeId]
+				route.ResourceVersion = resourceVersionList[routeId]
+				if err := restClient.Put().
+					Namespace(namespace).
+					Resource("routes").
+					Name(route.Name).
+					Body(&route).
+					Do(context.TODO()).
+					Into(nil); err != nil {
+					errOnce.Do(func() {
+						updateErr = fmt.Errorf("update route %s failure: %v", route.Name, err)
+					})
+				}
+			}
+		}(threadId)
+	}
+	wg.Wait()
+	return updateErr
+}
+
+// Function: CreateRoutes
+// Description: Create routes without subpaths for nodes joining the emulation.
+// Existing routes are left as they are, e.g. when a failed sync is retried.
+// 1. names: Names of routes(nodes' uuid) to create.
+func CreateRoutes(names []string) error {
+	restClient, err := util.GetRouteClient()
+	if err != nil {
+		return fmt.Errorf("config error: %v", err)
+	}
+	namespace, err := util.GetNamespace()
+	if err != nil {
+		return fmt.Errorf("get namespace error: %v", err)
+	}
+	for _, name := range names {
+		route := sdnv1.Route{
+			Spec: sdnv1.RouteSpec{
+				SubPaths: []sdnv1.SubPath{},
+			},
+		}
+		route.APIVersion = "sdn.dtn-satellite-sdn/v1"
+		route.Kind = "Route"
+		route.Name = name
+		if err := restClient.Post().
+			Namespace(namespace).
+			Resource("routes").
+			Body(&route).
+			Do(context.TODO()).
+			Into(nil); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("create route %s failure: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Function: DeleteRoutes
+// Description: Delete routes of nodes which have left the emulation, as well as their resource versions in redis.
+// 1. names: Names of routes(nodes' uuid) to delete.
+func DeleteRoutes(names []string) error {
+	restClient, err := util.GetRouteClient()
+	if err != nil {
+		return fmt.Errorf("config error: %v", err)
+	}
+	namespace, err := util.GetNamespace()
+	if err != nil {
+		return fmt.Errorf("get namespace error: %v", err)
+	}
+	routeKeyList := []string{}
+	for _, name := range names {
+		if err := restClient.Delete().
+			Namespace(namespace).
+			Resource("routes").
+			Name(name).
+			Do(context.TODO()).
+			Error(); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete route %s failure: %v", name, err)
+		}
+		routeKeyList = append(routeKeyList, routeKey(name))
+	}
+	if len(routeKeyList) > 0 {
+		if err := common.NewRedisClient().MultiDel(routeKeyList); err != nil {
+			return fmt.Errorf("delete resource version error: %v", err)
+		}
+	}
+	return nil
+}
+
 // Return route table for all nodes
+// distanceMap: 1e9 for edges not directly connected.
 func ComputeRoutes(distanceMap [][]float64, threadNum int) [][]int {
+	return ComputeRoutesOnGraph(NewGraphFromMatrix(distanceMap), threadNum)
+}
+
+// Return route table for all nodes in the sparse graph, which is dense(n^2 next hops) unlike RouteTable of RouteEngine
+func ComputeRoutesOnGraph(graph Graph, threadNum int) [][]int {
 	// Initialzie routeTable
-	nodeCount := len(distanceMap)
+	nodeCount := len(graph)
 	routeTable := [][]int{}
 	for i := 0; i < nodeCount; i++ {
 		routeTable = append(routeTable, make([]int, nodeCount))
@@ -176,7 +332,7 @@ func ComputeRoutes(distanceMap [][]float64, threadNum int) [][]int {
 	var wg sync.WaitGroup
 	wg.Add(threadNum)
 	for idx := 0; idx < threadNum; idx++ {
-		go ComputeRouteThread(distanceMap, routeTable, idx, threadNum, &wg)
+		go ComputeRouteThread(graph, routeTable, idx, threadNum, &wg)
 	}
 	wg.Wait()
 
@@ -185,48 +341,11 @@ func ComputeRoutes(distanceMap [][]float64, threadNum int) [][]int {
 
 // Return certain nodes' route table
 // routeTable: means the next node packets forwarded.
-func ComputeRouteThread(distanceMap [][]float64, routeTable [][]int, threadID int, threadNum int, wg *sync.WaitGroup) {
+func ComputeRouteThread(graph Graph, routeTable [][]int, threadID int, threadNum int, wg *sync.WaitGroup) {
 	defer wg.Done()
-	nodeCount := len(distanceMap)
+	nodeCount := len(graph)
+	dist := make([]float64, nodeCount)
 	for idx := threadID; idx < nodeCount; idx += threadNum {
-		// Initialize some variables
-		visited := make([]bool, nodeCount)
-		dist := make([]float64, nodeCount)
-		copy(dist, distanceMap[idx])
-		visited[idx] = true
-		dijkstraPath := make([]int, nodeCount) // Record next hop nodes(default is node(idx))
-		for i := range dijkstraPath {
-			dijkstraPath[i] = i
-		}
-
-		// Use dijkstra algorithm to compute minimum distance and their routes
-		for i := 1; i < nodeCount; i++ {
-			// Use minDist and minIndex to record minimum distance and corresponding node's index
-			minDist, minIndex := 1e9, 0
-
-			// Iterate all nodes, find the next unvisted and nearst node to startNode.
-			for j := 0; j < nodeCount; j++ {
-				if !visited[j] && dist[j] < minDist {
-					minDist = dist[j]
-					minIndex = j
-				}
-			}
-
-			// Iterate other nodes, update their minium distance to startNode
-			for j := 0; j < nodeCount; j++ {
-				if !visited[j] && dist[minIndex]+distanceMap[minIndex][j] < dist[j] {
-					dist[j] = dist[minIndex] + distanceMap[minIndex][j]
-					dijkstraPath[j] = dijkstraPath[minIndex]
-				}
-			}
-
-			// Mark minIndex node as visited
-			visited[minIndex] = true
-		}
-
-		// Update next-hop table(routeTable)
-		for i := 0; i < nodeCount; i++ {
-			routeTable[idx][i] = dijkstraPath[i]
-		}
+		dijkstra(graph, idx, dist, routeTable[idx])
 	}
 }
@@ -29,3 +29,275 @@ func TestComputeRoutes(t *testing.T) {
 		t.Errorf("Result error!")
 	}
 }
+
+func TestRouteEngineUpdate(t *testing.T) {
+	var distanceMap = [][]float64{
+		{0, 100, 1200, 1e9, 1e9, 1e9},
+		{100, 0, 900, 300, 1e9, 1e9},
+		{1200, 900, 0, 400, 500, 1e9},
+		{1e9, 300, 400, 0, 1300, 1400},
+		{1e9, 1e9, 500, 1300, 0, 1500},
+		{1e9, 1e9, 1e9, 1400, 1500, 0},
+	}
+	engine := NewRouteEngine(0, 6)
+	first, sources := engine.Update(NewGraphFromMatrix(distanceMap))
+	if len(sources) != 6 || !reflect.DeepEqual(denseTable(first), ComputeRoutes(distanceMap, 6)) {
+		t.Errorf("Result error at first update!")
+	}
+	expected := ComputeRoutes(distanceMap, 6)
+
+	// Link 4-5 becomes longer, which is only used by node 4 and node 5
+	distanceMap[4][5], distanceMap[5][4] = 1600, 1600
+	routeTable, sources := engine.Update(NewGraphFromMatrix(distanceMap))
+	if !reflect.DeepEqual(sources, []int{4, 5}) {
+		t.Errorf("Expect only affected sources to be recomputed, got %v", sources)
+	}
+	if !reflect.DeepEqual(denseTable(routeTable), ComputeRoutes(distanceMap, 6)) {
+		t.Errorf("Result error after update! %v", denseTable(routeTable))
+	}
+	// Rows recomputed are replaced, so the table returned before is not modified
+	if !reflect.DeepEqual(denseTable(first), expected) || &first.rows[0][0] != &routeTable.rows[0][0] {
+		t.Errorf("Expect the first table to be unchanged and to share rows not recomputed, got %v", denseTable(first))
+	}
+	if dist := engine.Distances(0); dist[5] != 1800 {
+		t.Errorf("Expect distance 1800 from 0 to 5, got %v", dist)
+	}
+
+	// Link 1-3 is broken, and link 4-5 becomes much shorter
+	distanceMap[1][3], distanceMap[3][1] = 1e9, 1e9
+	distanceMap[4][5], distanceMap[5][4] = 500, 500
+	if routeTable, _ := engine.Update(NewGraphFromMatrix(distanceMap)); !reflect.DeepEqual(denseTable(routeTable), ComputeRoutes(distanceMap, 6)) {
+		t.Errorf("Result error after update! %v", denseTable(routeTable))
+	}
+
+	// Changes within tolerance are ignored
+	engine.Tolerance = 0.1
+	distanceMap[0][1], distanceMap[1][0] = 105, 105
+	if _, sources := engine.Update(NewGraphFromMatrix(distanceMap)); len(sources) != 0 {
+		t.Errorf("Expect no source to be recomputed, got %v", sources)
+	}
+}
+
+func TestContactPlan(t *testing.T) {
+	current := NewGraphFromMatrix([][]float64{
+		{0, 100, 200, 1e9},
+		{100, 0, 1e9, 300},
+		{200, 1e9, 0, 300},
+		{1e9, 300, 300, 0},
+	})
+	// Link 1-3 breaks and link 0-2 becomes longer later
+	later := NewGraphFromMatrix([][]float64{
+		{0, 100, 250, 1e9},
+		{100, 0, 1e9, 1e9},
+		{250, 1e9, 0, 300},
+		{1e9, 1e9, 300, 0},
+	})
+	if ComputeRoutesOnGraph(current, 4)[0][3] != 1 {
+		t.Errorf("Expect route via node 1 on current graph")
+	}
+	plan := NewContactPlan([]Graph{current, later})
+	if w, _ := plan.Lasting.Weight(0, 2); w != 250 {
+		t.Errorf("Expect worst weight 250, got %v", w)
+	}
+	if plan.Lasts(3, 1) || plan.Breaking != 2 {
+		t.Errorf("Expect link 1-3 to break in both directions, got %v", plan)
+	}
+	routeTable, _ := NewRouteEngine(0, 4).Update(plan.Lasting)
+	if nextHop := routeTable.NextHop(0, 3); nextHop != 2 {
+		t.Errorf("Expect route avoiding broken link via node 2, got %v", nextHop)
+	}
+
+	// Link 0-1 breaks as well, so no path to node 1 lasts, and it is unreachable instead of being routed
+	// over a breaking link
+	later = NewGraphFromMatrix([][]float64{
+		{0, 1e9, 250, 1e9},
+		{1e9, 0, 1e9, 1e9},
+		{250, 1e9, 0, 300},
+		{1e9, 1e9, 300, 0},
+	})
+	plan = NewContactPlan([]Graph{current, later})
+	routeTable, _ = NewRouteEngine(0, 4).Update(plan.Lasting)
+	if routeTable.NextHop(0, 1) != NoRoute || routeTable.NextHop(3, 1) != NoRoute {
+		t.Errorf("Expect node 1 to be unreachable, got %v", denseTable(routeTable))
+	}
+
+	// Routes installed are checked to survive the horizon
+	nodes := map[int]string{0: "a", 1: "b", 2: "c", 3: "d"}
+	routeTable = NewRouteTable(4)
+	routeTable.SetRow(3, []int{1, 1, 2, 3})
+	issues := plan.Validate(nodes, routeTable, 2)
+	if len(issues) != 2 || routeTable.NextHop(3, 0) != NoRoute || routeTable.NextHop(3, 2) != 2 {
+		t.Errorf("Expect routes of node 3 via breaking link 3-1 to be omitted, got %v", issues)
+	}
+	for _, issue := range issues {
+		if issue.Kind != BreakingIssue {
+			t.Errorf("Expect breaking issues, got %v", issues)
+		}
+	}
+}
+
+func TestMultiplePaths(t *testing.T) {
+	graph := NewGraphFromMatrix([][]float64{
+		{0, 100, 1200, 1e9, 1e9, 1e9},
+		{100, 0, 900, 300, 1e9, 1e9},
+		{1200, 900, 0, 400, 500, 1e9},
+		{1e9, 300, 400, 0, 1300, 1400},
+		{1e9, 1e9, 500, 1300, 0, 1500},
+		{1e9, 1e9, 1e9, 1400, 1500, 0},
+	})
+	// Paths of equal weight are ordered by hops
+	expected := [][]int{{0, 1, 3, 5}, {0, 1, 2, 3, 5}, {0, 1, 3, 2, 4, 5}}
+	paths := KShortestPaths(graph, 0, 5, 3)
+	for i, path := range paths {
+		if i >= len(expected) || !reflect.DeepEqual(path.Nodes, expected[i]) {
+			t.Errorf("Expect k-shortest paths %v, got %v", expected, paths)
+			break
+		}
+	}
+	if len(paths) != 3 || paths[1].Weight != 2800 {
+		t.Errorf("Expect 3 paths and the second one weighs 2800, got %v", paths)
+	}
+
+	// Only 2 link-disjoint paths leave node 0
+	paths = DisjointPaths(graph, 0, 5, 3, LinkDisjoint)
+	if len(paths) != 2 || !reflect.DeepEqual(paths[1].Nodes, []int{0, 2, 4, 5}) {
+		t.Errorf("Expect 2 disjoint paths, got %v", paths)
+	}
+
+}
+
+func TestRouters(t *testing.T) {
+	// Square 0-1-2-3 whose link 0-3 is much longer
+	square := NewGraphFromMatrix([][]float64{
+		{0, 1, 1e9, 10},
+		{1, 0, 1, 1e9},
+		{1e9, 1, 0, 1},
+		{10, 1e9, 1, 0},
+	})
+	input := &RouteInput{Graph: square}
+	expected := map[string]int{ShortestRouterName: 1, MinHopRouterName: 3}
+	for name, nextHop := range expected {
+		router, _ := NewRouter(name, 0, 2)
+		if routeTable := router.ComputeRoutes(input); routeTable.NextHop(0, 3) != nextHop {
+			t.Errorf("Expect next hop %d of %s router, got %d", nextHop, name, routeTable.NextHop(0, 3))
+		}
+	}
+	// Link 0->1 is congested
+	router, _ := NewRouter(LoadAwareRouterName, 0, 2)
+	if routeTable := router.ComputeRoutes(&RouteInput{Graph: square}); routeTable.NextHop(0, 3) != 1 {
+		t.Errorf("Expect idle load-aware router to route as shortest router")
+	}
+	input.Utilization = map[[2]int]float64{{0, 1}: 0.9}
+	if routeTable := router.ComputeRoutes(input); routeTable.NextHop(0, 3) != 3 || routeTable.NextHop(1, 0) != 0 {
+		t.Errorf("Expect load-aware router to avoid congested link, got %v", denseTable(routeTable))
+	}
+	if _, err := NewRouter("unknown", 0, 2); err == nil {
+		t.Errorf("Unknown router should be rejected")
+	}
+
+	// Node 0 is a local minimum towards node 3, whose recovery via node 1 loops with greedy forwarding of node 1
+	chain := NewGraphFromMatrix([][]float64{
+		{0, 1, 1e9, 1e9},
+		{1, 0, 1, 1e9},
+		{1e9, 1, 0, 1},
+		{1e9, 1e9, 1, 0},
+	})
+	positions := []*[3]float64{{5, 0, 0}, {0, 0, 0}, {0, 10, 0}, {10, 0, 0}}
+	router, _ = NewRouter(GreedyRouterName, 0, 2)
+	routeTable := denseTable(router.ComputeRoutes(&RouteInput{Graph: chain, Positions: positions}))
+	if routeTable[1][3] != 2 {
+		t.Errorf("Expect greedy loop 0<->1 to be broken, got %v", routeTable)
+	}
+	for src := range routeTable {
+		for dst := range routeTable {
+			node, hops := src, 0
+			for ; node != dst && hops < len(routeTable); hops++ {
+				node = routeTable[node][dst]
+			}
+			if node != dst {
+				t.Errorf("Expect greedy route from %d to reach %d, got %v", src, dst, routeTable)
+			}
+		}
+	}
+}
+
+func TestValidateRoutes(t *testing.T) {
+	// 0 - 1 - 2, while 3 is isolated
+	graph := NewGraph(4)
+	for _, edge := range [][2]int{{0, 1}, {1, 2}} {
+		graph.SetEdge(edge[0], edge[1], 1)
+		graph.SetEdge(edge[1], edge[0], 1)
+	}
+	nodes := map[int]string{0: "a", 1: "b", 2: "c", 3: "d"}
+	routeTable, _ := NewRouteEngine(0, 2).Update(graph)
+	routeTable.Set(0, 2, 2)
+	routeTable.Set(1, 0, 2)
+	routeTable.Set(2, 0, 1)
+	computed := routeTable.Clone()
+	issues := ValidateRoutes(graph, nodes, routeTable, nil, 2)
+	counts := map[string]int{}
+	for _, issue := range issues {
+		counts[issue.Kind]++
+		if routeTable.NextHop(issue.Src, issue.Dst) != NoRoute {
+			t.Errorf("Expect %s route %d->%d to be omitted", issue.Kind, issue.Src, issue.Dst)
+		}
+	}
+	if expected := map[string]int{UnreachableIssue: 6, BlackholeIssue: 1, LoopIssue: 2}; !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Expect issues %v, got %v", expected, issues)
+	}
+	if routeTable.NextHop(1, 2) != 2 || computed.NextHop(0, 2) != 2 {
+		t.Errorf("Expect valid routes to be kept and tables cloned before not to be modified")
+	}
+
+	// 0 switches to 2 via 1, while 1 still forwards to 2 via 0
+	graph.SetEdge(0, 2, 1)
+	graph.SetEdge(2, 0, 1)
+	routeTable, _ = NewRouteEngine(0, 2).Update(graph)
+	prevTable := routeTable.Clone()
+	routeTable.Set(0, 2, 1)
+	prevTable.Set(1, 2, 0)
+	issues = ValidateRoutes(graph, nodes, routeTable, prevTable, 2)
+	transient := 0
+	for _, issue := range issues {
+		if issue.Kind == TransientLoopIssue && issue.Dst == 2 {
+			transient++
+		}
+	}
+	if transient != 2 || routeTable.NextHop(0, 2) != 1 {
+		t.Errorf("Expect transient loop between 0 and 1 to be reported only, got %v", issues)
+	}
+}
+
+func TestRouteTable(t *testing.T) {
+	table := NewRouteTable(6)
+	table.SetRow(0, []int{0, 1, 1, 1, 2, 2})
+	if table.Runs() != 8 {
+		t.Errorf("Expect 3 runs of row 0 and 1 run of other rows, got %d", table.Runs())
+	}
+	cloned := table.Clone()
+	// Splitting the run 1-3 in the middle, and merging runs after setting it back
+	table.Set(0, 2, 2)
+	if expected := []int{0, 1, 2, 1, 2, 2}; !reflect.DeepEqual(table.Row(0, nil), expected) || table.Runs() != 10 {
+		t.Errorf("Expect row %v in 5 runs, got %v", expected, table.rows[0])
+	}
+	table.Set(0, 2, 1)
+	if !table.EqualRow(cloned, 0) || table.Runs() != 8 {
+		t.Errorf("Expect runs to be merged, got %v", table.rows[0])
+	}
+	table.Set(0, 5, NoRoute)
+	if table.NextHop(0, 5) != NoRoute || table.NextHop(0, 4) != 2 || cloned.NextHop(0, 5) != 2 {
+		t.Errorf("Expect only the table set to be modified, got %v and %v", table.rows[0], cloned.rows[0])
+	}
+	if table.NextHop(3, 1) != NoRoute {
+		t.Errorf("Expect NoRoute in a new table")
+	}
+}
+
+// denseTable expands the route table into next hops of all pairs of nodes.
+func denseTable(table *RouteTable) [][]int {
+	result := make([][]int, table.Len())
+	for src := range result {
+		result[src] = table.Row(src, nil)
+	}
+	return result
+}
@@ -0,0 +1,143 @@
+package route
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	ShortestRouterName  = "shortest"
+	MinHopRouterName    = "min-hop"
+	LoadAwareRouterName = "load-aware"
+	GreedyRouterName    = "greedy"
+)
+
+// DefaultCongestionFactor is the default factor of queueing delay added to link latency by load-aware routing.
+const DefaultCongestionFactor = 1.0
+
+// maxUtilization caps link utilization so that the queueing delay of saturated links is finite
+const maxUtilization = 0.99
+
+// RouteInput is the network snapshot with which routers compute routes.
+type RouteInput struct {
+	// Graph weights links by latency(ms)
+	Graph Graph
+
+	// Positions stores the position of each node in ECI, nil means a hole left by removed node
+	Positions []*[3]float64
+
+	// Utilization stores utilization(0~1) of directed links [from, to], links missing are regarded as idle
+	Utilization map[[2]int]float64
+}
+
+// Router computes the route table(next hops) of all nodes.
+type Router interface {
+	// Name returns the name used by `sdnctl init --router`
+	Name() string
+
+	// ComputeRoutes returns the route table, in which routeTable.NextHop(i, j) is the next hop of i to j.
+	// The route table may share rows with the router, which are never modified in place.
+	ComputeRoutes(input *RouteInput) *RouteTable
+
+	// Graph returns weights of links by which routes were computed last time, which should not be modified
+	Graph() Graph
+
+	// Distances returns distances of shortest paths in Graph from src to all nodes, which are computed on demand
+	Distances(src int) []float64
+}
+
+// ShortestRouter routes along paths of the lowest latency.
+type ShortestRouter struct {
+	*RouteEngine
+}
+
+// MinHopRouter routes along paths of the fewest hops, among which paths of lower latency are preferred.
+type MinHopRouter struct {
+	*RouteEngine
+}
+
+// LoadAwareRouter routes along paths of the lowest latency plus queueing delay estimated from link utilization.
+type LoadAwareRouter struct {
+	*RouteEngine
+
+	// CongestionFactor scales the queueing delay u/(1-u) times latency of a link with utilization u
+	CongestionFactor float64
+}
+
+// Function: NewRouter
+// Description: Create router by name.
+// 1. name: shortest/min-hop/load-aware/greedy.
+// 2. tolerance: The relative change[0, 1) of a link's weight below which routes over it are not recomputed.
+// 3. threadNum: The number of goroutines to compute routes.
+func NewRouter(name string, tolerance float64, threadNum int) (Router, error) {
+	if tolerance < 0 || tolerance >= 1 {
+		return nil, fmt.Errorf("tolerance %v should be in [0, 1)", tolerance)
+	}
+	engine := NewRouteEngine(tolerance, threadNum)
+	switch name {
+	case ShortestRouterName:
+		return &ShortestRouter{RouteEngine: engine}, nil
+	case MinHopRouterName:
+		return &MinHopRouter{RouteEngine: engine}, nil
+	case LoadAwareRouterName:
+		return &LoadAwareRouter{RouteEngine: engine, CongestionFactor: DefaultCongestionFactor}, nil
+	case GreedyRouterName:
+		return &GreedyRouter{RouteEngine: engine}, nil
+	default:
+		return nil, fmt.Errorf("unknown router %s", name)
+	}
+}
+
+func (r *ShortestRouter) Name() string {
+	return ShortestRouterName
+}
+
+func (r *ShortestRouter) ComputeRoutes(input *RouteInput) *RouteTable {
+	routeTable, _ := r.Update(input.Graph)
+	return routeTable
+}
+
+func (r *MinHopRouter) Name() string {
+	return MinHopRouterName
+}
+
+// Function: ComputeRoutes
+// Description: Weight each link by 1 hop plus its latency scaled down to break ties among paths of the same hops.
+func (r *MinHopRouter) ComputeRoutes(input *RouteInput) *RouteTable {
+	graph := mapWeights(input.Graph, func(from, to int, latency float64) float64 {
+		// Latency of a path is far less than 1e6 ms, so it never outweighs a hop
+		return 1 + latency*1e-6
+	})
+	routeTable, _ := r.Update(graph)
+	return routeTable
+}
+
+func (r *LoadAwareRouter) Name() string {
+	return LoadAwareRouterName
+}
+
+// Function: ComputeRoutes
+// Description: Weight each link by latency*(1+CongestionFactor*u/(1-u)), which grows fast as utilization u approaches 1.
+func (r *LoadAwareRouter) ComputeRoutes(input *RouteInput) *RouteTable {
+	graph := mapWeights(input.Graph, func(from, to int, latency float64) float64 {
+		u := math.Min(input.Utilization[[2]int{from, to}], maxUtilization)
+		if u <= 0 {
+			return latency
+		}
+		return latency * (1 + r.CongestionFactor*u/(1-u))
+	})
+	routeTable, _ := r.Update(graph)
+	return routeTable
+}
+
+// mapWeights returns a copy of graph whose weights are mapped by weight.
+func mapWeights(graph Graph, weight func(from, to int, w float64) float64) Graph {
+	result := NewGraph(len(graph))
+	for from, edges := range graph {
+		result[from] = make([]Edge, len(edges))
+		for i, edge := range edges {
+			result[from][i] = Edge{To: edge.To, Weight: weight(from, edge.To, edge.Weight)}
+		}
+	}
+	return result
+}
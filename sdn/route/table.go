@@ -0,0 +1,139 @@
+package route
+
+import "sort"
+
+// RouteTable is the route table(next hops) of all nodes in sparse form.
+// Nodes are indexed plane by plane, so a source usually forwards consecutive destinations to the same neighbour.
+// Each row stores runs of destinations sharing a next hop instead of a next hop per destination, and unreachable
+// destinations are NoRoute.
+// Rows are replaced instead of modified in place, so a table cloned from another shares rows changed by neither.
+type RouteTable struct {
+	rows [][]hopRun
+}
+
+// hopRun is the run of destinations from start until the start of the next run in a row, which are forwarded to next.
+type hopRun struct {
+	start int32
+	next  int32
+}
+
+// Function: NewRouteTable
+// Description: Create a route table of nodeCount nodes, in which all destinations are NoRoute.
+func NewRouteTable(nodeCount int) *RouteTable {
+	table := &RouteTable{rows: make([][]hopRun, nodeCount)}
+	for src := range table.rows {
+		table.rows[src] = []hopRun{{start: 0, next: NoRoute}}
+	}
+	return table
+}
+
+// Function: Len
+// Description: Return the number of nodes including holes left by removed nodes.
+func (t *RouteTable) Len() int {
+	return len(t.rows)
+}
+
+// Function: NextHop
+// Description: Return the next hop of src to dst, which is src itself if src == dst and NoRoute if dst is unreachable.
+func (t *RouteTable) NextHop(src, dst int) int {
+	row := t.rows[src]
+	pos := sort.Search(len(row), func(i int) bool { return int(row[i].start) > dst }) - 1
+	return int(row[pos].next)
+}
+
+// Function: Row
+// Description: Expand next hops of src to all destinations into nextHops, which is allocated if it is too short.
+func (t *RouteTable) Row(src int, nextHops []int) []int {
+	if len(nextHops) < len(t.rows) {
+		nextHops = make([]int, len(t.rows))
+	}
+	row := t.rows[src]
+	for i, run := range row {
+		end := len(t.rows)
+		if i+1 < len(row) {
+			end = int(row[i+1].start)
+		}
+		for dst := int(run.start); dst < end; dst++ {
+			nextHops[dst] = int(run.next)
+		}
+	}
+	return nextHops[:len(t.rows)]
+}
+
+// Function: SetRow
+// Description: Replace next hops of src with nextHops, which has a next hop for each destination.
+func (t *RouteTable) SetRow(src int, nextHops []int) {
+	row := []hopRun{}
+	for dst, next := range nextHops {
+		if dst == 0 || next != nextHops[dst-1] {
+			row = append(row, hopRun{start: int32(dst), next: int32(next)})
+		}
+	}
+	t.rows[src] = row
+}
+
+// Function: Set
+// Description: Replace the next hop of src to dst with next.
+func (t *RouteTable) Set(src, dst, next int) {
+	if t.NextHop(src, dst) == next {
+		return
+	}
+	row := t.rows[src]
+	pos := sort.Search(len(row), func(i int) bool { return int(row[i].start) > dst }) - 1
+	end := len(t.rows)
+	if pos+1 < len(row) {
+		end = int(row[pos+1].start)
+	}
+	// The run containing dst is split into at most 3 runs, and merged with its neighbours if they share next hops
+	runs := make([]hopRun, 0, len(row)+2)
+	runs = append(runs, row[:pos]...)
+	if int(row[pos].start) < dst {
+		runs = append(runs, row[pos])
+	}
+	runs = append(runs, hopRun{start: int32(dst), next: int32(next)})
+	if dst+1 < end {
+		runs = append(runs, hopRun{start: int32(dst + 1), next: row[pos].next})
+	}
+	runs = append(runs, row[pos+1:]...)
+	merged := runs[:1]
+	for _, run := range runs[1:] {
+		if run.next != merged[len(merged)-1].next {
+			merged = append(merged, run)
+		}
+	}
+	t.rows[src] = merged
+}
+
+// Function: Clone
+// Description: Return a copy of the table sharing rows with it, which costs a row header per node.
+func (t *RouteTable) Clone() *RouteTable {
+	return &RouteTable{rows: append([][]hopRun{}, t.rows...)}
+}
+
+// Function: EqualRow
+// Description: Return whether src has the same next hops to all destinations in both tables.
+func (t *RouteTable) EqualRow(other *RouteTable, src int) bool {
+	row1, row2 := t.rows[src], other.rows[src]
+	if len(row1) != len(row2) {
+		return false
+	}
+	if len(row1) > 0 && &row1[0] == &row2[0] {
+		return true
+	}
+	for i := range row1 {
+		if row1[i] != row2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Function: Runs
+// Description: Return the number of runs stored by all rows, which is n^2 at most for n nodes.
+func (t *RouteTable) Runs() int {
+	result := 0
+	for _, row := range t.rows {
+		result += len(row)
+	}
+	return result
+}
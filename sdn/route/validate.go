@@ -0,0 +1,247 @@
+package route
+
+import "sync"
+
+// NoRoute is the next hop of routes omitted by validation, for which no subpath is installed.
+const NoRoute = -1
+
+const (
+	// UnreachableIssue means that the destination is disconnected from the source in topology,
+	// or the router finds no route to it(e.g. no path lasts the look-ahead horizon)
+	UnreachableIssue = "unreachable"
+
+	// BlackholeIssue means that the next hop is not a neighbour in topology, e.g. a broken link or a removed node
+	BlackholeIssue = "blackhole"
+
+	// LoopIssue means that the next hop leads back to the source without reaching the destination
+	LoopIssue = "loop"
+
+	// TransientLoopIssue means that a loop forms while some nodes still forward by the previous route table
+	TransientLoopIssue = "transient-loop"
+
+	// BreakingIssue means that the link to the next hop breaks within the look-ahead horizon
+	BreakingIssue = "breaking"
+)
+
+// RouteIssue is a route from Src to Dst found invalid by validation.
+type RouteIssue struct {
+	Src  int
+	Dst  int
+	Kind string
+}
+
+// Function: ValidateRoutes
+// Description: Check routes against topology before they are installed. Unreachable destinations, blackholes and
+// loops are set to NoRoute in routeTable, so that packets are dropped at once instead of being misrouted.
+// Return issues found. Transient loops are only reported, since they vanish once all routes are updated.
+// 1. topo: The topology routes are installed on.
+// 2. nodes: Indices of nodes taking part in emulation, other indices are holes left by removed nodes.
+// 3. routeTable: Next hops computed by router, which are validated in place.
+// 4. prevTable: Next hops installed before with the same indices, nil means no transition.
+// 5. threadNum: The number of goroutines to validate routes.
+func ValidateRoutes(topo Graph, nodes map[int]string, routeTable, prevTable *RouteTable, threadNum int) []RouteIssue {
+	nodeCount := routeTable.Len()
+	if prevTable != nil && prevTable.Len() != nodeCount {
+		prevTable = nil
+	}
+	component := components(topo)
+	issues := make([][]RouteIssue, threadNum)
+	var wg sync.WaitGroup
+	wg.Add(threadNum)
+	for threadId := 0; threadId < threadNum; threadId++ {
+		go func(id int) {
+			defer wg.Done()
+			// Destinations are validated in parallel without modifying routeTable, since a row is shared by them
+			for dst := id; dst < nodeCount; dst += threadNum {
+				if _, ok := nodes[dst]; !ok {
+					continue
+				}
+				col := &column{table: routeTable, dst: dst, omitted: map[int]bool{}}
+				issues[id] = validateDestination(topo, nodes, component, col, issues[id])
+				if prevTable != nil {
+					issues[id] = findTransientLoops(nodes, col, prevTable, issues[id])
+				}
+			}
+		}(threadId)
+	}
+	wg.Wait()
+	merged := []RouteIssue{}
+	for _, threadIssues := range issues {
+		for _, issue := range threadIssues {
+			if issue.Kind != TransientLoopIssue {
+				routeTable.Set(issue.Src, issue.Dst, NoRoute)
+			}
+		}
+		merged = append(merged, threadIssues...)
+	}
+	return merged
+}
+
+// column is next hops of all nodes to dst in table, in which routes omitted by validation are NoRoute.
+type column struct {
+	table   *RouteTable
+	dst     int
+	omitted map[int]bool
+}
+
+func (c *column) next(node int) int {
+	if c.omitted[node] {
+		return NoRoute
+	}
+	return c.table.NextHop(node, c.dst)
+}
+
+// validateDestination omits invalid routes to col.dst, and appends their issues.
+func validateDestination(topo Graph, nodes map[int]string, component []int, col *column, issues []RouteIssue) []RouteIssue {
+	nodeCount, dst := col.table.Len(), col.dst
+	for src := 0; src < nodeCount; src++ {
+		if _, ok := nodes[src]; !ok || src == dst {
+			continue
+		}
+		next := col.next(src)
+		if component[src] != component[dst] || next == NoRoute {
+			issues = append(issues, RouteIssue{Src: src, Dst: dst, Kind: UnreachableIssue})
+			col.omitted[src] = true
+		} else if _, linked := topo.Weight(src, next); !linked {
+			issues = append(issues, RouteIssue{Src: src, Dst: dst, Kind: BlackholeIssue})
+			col.omitted[src] = true
+		}
+	}
+
+	// Walk along next hops until dst, NoRoute, a node done before, or a node on the current walk(loop)
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, nodeCount)
+	for start := 0; start < nodeCount; start++ {
+		if _, ok := nodes[start]; !ok {
+			continue
+		}
+		walk := []int{}
+		node := start
+		for node != dst && node != NoRoute && state[node] == unvisited {
+			state[node] = visiting
+			walk = append(walk, node)
+			node = col.next(node)
+		}
+		if node != dst && node != NoRoute && state[node] == visiting {
+			// Nodes on the loop drop packets, and nodes leading to the loop forward to them as before
+			loop := []int{node}
+			for looping := col.next(node); looping != node; looping = col.next(looping) {
+				loop = append(loop, looping)
+			}
+			for _, looping := range loop {
+				issues = append(issues, RouteIssue{Src: looping, Dst: dst, Kind: LoopIssue})
+				col.omitted[looping] = true
+			}
+		}
+		for _, visited := range walk {
+			state[visited] = done
+		}
+	}
+	return issues
+}
+
+// findTransientLoops appends issues of nodes on loops to col.dst formed by the union of col and prevTable,
+// i.e. when some nodes have switched to routes validated while others still forward by prevTable.
+func findTransientLoops(nodes map[int]string, col *column, prevTable *RouteTable, issues []RouteIssue) []RouteIssue {
+	dst := col.dst
+	successors := func(node int) []int {
+		result := []int{}
+		for _, next := range []int{col.next(node), prevTable.NextHop(node, dst)} {
+			if next != NoRoute && next != dst {
+				if _, ok := nodes[next]; ok {
+					result = append(result, next)
+				}
+			}
+		}
+		return result
+	}
+	for _, component := range loopComponents(nodes, col.table.Len(), dst, successors) {
+		for _, looping := range component {
+			issues = append(issues, RouteIssue{Src: looping, Dst: dst, Kind: TransientLoopIssue})
+		}
+	}
+	return issues
+}
+
+// loopComponents returns strongly connected components of more than one node in the forwarding graph to dst,
+// i.e. nodes on loops, found by Tarjan's algorithm.
+// 1. nodes: Indices of nodes taking part in emulation.
+// 2. nodeCount: The number of indices including holes.
+// 3. dst: The destination, which is never on a loop.
+// 4. successors: Next hops of a node to dst other than dst itself.
+func loopComponents(nodes map[int]string, nodeCount, dst int, successors func(node int) []int) [][]int {
+	index, lowLink := make([]int, nodeCount), make([]int, nodeCount)
+	onStack := make([]bool, nodeCount)
+	stack := []int{}
+	counter := 0
+	result := [][]int{}
+	var connect func(node int)
+	connect = func(node int) {
+		counter++
+		index[node], lowLink[node] = counter, counter
+		stack = append(stack, node)
+		onStack[node] = true
+		for _, next := range successors(node) {
+			if index[next] == 0 {
+				connect(next)
+				if lowLink[next] < lowLink[node] {
+					lowLink[node] = lowLink[next]
+				}
+			} else if onStack[next] && index[next] < lowLink[node] {
+				lowLink[node] = index[next]
+			}
+		}
+		if lowLink[node] != index[node] {
+			return
+		}
+		component := []int{}
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == node {
+				break
+			}
+		}
+		if len(component) > 1 {
+			result = append(result, component)
+		}
+	}
+	for node := 0; node < nodeCount; node++ {
+		if _, ok := nodes[node]; ok && node != dst && index[node] == 0 {
+			connect(node)
+		}
+	}
+	return result
+}
+
+// components returns the index of the connected component each node belongs to.
+func components(graph Graph) []int {
+	component := make([]int, len(graph))
+	for i := range component {
+		component[i] = -1
+	}
+	for start := range graph {
+		if component[start] != -1 {
+			continue
+		}
+		component[start] = start
+		queue := []int{start}
+		for len(queue) > 0 {
+			node := queue[0]
+			queue = queue[1:]
+			for _, edge := range graph[node] {
+				if component[edge.To] == -1 {
+					component[edge.To] = start
+					queue = append(queue, edge.To)
+				}
+			}
+		}
+	}
+	return component
+}
@@ -13,17 +13,24 @@ import (
 
 type HttpHandler func(http.ResponseWriter, *http.Request)
 
-func RunSDNServer(url string, expectedNodeNum int, timeout int) error {
+func RunSDNServer(url string, expectedNodeNum int, timeout int, config *clientset.SDNConfig) error {
 	// Create new clientset
 	logger := logrus.WithFields(logrus.Fields{
 		"url": 		url,
 		"node-num": expectedNodeNum,
 		"timeout":  timeout,
+		"topology": config.Topology.Name(),
+		"router":   config.Router.Name(),
 	})
 	logger.WithField("time", time.Now()).Info("start sdn server")
 
-	client := clientset.NewSDNClient(url)
-	if err := client.ApplyTopo(); err != nil {
+	client := clientset.NewSDNClient(url, config)
+	applyTopo, applyRoute := client.ApplyTopo, client.ApplyRoute
+	if config.Reattach {
+		// Objects of existing emulation are kept, whose addresses are stable with IPAM
+		applyTopo, applyRoute = client.UpdateTopo, client.UpdateRoute
+	}
+	if err := applyTopo(); err != nil {
 		logger.WithError(err).Error("apply topology failed")
 		return err
 	}
@@ -31,7 +38,7 @@ func RunSDNServer(url string, expectedNodeNum int, timeout int) error {
 		logger.WithError(err).Error("apply pod failed")
 		return err
 	}
-	if err := client.ApplyRoute(); err != nil {
+	if err := applyRoute(); err != nil {
 		logger.WithError(err).Error("apply route failed")
 		return err
 	}
@@ -46,6 +53,9 @@ func RunSDNServer(url string, expectedNodeNum int, timeout int) error {
 				if err := client.FetchAndUpdate(); err != nil {
 					logger.WithError(err).Error("fetch and update topology err")
 				}
+				if err := client.SyncNodes(); err != nil {
+					logger.WithError(err).Error("sync nodes error")
+				}
 				if err := client.UpdateTopo(); err != nil {
 					logger.WithError(err).Error("update topology error")
 				}
@@ -63,7 +73,11 @@ func RunSDNServer(url string, expectedNodeNum int, timeout int) error {
 		"/getRoute":         client.GetRouteFromAndToHandler,
 		"/getConnection":    client.GetRouteHopsHandler,
 		"/getDistance":      client.GetDistanceHanlder,
+		"/getLatency":       client.GetLatencyHandler,
+		"/getLinkMetrics":   client.GetLinkMetricsHandler,
+		"/reportLinkLoad":   client.ReportLinkLoadHandler,
 		"/getSpreadArray":	 client.GetSpreadArrayHanlder,
+		"/getAccess":        client.GetAccessHandler,
 	}
 	for url, handler := range sdnHandlerMap {
 		http.HandleFunc(url, handler)
@@ -73,16 +87,18 @@ func RunSDNServer(url string, expectedNodeNum int, timeout int) error {
 	return http.ListenAndServe(":30101", nil)
 }
 
-func RunSDNServerTest(url string, expectedNodeNum int, timeout int) error {
+func RunSDNServerTest(url string, expectedNodeNum int, timeout int, config *clientset.SDNConfig) error {
 	// Create new clientset
 	logger := logrus.WithFields(logrus.Fields{
 		"url": 		url,
 		"node-num": expectedNodeNum,
 		"timeout":  timeout,
+		"topology": config.Topology.Name(),
+		"router":   config.Router.Name(),
 	})
 	logger.WithField("time", time.Now()).Info("start sdn server.")
 
-	client := clientset.NewSDNClient(url)
+	client := clientset.NewSDNClient(url, config)
 	logger.WithField("time", time.Now()).Info("sdn server has been started!")
 
 	// Set up sync loop
@@ -105,7 +121,11 @@ func RunSDNServerTest(url string, expectedNodeNum int, timeout int) error {
 		"/getRoute":         client.GetRouteFromAndToHandler,
 		"/getConnection":    client.GetRouteHopsHandler,
 		"/getDistance":      client.GetDistanceHanlder,
+		"/getLatency":       client.GetLatencyHandler,
+		"/getLinkMetrics":   client.GetLinkMetricsHandler,
+		"/reportLinkLoad":   client.ReportLinkLoadHandler,
 		"/getSpreadArray":	 client.GetSpreadArrayHanlder,
+		"/getAccess":        client.GetAccessHandler,
 		"/metrics":			 client.GetFakeMetricsHandler,
 	}
 	for url, handler := range sdnHandlerMap {
@@ -8,7 +8,12 @@ import (
 	gosate "github.com/joshuaferrara/go-satellite"
 )
 
-const NAME_PREFIX_V1 = "sdn"
+const (
+	NAME_PREFIX_V1 = "sdn"
+
+	// EarthMu is the standard gravitational parameter of the Earth(km^3/s^2)
+	EarthMu = 398600.4418
+)
 
 type Satellite struct {
 	Name      string
@@ -193,3 +198,47 @@ func (sat *Satellite) AngleDeltaAtTime(anotherSat Satellite, year, month, day, h
 	}
 	return angleDelta
 }
+
+// Return inclination, right ascension of ascending node(RAAN), argument of latitude in degree
+// and semi-major axis in kilometer at given time, which are derived from propagated state vector.
+// For equatorial orbits, RAAN is 0 and argument of latitude is the true longitude.
+func (sat *Satellite) OrbitalElementsAtTime(year, month, day, hour, minute, second int) (incl, raan, argLat, sma float64) {
+	r, v := gosate.Propagate(
+		sat.Satellite, year, month,
+		day, hour, minute, second,
+	)
+	// Angular momentum h = r x v
+	hx := r.Y*v.Z - r.Z*v.Y
+	hy := r.Z*v.X - r.X*v.Z
+	hz := r.X*v.Y - r.Y*v.X
+	h := math.Sqrt(hx*hx + hy*hy + hz*hz)
+	rNorm := math.Sqrt(r.X*r.X + r.Y*r.Y + r.Z*r.Z)
+	vNorm := math.Sqrt(v.X*v.X + v.Y*v.Y + v.Z*v.Z)
+
+	incl = math.Acos(hz/h) * 180.0 / math.Pi
+	// Node vector n = z x h lies along the line of nodes
+	if math.Hypot(hx, hy) < 1e-9*h {
+		raan = 0
+		argLat = math.Atan2(r.Y, r.X)
+	} else {
+		raanRad := math.Atan2(hx, -hy)
+		raan = raanRad
+		argLat = math.Atan2(
+			r.Z/math.Sin(math.Acos(hz/h)),
+			r.X*math.Cos(raanRad)+r.Y*math.Sin(raanRad),
+		)
+	}
+	raan = normalizeDegree(raan * 180.0 / math.Pi)
+	argLat = normalizeDegree(argLat * 180.0 / math.Pi)
+	// Vis-viva equation
+	sma = 1.0 / (2.0/rNorm - vNorm*vNorm/EarthMu)
+	return
+}
+
+func normalizeDegree(deg float64) float64 {
+	deg = math.Mod(deg, 360.0)
+	if deg < 0 {
+		deg += 360.0
+	}
+	return deg
+}
@@ -17,6 +17,27 @@ const (
 	USER		  = 6
 
 	NAME_PREFIX_V2 = "sdn"
+
+	// EarthRadius is the radius(km) of the spherical Earth used by LLA->ECI conversion
+	EarthRadius = 6378.137
+)
+
+var (
+	// GrazingAltitude is the min altitude(km) that a line-of-sight link can pass by,
+	// which leaves a margin for the atmosphere.
+	GrazingAltitude = 80.0
+
+	// DefaultMinElevation is the min elevation angle(degree) for a terminal to access a satellite,
+	// used when the terminal does not declare its own elevation mask.
+	DefaultMinElevation = 10.0
+
+	// DefaultMaxAccessNum is the max number of satellites a terminal accesses simultaneously,
+	// used when the terminal does not declare its own value.
+	DefaultMaxAccessNum = 1
+
+	// HighOrbitAltitude is the min altitude(km) of satellites in the high-orbit(MEO/GEO/IGSO) layer,
+	// which is above LEO shells(<2000km) and below MEO navigation satellites(~20000km, e.g. BeiDou/GPS)
+	HighOrbitAltitude = 10000.0
 )
 
 type NodeType int
@@ -30,6 +51,8 @@ type NodeInterface interface {
 	AngleAtTime(t time.Time) float64
 	AngleDeltaWithNode(node *Node) float64
 	AngleDeltaWithNodeAtTime(node *Node, t time.Time) float64
+	LineOfSightWithNodeAtTime(node *Node, t time.Time) bool
+	ElevationWithNodeAtTime(node *Node, t time.Time) float64
 }
 
 type Node struct {
@@ -40,6 +63,12 @@ type Node struct {
 	Latitude  float64
 	Longitude float64
 	Altitude  float64
+
+	// MinElevation is the elevation mask(degree) of terminals(ground station/missile/user)
+	MinElevation float64
+
+	// MaxAccessNum is the max number of satellites a terminal connects to
+	MaxAccessNum int
 }
 
 func NewSatNode(nodeType NodeType, params map[string]interface{}) Node {
@@ -55,25 +84,36 @@ func NewSatNode(nodeType NodeType, params map[string]interface{}) Node {
 }
 
 func NewOtherNode(nodeType NodeType, params map[string]interface{}) Node {
-	return Node{
-		Type:      nodeType,
-		UUID:      params["uuid"].(string),
-		Latitude:  params["lat"].(float64),
-		Longitude: params["lon"].(float64),
-		Altitude:  params["height"].(float64),
+	node := Node{
+		Type:         nodeType,
+		UUID:         params["uuid"].(string),
+		Latitude:     params["lat"].(float64),
+		Longitude:    params["lon"].(float64),
+		Altitude:     params["height"].(float64),
+		MinElevation: DefaultMinElevation,
+		MaxAccessNum: DefaultMaxAccessNum,
+	}
+	// Terminals may declare their own elevation mask and access num
+	if minElevation, ok := params["minElevation"].(float64); ok {
+		node.MinElevation = minElevation
+	}
+	if maxAccessNum, ok := params["maxAccess"].(float64); ok && maxAccessNum > 0 {
+		node.MaxAccessNum = (int)(maxAccessNum)
 	}
+	return node
 }
 
 // Return the position of node expressed by x/y/z
 func (n *Node) Position() (x, y, z float64) {
-	// Declare current time
+	// Declare current time in UTC
+	now := time.Now().UTC()
 	year, month, day, hour, minute, second :=
-		time.Now().Year(),
-		int(time.Now().Month()),
-		time.Now().Day(),
-		time.Now().Hour(),
-		time.Now().Minute(),
-		time.Now().Second()
+		now.Year(),
+		int(now.Month()),
+		now.Day(),
+		now.Hour(),
+		now.Minute(),
+		now.Second()
 
 	// Construct params for conversion
 	obsCoords := gosate.LatLong{
@@ -93,7 +133,8 @@ func (n *Node) Position() (x, y, z float64) {
 
 // Return the position of node expressed by x/y/z at given time
 func (n *Node) PositionAtTime(t time.Time) (x, y, z float64) {
-	// Declare time
+	// Declare time, calendar fields are in UTC
+	t = t.UTC()
 	year, month, day, hour, minute, second :=
 		t.Year(), int(t.Month()),
 		t.Day(), t.Hour(),
@@ -188,3 +229,55 @@ func (n *Node) AngleDeltaAtTime(node *Node, t time.Time) float64 {
 	}
 	return angleDelta
 }
+
+// Return whether the straight line between the node and the specified node clears the Earth
+// plus GrazingAltitude at given time.
+// Endpoints lower than the grazing shell(e.g. ground stations) only require the line to stay
+// above their own altitude, so that a satellite is visible to them when it is above the horizon.
+func (n *Node) LineOfSightWithNodeAtTime(node *Node, t time.Time) bool {
+	x1, y1, z1 := n.PositionAtTime(t)
+	x2, y2, z2 := node.PositionAtTime(t)
+	return LineOfSight(x1, y1, z1, x2, y2, z2, GrazingAltitude)
+}
+
+// Return the elevation angle(degree) of the specified node seen from the node at given time.
+// The local horizon is perpendicular to the line from the Earth's center to the node.
+func (n *Node) ElevationWithNodeAtTime(node *Node, t time.Time) float64 {
+	x1, y1, z1 := n.PositionAtTime(t)
+	x2, y2, z2 := node.PositionAtTime(t)
+	return Elevation(x1, y1, z1, x2, y2, z2)
+}
+
+// Return the elevation angle(degree) of (x2, y2, z2) seen from (x1, y1, z1) in ECI.
+func Elevation(x1, y1, z1, x2, y2, z2 float64) float64 {
+	dx, dy, dz := x2-x1, y2-y1, z2-z1
+	r := math.Sqrt(x1*x1 + y1*y1 + z1*z1)
+	distance := math.Sqrt(dx*dx + dy*dy + dz*dz)
+	if r == 0 || distance == 0 {
+		return 90.0
+	}
+	sinEl := (dx*x1 + dy*y1 + dz*z1) / (r * distance)
+	return math.Asin(math.Max(-1, math.Min(1, sinEl))) * 180.0 / math.Pi
+}
+
+// Return whether segment (x1, y1, z1)-(x2, y2, z2) in ECI clears the sphere of EarthRadius+grazingAltitude.
+func LineOfSight(x1, y1, z1, x2, y2, z2, grazingAltitude float64) bool {
+	limit := EarthRadius + grazingAltitude
+	r1 := math.Sqrt(x1*x1 + y1*y1 + z1*z1)
+	r2 := math.Sqrt(x2*x2 + y2*y2 + z2*z2)
+	limit = math.Min(limit, math.Min(r1, r2))
+
+	// Find the point on segment closest to the Earth's center
+	dx, dy, dz := x2-x1, y2-y1, z2-z1
+	length2 := dx*dx + dy*dy + dz*dz
+	if length2 == 0 {
+		return true
+	}
+	ratio := -(x1*dx + y1*dy + z1*dz) / length2
+	if ratio <= 0 || ratio >= 1 {
+		return true
+	}
+	cx, cy, cz := x1+ratio*dx, y1+ratio*dy, z1+ratio*dz
+	// Tolerate rounding error for endpoints lying exactly on the limit
+	return math.Sqrt(cx*cx+cy*cy+cz*cz) >= limit-1e-6
+}
@@ -0,0 +1,50 @@
+package v2
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLineOfSight(t *testing.T) {
+	r := EarthRadius + 550.0
+	// Two satellites on opposite sides of the Earth
+	if LineOfSight(r, 0, 0, -r, 0, 0, 0) {
+		t.Errorf("link through the Earth should be occluded")
+	}
+	// Two neighbouring satellites
+	if !LineOfSight(r, 0, 0, 0.9*r, 0.43*r, 0, 80) {
+		t.Errorf("link between neighbours should be visible")
+	}
+	// Ground station and a satellite above/below its horizon
+	if !LineOfSight(EarthRadius, 0, 0, r, 1000, 0, GrazingAltitude) {
+		t.Errorf("satellite above horizon should be visible")
+	}
+	if LineOfSight(EarthRadius, 0, 0, 0, r, 0, GrazingAltitude) {
+		t.Errorf("satellite below horizon should be occluded")
+	}
+}
+
+func TestElevation(t *testing.T) {
+	station := Node{Latitude: 0, Longitude: 0, Altitude: 0}
+	zenith := Node{Latitude: 0, Longitude: 0, Altitude: 550}
+	horizon := Node{Latitude: 0, Longitude: 40, Altitude: 550}
+	// A fixed time keeps the test deterministic, and asin amplifies rounding errors near 90 degrees
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if el := station.ElevationWithNodeAtTime(&zenith, now); math.Abs(el-90) > 1e-3 {
+		t.Errorf("expected elevation 90, got %v", el)
+	}
+	if el := station.ElevationWithNodeAtTime(&horizon, now); el > 0 {
+		t.Errorf("expected negative elevation, got %v", el)
+	}
+}
+
+func TestPositionAtTimeZone(t *testing.T) {
+	node := Node{Latitude: 31, Longitude: 121, Altitude: 0}
+	utc := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	x1, y1, z1 := node.PositionAtTime(utc)
+	x2, y2, z2 := node.PositionAtTime(utc.In(time.FixedZone("UTC+8", 8*3600)))
+	if x1 != x2 || y1 != y2 || z1 != z2 {
+		t.Errorf("position should not depend on time zone, got (%v, %v, %v) and (%v, %v, %v)", x1, y1, z1, x2, y2, z2)
+	}
+}
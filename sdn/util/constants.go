@@ -4,6 +4,14 @@ const (
 	POD_IMAGE_NAME = "electronicwaste/podserver"
 	POD_IMAGE_TAG  = "v29"
 	ThreadNums     = 64
+
+	// Address families of global and link IPs
+	IPv4 = "ipv4"
+	IPv6 = "ipv6"
+
+	// Default ULA prefixes of IPv6 mode
+	DefaultGlobalPrefixV6 = "fd00:233::/64"
+	DefaultLinkPrefixV6   = "fd00:234::/64"
 )
 
 var (
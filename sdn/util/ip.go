@@ -1,11 +1,22 @@
 package util
 
 import (
+	"encoding/binary"
 	"fmt"
+	"net"
 	"os/exec"
 	"strings"
 )
 
+var (
+	// IPFamily is the address family of global and link IPs, set by SetIPFamily
+	IPFamily = IPv4
+
+	// GlobalPrefixV6 and LinkPrefixV6 are prefixes of global and link IPs in IPv6 mode
+	GlobalPrefixV6, _ = parsePrefixV6(DefaultGlobalPrefixV6)
+	LinkPrefixV6, _   = parsePrefixV6(DefaultLinkPrefixV6)
+)
+
 // Get a pod's ip via 'kubectl get pod <podName> -o wide' instruction by parsing the output.
 func GetPodIP(podName string) (string, error) {
 	// Executing 'kubectl get pod <podName> -o wide'
@@ -38,7 +49,11 @@ func GetPodIP(podName string) (string, error) {
 // IP = uid << 2 | 0x1/0x2 (according to whether myID < peerID)
 // The highest bit of IP must be 1
 // Can support allocating IP to at most 2^14 pods
+// In IPv6 mode, the link address is allocated from LinkPrefixV6 instead, see getVxlanIPv6.
 func GetVxlanIP(myID, peerID uint) string {
+	if IPFamily == IPv6 {
+		return getVxlanIPv6(myID, peerID)
+	}
 	var uid uint
 	netIP := make([]string, 4)
 	if myID < peerID {
@@ -57,7 +72,11 @@ func GetVxlanIP(myID, peerID uint) string {
 
 // Allocate global IP to pod according to its idx
 // IP = 10.233.((idx >> 8)&0xff).(idx&0xff)
+// In IPv6 mode, IP = GlobalPrefixV6 | idx
 func GetGlobalIP(myID uint) string {
+	if IPFamily == IPv6 {
+		return getGlobalIPv6(myID)
+	}
 	netIP := make([]string, 4)
 	netIP[0] = "10"
 	netIP[1] = "233"
@@ -66,3 +85,88 @@ func GetGlobalIP(myID uint) string {
 
 	return strings.Join(netIP, ".")
 }
+
+// Function: IsGlobalIP
+// Description: Return true if ip is in the range of global IPs allocated by GetGlobalIP in current address family.
+func IsGlobalIP(ip net.IP) bool {
+	if IPFamily == IPv6 {
+		return ip.To4() == nil && GlobalPrefixV6.Contains(ip)
+	}
+	ip = ip.To4()
+	return ip != nil && ip[0] == 10 && ip[1] == 233
+}
+
+// Function: SetIPFamily
+// Description: Choose the address family of global and link IPs, which should be called before any IP is allocated.
+// 1. family: IPv4 or IPv6.
+// 2. globalPrefix: ULA prefix(at most /64) of global IPs in IPv6 mode, empty means DefaultGlobalPrefixV6.
+// 3. linkPrefix: ULA prefix(at most /64) of link IPs in IPv6 mode, empty means DefaultLinkPrefixV6.
+func SetIPFamily(family, globalPrefix, linkPrefix string) error {
+	switch family {
+	case IPv4:
+		IPFamily = IPv4
+		return nil
+	case IPv6:
+	default:
+		return fmt.Errorf("unknown ip family %s, expect %s or %s", family, IPv4, IPv6)
+	}
+	if globalPrefix == "" {
+		globalPrefix = DefaultGlobalPrefixV6
+	}
+	if linkPrefix == "" {
+		linkPrefix = DefaultLinkPrefixV6
+	}
+	global, err := parsePrefixV6(globalPrefix)
+	if err != nil {
+		return err
+	}
+	link, err := parsePrefixV6(linkPrefix)
+	if err != nil {
+		return err
+	}
+	if global.Contains(link.IP) || link.Contains(global.IP) {
+		return fmt.Errorf("global prefix %s overlaps with link prefix %s", globalPrefix, linkPrefix)
+	}
+	IPFamily, GlobalPrefixV6, LinkPrefixV6 = IPv6, global, link
+	return nil
+}
+
+// parsePrefixV6 parses an IPv6 prefix which leaves at least 64 bits for host part.
+func parsePrefixV6(prefix string) (*net.IPNet, error) {
+	ip, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ipv6 prefix %s: %v", prefix, err)
+	}
+	if ip.To4() != nil {
+		return nil, fmt.Errorf("invalid ipv6 prefix %s: not an ipv6 address", prefix)
+	}
+	if ones, _ := ipNet.Mask.Size(); ones > 64 {
+		return nil, fmt.Errorf("invalid ipv6 prefix %s: prefix length should be no more than 64", prefix)
+	}
+	return ipNet, nil
+}
+
+// withHostV6 returns the address in prefix whose lower 64 bits are host.
+func withHostV6(prefix *net.IPNet, host uint64) net.IP {
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, prefix.IP.To16())
+	binary.BigEndian.PutUint64(ip[8:], host)
+	return ip
+}
+
+// IP = GlobalPrefixV6 | idx
+func getGlobalIPv6(myID uint) string {
+	return withHostV6(GlobalPrefixV6, uint64(myID)).String()
+}
+
+// IP = LinkPrefixV6 | (smallerID << 32 | largerID) << 1 | 0x0/0x1 (according to whether myID < peerID)
+// Each link is a /127 point-to-point subnet, which supports allocating IP to at most 2^31 pods
+func getVxlanIPv6(myID, peerID uint) string {
+	var host uint64
+	if myID < peerID {
+		host = (uint64(myID) << 32 | uint64(peerID)) << 1
+	} else {
+		host = (uint64(peerID) << 32 | uint64(myID)) << 1 | 0x1
+	}
+	return withHostV6(LinkPrefixV6, host).String() + "/127"
+}